@@ -1,6 +1,6 @@
 #!/bin/bash
 
-# label the hotel res deployments with the vector label
+# label the hotel res deployments with the vector label and give each a podoscaler policy
 deployments=("frontend-hotelres" "geo-hotelres" "profile-hotelres" "rate-hotelres" "recommendation-hotelres" "reservation-hotelres" "search-hotelres" "user-hotelres")
 
 namespace="deathstarbench"
@@ -8,11 +8,23 @@ namespace="deathstarbench"
 label_key="vecter"
 label_value="true"
 
+kubectl apply -f ./deploy/crd-podoscalerpolicy.yaml
 kubectl scale deployment -n deathstarbench --all --replicas=1
 
 for deployment in "${deployments[@]}"; do
   echo "Labeling and setting resources for $deployment..."
-  kubectl label deployment "$deployment" "$label_key=$label_value" -n "$namespace" --overwrite
+  kubectl label deployment "$deployment" "$label_key=$label_value" -n "$namespace" --overwrite # watcher
+  kubectl apply -n "$namespace" -f - <<EOF
+apiVersion: vecter.io/v1alpha1
+kind: PodoscalerPolicy
+metadata:
+  name: $deployment
+spec:
+  targetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: $deployment
+EOF
   kubectl patch deployment $deployment -n deathstarbench --type="json" -p="[{'op': 'replace', 'path': '/spec/template/spec/containers/0/resources', 'value': {'requests': {'cpu': '100m'}}}]"
 done
 
@@ -2,4 +2,5 @@
 set -e
 minikube start --nodes 1 --driver=docker --feature-gates=InPlacePodVerticalScaling=true
 kubectl apply -f ./deploy/rbac.yaml
+kubectl apply -f ./deploy/crd-podoscalerpolicy.yaml
 kubectl apply -f ./deploy/components.yaml
\ No newline at end of file
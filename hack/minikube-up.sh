@@ -7,4 +7,5 @@ linkerd install --set proxyInit.runAsRoot=true | kubectl apply -f -
 linkerd viz install | kubectl apply -f -
 linkerd inject <(kubectl get deploy -n ingress-nginx ingress-nginx-controller -o yaml) | kubectl apply -f -
 kubectl apply -f ./deploy/rbac.yaml
+kubectl apply -f ./deploy/crd-podoscalerpolicy.yaml
 kubectl apply -f ./deploy/components.yaml
\ No newline at end of file
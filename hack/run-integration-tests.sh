@@ -9,7 +9,7 @@ echo "<<< BUILDING AUTOSCALER >>>"
 docker image build -q -t autoscaler-img --build-arg BUILD_TAG=autoscalertest ./scalers
 
 kubectl apply -f ./deploy/deploy-dummy.yaml
-kubectl label deployment dummy vecter=true --overwrite
+kubectl apply -f ./deploy/podoscalerpolicy-dummy.yaml
 kubectl apply -f ./deploy/deploy-autoscaler-test.yaml
 
 echo
@@ -20,5 +20,6 @@ sleep 15
 kubectl logs autoscaler-test
 
 echo
+kubectl delete podoscalerpolicy dummy
 kubectl delete deployment dummy
 kubectl delete pod autoscaler-test
\ No newline at end of file
@@ -4,4 +4,5 @@ eval $(minikube -p minikube docker-env)
 docker image build -t testapp-img ./testapp
 kubectl apply -f ./deploy/deploy-testapp.yaml
 kubectl apply -f ./deploy/deploy-ingress.yaml
-kubectl label deployment testapp vecter=true --overwrite
\ No newline at end of file
+kubectl label deployment testapp vecter=true --overwrite # watcher
+kubectl apply -f ./deploy/podoscalerpolicy-testapp.yaml
\ No newline at end of file
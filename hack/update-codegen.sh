@@ -0,0 +1,9 @@
+#!/bin/bash
+# regenerates the deepcopy functions and the CRD manifest after changing scalers/api
+set -e
+cd ./scalers
+go run sigs.k8s.io/controller-tools/cmd/controller-gen@v0.17.2 \
+  object paths=./api/... \
+  crd:crdVersions=v1 output:crd:stdout > ../deploy/crd-podoscalerpolicy.yaml.tmp
+{ echo "# generated from scalers/api/v1alpha1 by hack/update-codegen.sh"; cat ../deploy/crd-podoscalerpolicy.yaml.tmp; } > ../deploy/crd-podoscalerpolicy.yaml
+rm ../deploy/crd-podoscalerpolicy.yaml.tmp
@@ -0,0 +1,4 @@
+// Package v1alpha1 holds the PodoscalerPolicy custom resource.
+// +kubebuilder:object:generate=true
+// +groupName=vecter.io
+package v1alpha1
@@ -0,0 +1,199 @@
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+/* --- SPEC ---
+ * every knob is optional - unset ones fall back to the target's vecter.io/ annotations,
+ * then to the autoscaler-wide defaults
+ */
+
+// the workload a policy scales, in the policy's namespace
+type TargetReference struct {
+	// +kubebuilder:default=apps/v1
+	APIVersion string `json:"apiVersion,omitempty"`
+	// +kubebuilder:validation:Enum=Deployment
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// one percentile of the slo, e.g. p95 under 30ms
+type LatencyTargetSpec struct {
+	// +kubebuilder:validation:Pattern=`^p[0-9]+(\.[0-9]+)?$`
+	Percentile string `json:"percentile"`
+	// +kubebuilder:validation:Minimum=1
+	ThresholdMillis int64 `json:"thresholdMillis"`
+	// share of the combined violation score, set on every target or none
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	WeightPercent *int32 `json:"weightPercent,omitempty"`
+}
+
+type SLOSpec struct {
+	// p99 latency the target should stay under, unless latencyTargets are set
+	// +kubebuilder:validation:Minimum=1
+	LatencyThresholdMillis *int64 `json:"latencyThresholdMillis,omitempty"`
+	// percentile targets that all have to hold, or with weights their combined score
+	// +kubebuilder:validation:MinItems=1
+	LatencyTargets []LatencyTargetSpec `json:"latencyTargets,omitempty"`
+	// inherit, service:[<namespace>/]<name> or promql:<query>
+	LatencySource string `json:"latencySource,omitempty"`
+	// controller mode holds latency at this share of each latency target
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	LatencySetpointPercent *int32 `json:"latencySetpointPercent,omitempty"`
+	// share of requests that have to succeed, e.g. "99.9", unset for no availability slo
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	AvailabilityPercent string `json:"availabilityPercent,omitempty"`
+	// promql for requests and 5xx responses per second, needed with a promql latency source
+	RequestsQuery string `json:"requestsQuery,omitempty"`
+	ErrorsQuery   string `json:"errorsQuery,omitempty"`
+}
+
+type BoundsSpec struct {
+	// +kubebuilder:validation:Minimum=1
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+	// per pod cpu requests
+	MinCPU *resource.Quantity `json:"minCPU,omitempty"`
+	MaxCPU *resource.Quantity `json:"maxCPU,omitempty"`
+	// per pod memory requests
+	MinMemory *resource.Quantity `json:"minMemory,omitempty"`
+	MaxMemory *resource.Quantity `json:"maxMemory,omitempty"`
+}
+
+// thresholds are percentages, since floats don't round trip well through CRDs
+type ScalingSpec struct {
+	// cpu a single replica should handle
+	Maps *resource.Quantity `json:"maps,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	MinNodeAvailabilityPercent *int32 `json:"minNodeAvailabilityPercent,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	DownscaleUtilizationPercent *int32 `json:"downscaleUtilizationPercent,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	MemoryPressurePercent *int32 `json:"memoryPressurePercent,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	MemoryDownscalePercent *int32 `json:"memoryDownscalePercent,omitempty"`
+	// keep, ratio:<limit / request> or unbounded
+	CPULimit string `json:"cpuLimit,omitempty"`
+	// containers left out of measuring and resizing, replaces the default list
+	Sidecars []string `json:"sidecars,omitempty"`
+	// how long a higher recommendation has to hold before scaling up, 0 scales up right away
+	// +kubebuilder:validation:Minimum=0
+	ScaleUpStabilizationSeconds *int32 `json:"scaleUpStabilizationSeconds,omitempty"`
+	// how long a lower recommendation has to hold before scaling down, 0 scales down right away
+	// +kubebuilder:validation:Minimum=0
+	ScaleDownStabilizationSeconds *int32 `json:"scaleDownStabilizationSeconds,omitempty"`
+	// share of the forecast in the demand sized for, 0 scales on current load only
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	ForecastWeightPercent *int32 `json:"forecastWeightPercent,omitempty"`
+	// how far ahead to scale for the forecast
+	// +kubebuilder:validation:Minimum=0
+	ForecastHorizonSeconds *int32 `json:"forecastHorizonSeconds,omitempty"`
+	// rules scales on slo violations, controller sizes total cpu to hold latency at the setpoint
+	// +kubebuilder:validation:Enum=rules;controller
+	Mode string `json:"mode,omitempty"`
+	// controller mode, largest change of total cpu in one round as a share of the allocation
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	ControllerMaxStepPercent *int32 `json:"controllerMaxStepPercent,omitempty"`
+	// the algorithm that plans the target: vecter, or the hpa and vpa baselines
+	// +kubebuilder:validation:Enum=vecter;hpa;vpa
+	Policy string `json:"policy,omitempty"`
+	// hpa policy, cpu usage as a share of requests to hold
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	HPATargetUtilizationPercent *int32 `json:"hpaTargetUtilizationPercent,omitempty"`
+	// when a HorizontalPodAutoscaler also targets the deployment: refuse to scale it, take over from the hpa, or scale requests only
+	// +kubebuilder:validation:Enum=refuse;takeover;vertical
+	HPAInterop string `json:"hpaInterop,omitempty"`
+}
+
+type PodoscalerPolicySpec struct {
+	TargetRef TargetReference `json:"targetRef"`
+	SLO       SLOSpec         `json:"slo,omitempty"`
+	Bounds    BoundsSpec      `json:"bounds,omitempty"`
+	Scaling   ScalingSpec     `json:"scaling,omitempty"`
+}
+
+/* --- STATUS --- */
+
+// the target as the autoscaler last saw it
+type ObservedState struct {
+	Time                metav1.Time `json:"time"`
+	Replicas            int32       `json:"replicas"`
+	CPURequestsMillis   int64       `json:"cpuRequestsMillis"` // per pod
+	UtilizationMillis   int64       `json:"utilizationMillis"`
+	AllocationMillis    int64       `json:"allocationMillis"`
+	MemoryRequestsBytes int64       `json:"memoryRequestsBytes"` // per pod
+	MemoryUsageBytes    int64       `json:"memoryUsageBytes"`
+	LatencyMillis       *int64      `json:"latencyMillis,omitempty"` // unset if latency could not be read
+	SLOViolated         bool        `json:"sloViolated"`
+}
+
+type DecisionAction struct {
+	Type                string `json:"type"` // hscale, vscale or migrate
+	Replicas            int32  `json:"replicas,omitempty"`
+	CPURequestsMillis   int64  `json:"cpuRequestsMillis,omitempty"`
+	MemoryRequestsBytes int64  `json:"memoryRequestsBytes,omitempty"`
+	PodName             string `json:"podName,omitempty"`
+	Node                string `json:"node,omitempty"` // where a migrated pod was moved to
+	Reason              string `json:"reason,omitempty"`
+}
+
+type Decision struct {
+	Time    metav1.Time      `json:"time"`
+	DryRun  bool             `json:"dryRun,omitempty"` // planned but not applied
+	Actions []DecisionAction `json:"actions,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+const (
+	// True when the last round went through, False with the reason otherwise
+	ReconciledCondition = "Reconciled"
+
+	ReconciledReason     = "Reconciled"
+	InvalidTargetReason  = "InvalidTarget"
+	InvalidPolicyReason  = "InvalidPolicy"
+	HPAConflictReason    = "HPAConflict"
+	ReconcileErrorReason = "ReconcileError"
+)
+
+type PodoscalerPolicyStatus struct {
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Observed           *ObservedState     `json:"observed,omitempty"`
+	LastDecision       *Decision          `json:"lastDecision,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=pdp
+// +kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.targetRef.name`
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.status.observed.replicas`
+// +kubebuilder:printcolumn:name="Reconciled",type=string,JSONPath=`.status.conditions[?(@.type=="Reconciled")].status`
+
+// PodoscalerPolicy opts a workload into the autoscaler and holds its SLO, bounds and knobs
+type PodoscalerPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PodoscalerPolicySpec   `json:"spec"`
+	Status PodoscalerPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+type PodoscalerPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PodoscalerPolicy `json:"items"`
+}
@@ -0,0 +1,138 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"fmt"
+	"math"
+)
+
+/* --- LATENCY CONTROLLER ---
+ * a PI controller for deployments in controller mode, sizing total cpu from how far latency is off a setpoint below the slo
+ * latency is measured as the slo's latency score (see SLO), so every target counts and the setpoint is a share of each of them
+ * the output is headroom over demand: target = demand x (1 + KP x error + KI x integral), error = (score - setpoint) / setpoint
+ * the integral learns the headroom that holds latency at the setpoint, and starts from the current allocation (no bump on switch over)
+ * anti-windup: the integral is held while the output is clamped in the direction it would grow, or while the last target
+ * has not been applied yet (stabilization, a failed resize), and KI x integral never leaves [0, CONTROLLER_MAX_HEADROOM]
+ * congestion and node migrations are left to rules mode
+ */
+const (
+	CONTROLLER_KP           = 0.5
+	CONTROLLER_KI           = 0.1
+	CONTROLLER_MAX_ERROR    = 1.0  // relative error is clamped to ±1, a latency spike is no bigger a push than twice the setpoint
+	CONTROLLER_MAX_HEADROOM = 3.0  // total cpu is kept between 1x and 4x demand
+	CONTROLLER_DEADBAND     = 0.05 // targets this close to the allocation are left alone
+	CONTROLLER_MIN_SETPOINT = 0.01 // setpoints below this share of the slo are raised to it
+)
+
+// latency score the controller aims for, the setpoint's share of every latency target
+func (p DeploymentPolicy) Setpoint() float64 {
+	return max(p.LatencySetpoint, CONTROLLER_MIN_SETPOINT)
+}
+
+type Controller struct {
+	Integral float64 // accumulated error, in rounds
+	Target   int64   // total millicpus asked for last round, 0 before the first
+}
+
+// total cpu the deployment should have this round, and the error it was sized from
+// state must have a latency reading
+func (c *Controller) Update(state DeploymentState) (int64, float64) {
+	policy := state.Policy
+	setpoint := policy.Setpoint()
+	score := state.latencyScore()
+	e := math.Max(-CONTROLLER_MAX_ERROR, math.Min((score-setpoint)/setpoint, CONTROLLER_MAX_ERROR))
+	demand := float64(max(state.Demand(), 1))
+	allocation := float64(state.Allocation)
+
+	if c.Target == 0 {
+		c.Integral = math.Max(0, math.Min((allocation/demand-1-CONTROLLER_KP*e)/CONTROLLER_KI, CONTROLLER_MAX_HEADROOM/CONTROLLER_KI))
+	}
+	integral := c.Integral + e
+	if c.Target > 0 && math.Abs(allocation-float64(c.Target)) > CONTROLLER_DEADBAND*float64(c.Target) {
+		integral = c.Integral // last round's change has not landed
+	}
+	integral = math.Max(0, math.Min(integral, CONTROLLER_MAX_HEADROOM/CONTROLLER_KI))
+
+	headroom := math.Max(0, math.Min(CONTROLLER_KP*e+CONTROLLER_KI*integral, CONTROLLER_MAX_HEADROOM))
+	raw := demand * (1 + headroom)
+	target := math.Max(allocation*(1-policy.ControllerMaxStep), math.Min(raw, allocation*(1+policy.ControllerMaxStep)))
+	if policy.MaxReplicas > 0 && policy.MaxCPU > 0 {
+		target = math.Min(target, float64(int64(policy.MaxReplicas)*policy.MaxCPU))
+	}
+	target = math.Max(target, float64(int64(policy.MinReplicas)*policy.MinCPU))
+
+	// clamped in the direction the error pushes, integrating further would only wind up
+	if (raw > target && e > 0) || (raw < target && e < 0) {
+		integral = c.Integral
+	}
+
+	c.Integral = integral
+	c.Target = int64(math.Ceil(target))
+	return c.Target, e
+}
+
+// runs the deployment's controller, returns the total millicpus it asks for
+// 0 if the deployment is not in controller mode or latency could not be read, the rules decide then
+// the controller is part of the vecter policy, other policies never run it
+func (a *Autoscaler) control(state DeploymentState) int64 {
+	key := fmt.Sprintf("%s/%s", state.Namespace, state.Name)
+	a.controllerMu.Lock()
+	defer a.controllerMu.Unlock()
+
+	if state.Policy.Mode != ControllerScalingMode || state.Policy.ScalingPolicy != VECTER_SCALING_POLICY || state.Allocation == 0 {
+		delete(a.controllers, key) // starts over from the allocation if switched back
+		return 0
+	}
+	if state.LatencyErr != nil {
+		return 0
+	}
+	if a.controllers == nil {
+		a.controllers = map[string]*Controller{}
+	}
+	c, ok := a.controllers[key]
+	if !ok {
+		c = &Controller{}
+		a.controllers[key] = c
+	}
+
+	target, e := c.Update(state)
+	fmt.Printf("📊 Controller for %s: %s, latency score %.2f against setpoint %.2f (error %+.2f, integral %.2f) -> %d millicpus\n",
+		state.Name, state.describeSLO(), state.latencyScore(), state.Policy.Setpoint(), e, c.Integral, target)
+	return target
+}
+
+// sizes replicas and requests for the controller's target, in place of planCPU
+func planController(state DeploymentState, decision *DeploymentDecision) {
+	policy := state.Policy
+	target := state.ControlTarget
+	decision.logf("📊 Current state: %d/%d millicpus, controller target %d millicpus", state.Utilization, state.Allocation, target)
+	if math.Abs(float64(target-state.Allocation)) <= CONTROLLER_DEADBAND*float64(state.Allocation) {
+		decision.logf("ℹ️ Controller target within %.0f%% of the allocation - no action taken", CONTROLLER_DEADBAND*100)
+		return
+	}
+
+	numPods := len(state.Pods)
+	perpodalloc := state.PerPodAllocation()
+	replicas := decision.boundReplicas(policy, int(math.Ceil(float64(target)/float64(policy.Maps))))
+	requests := decision.boundRequests(policy, int64(math.Ceil(float64(target)/float64(replicas))))
+
+	if replicas > numPods { // hscale first (total increase) then vscale (possible decrease)
+		decision.logf("🔄 Horizontal scaling: %d -> %d replicas", numPods, replicas)
+		decision.add(ScaleAction{Type: HScaleAction, Replicas: replicas, Reason: "controller target"})
+		decision.logf("🔄 Vertical scaling: %d -> %d millicpus", perpodalloc, requests)
+		decision.add(ScaleAction{Type: VScaleAction, CpuRequests: requests, Reason: "resize after hscale"})
+	} else if replicas < numPods { // vscale first (possible increase) then hscale (decrease)
+		decision.logf("🔄 Vertical scaling: %d -> %d millicpus", perpodalloc, requests)
+		decision.add(ScaleAction{Type: VScaleAction, CpuRequests: requests, Reason: "controller target"})
+		decision.logf("🔄 Horizontal scaling: %d -> %d replicas", numPods, replicas)
+		decision.add(ScaleAction{Type: HScaleAction, Replicas: replicas, Reason: "controller target"})
+		// have to vscale new pods again
+		decision.logf("🔄 Vertical scaling: %d -> %d millicpus", perpodalloc, requests)
+		decision.add(ScaleAction{Type: VScaleAction, CpuRequests: requests, Reason: "resize after hscale"})
+	} else if requests != perpodalloc {
+		decision.logf("🔄 Vertical scaling: %d -> %d millicpus", perpodalloc, requests)
+		decision.add(ScaleAction{Type: VScaleAction, CpuRequests: requests, Reason: "controller target"})
+	}
+}
@@ -0,0 +1,365 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	util "github.com/tholiang/podoscaler/scalers/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+/* --- EXECUTOR ---
+ * applies the planner's actions to the cluster
+ */
+
+// applies the actions in order, stopping at the first failure or once ctx is done
+// migration actions hold the migration lock so two deployments never count on the same node headroom
+// a vscale the kubelet calls infeasible falls back once (see PlanResizeFallback), the fallback is added to dplan
+func (a *Autoscaler) execute(ctx context.Context, state DeploymentState, actions []ScaleAction, dplan *DeploymentPlan) error {
+	migrating := false
+	defer func() {
+		if migrating {
+			a.migrationMu.Unlock()
+		}
+	}()
+
+	for _, action := range actions {
+		if action.Migration != migrating {
+			if action.Migration {
+				a.migrationMu.Lock()
+			} else {
+				a.migrationMu.Unlock()
+			}
+			migrating = action.Migration
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("stopped before %s of deployment %s: %w", action.Type, state.Name, ctx.Err())
+		}
+
+		err := a.executeAction(ctx, state, action, dplan)
+		if errors.Is(err, util.ErrResizeInfeasible) && !action.Fallback {
+			err = a.resizeFallback(ctx, state, action, dplan, err)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to %s deployment %s: %w", action.Type, state.Name, err)
+		}
+	}
+	return nil
+}
+
+// the action keeps going for the grace period if ctx is done while it runs
+func (a *Autoscaler) executeAction(ctx context.Context, state DeploymentState, action ScaleAction, dplan *DeploymentPlan) error {
+	ctx, cancel := a.graceContext(ctx)
+	defer cancel()
+
+	switch action.Type {
+	case HScaleAction:
+		return a.hScale(ctx, state.Policy, action.Replicas, state.Name, state.Namespace)
+	case VScaleAction:
+		resizes, err := a.vScaleTo(ctx, state.Policy, action.CpuRequests, action.MemRequests, workingSets(state), state.Name, state.Namespace)
+		dplan.Resizes = append(dplan.Resizes, resizes...)
+		return err
+	case MigratePodAction:
+		replacement, err := a.Metrics.MigratePod(ctx, a.Clientset, action.PodName, action.Node, state.Namespace, a.migrationTimeout())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🔄 Node migration: pod %s replaced by %s on node %s\n", action.PodName, replacement, action.Node)
+		return nil
+	default:
+		return fmt.Errorf("unknown action type %s", action.Type)
+	}
+}
+
+// runs the fallback for a vscale that failed with cause, which wraps util.ErrResizeInfeasible
+func (a *Autoscaler) resizeFallback(ctx context.Context, state DeploymentState, action ScaleAction, dplan *DeploymentPlan, cause error) error {
+	var resizeErr *ResizeError
+	if !errors.As(cause, &resizeErr) {
+		return cause
+	}
+
+	decision := PlanResizeFallback(state, action, len(resizeErr.Pods), resizeErr.Pod)
+	for _, line := range decision.Log {
+		fmt.Println(line)
+	}
+	if len(decision.Actions) == 0 {
+		return cause
+	}
+
+	dplan.Actions = append(dplan.Actions, decision.Actions...)
+	err := a.execute(ctx, state, decision.Actions, dplan)
+	if err != nil {
+		return fmt.Errorf("fallback after infeasible resize: %w", err)
+	}
+	return nil
+}
+
+// in-place scale all pods and then the template to the given per-pod CPU and memory requests
+// each pod's resize is waited on until the kubelet has applied it, returns every pod's outcome
+// a zero request leaves that resource as is, on failure everything already patched is put back (see ResizeError)
+// no container's memory goes below its largest observed working set (by container name)
+func (a *Autoscaler) vScaleTo(ctx context.Context, policy DeploymentPolicy, millis int64, memBytes int64, workingSets map[string]int64, deploymentName string, deploymentNamespace string) ([]PodResize, error) {
+	if millis > 0 {
+		millis = boundRequests(policy, millis)
+	}
+	if memBytes > 0 {
+		memBytes = boundMemory(policy, memBytes)
+	}
+
+	podList, err := a.Metrics.GetReadyPodListForDeployment(ctx, a.Clientset, deploymentName, deploymentNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := splitRequests(podList, policy.Sidecars, millis, memBytes, workingSets)
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no app containers found for deployment %s", deploymentName)
+	}
+
+	// read up front so the template can be put back too, nothing has changed if this fails
+	deployment, err := a.Metrics.GetDeployment(ctx, a.Clientset, deploymentName, deploymentNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template of deployment %s: %w", deploymentName, err)
+	}
+
+	applied := []appliedResize{}
+	outcomes := make([]PodResize, len(podList))
+	for i, pod := range podList {
+		outcomes[i] = PodResize{Pod: pod.Name, Outcome: PodUntouched}
+	}
+
+	// the template takes its current limits from the first pod that has the container
+	templates := map[string]v1.Container{}
+	for i, pod := range podList {
+		start := time.Now()
+		for _, container := range pod.Spec.Containers {
+			containerRequests, ok := requests[container.Name]
+			if !ok {
+				continue
+			}
+			if _, ok := templates[container.Name]; !ok {
+				templates[container.Name] = container
+			}
+
+			resources := containerResources(policy.CPULimit, containerRequests, container, false)
+			err = a.Metrics.VScale(ctx, a.Clientset, pod.Name, container.Name, resources, deploymentNamespace)
+			if err == nil {
+				// the spec changed even if the kubelet never applies it, so it is put back either way
+				applied = append(applied, appliedResize{pod: pod.Name, container: container.Name, previous: previousResources(container, resources, false)})
+				err = a.Metrics.WaitForResize(ctx, a.Clientset, pod.Name, container.Name, resources, deploymentNamespace, a.resizeTimeout())
+			}
+			if err != nil {
+				fmt.Printf("Failed to vscale container %s of pod %s: %s\n", container.Name, pod.Name, err.Error())
+				outcomes[i].Outcome, outcomes[i].Error = PodFailed, err.Error()
+				return outcomes, a.rollbackResize(ctx, deploymentName, deploymentNamespace, applied, outcomes, pod.Name, err)
+			}
+		}
+		took := time.Since(start)
+		outcomes[i].Outcome, outcomes[i].ApplyMillis = PodResized, took.Milliseconds()
+		fmt.Printf("⏱️ Pod %s resized in %s\n", pod.Name, took.Round(time.Millisecond))
+	}
+
+	current := map[string]v1.Container{}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		current[container.Name] = container
+	}
+	for _, name := range sortedKeys(requests) {
+		resources := containerResources(policy.CPULimit, requests[name], templates[name], true)
+		// logged once per vscale from the template patch, not per pod
+		if resources.Limits.CPU != "" {
+			fmt.Printf("📏 Limit: %s cpu limit -> %s (%s)\n", name, resources.Limits.CPU, policy.CPULimit)
+		}
+		if resources.Limits.Memory != "" {
+			fmt.Printf("📏 Limit: %s memory limit -> %s\n", name, resources.Limits.Memory)
+		}
+		err = a.Metrics.PatchDeploymentReqs(ctx, a.Clientset, deploymentName, name, resources, deploymentNamespace)
+		if err != nil {
+			fmt.Printf("Failed to vscale container %s of deployment %s: %s\n", name, deploymentName, err.Error())
+			return outcomes, a.rollbackResize(ctx, deploymentName, deploymentNamespace, applied, outcomes, "", err)
+		}
+		if container, ok := current[name]; ok {
+			applied = append(applied, appliedResize{container: name, previous: previousResources(container, resources, true)})
+		}
+	}
+
+	return outcomes, nil
+}
+
+// new requests for one container, 0 leaves that resource as is
+type containerRequests struct {
+	Millis int64
+	Bytes  int64
+}
+
+// the patch for one container - requests plus whatever limits keep them valid
+func containerResources(limitPolicy CPULimitPolicy, requests containerRequests, current v1.Container, template bool) util.VerticalPatchContainerResources {
+	resources := util.VerticalPatchContainerResources{}
+	if requests.Millis > 0 {
+		resources.Requests.CPU = fmt.Sprintf("%dm", requests.Millis)
+		resources.Limits.CPU = limitPolicy.LimitFor(requests.Millis, current.Resources.Limits.Cpu().MilliValue(), template)
+	}
+	if requests.Bytes > 0 {
+		resources.Requests.Memory = resource.NewQuantity(requests.Bytes, resource.BinarySI).String()
+		// memory limits are never managed, only raised so the request stays valid
+		currentLimit := current.Resources.Limits.Memory().Value()
+		if currentLimit > 0 && currentLimit < requests.Bytes {
+			resources.Limits.Memory = resources.Requests.Memory
+		}
+	}
+	return resources
+}
+
+// splits the per-pod requests across the app containers in proportion to their current requests
+// (summed over all pods), so every pod and the template get the same split
+// memory shares are clamped at each container's working set, which can take the pod above memBytes
+func splitRequests(podList []v1.Pod, sidecars []string, millis int64, memBytes int64, workingSets map[string]int64) map[string]containerRequests {
+	names := []string{}
+	cpuWeights := map[string]int64{}
+	memWeights := map[string]int64{}
+	for _, pod := range podList {
+		for _, container := range pod.Spec.Containers {
+			if util.IsSidecar(container.Name, sidecars) {
+				continue
+			}
+			if _, ok := cpuWeights[container.Name]; !ok {
+				names = append(names, container.Name)
+			}
+			cpuWeights[container.Name] += container.Resources.Requests.Cpu().MilliValue()
+			memWeights[container.Name] += container.Resources.Requests.Memory().Value()
+		}
+	}
+
+	cpuShares := splitProportionally(millis, names, cpuWeights)
+	memShares := map[string]int64{}
+	if memBytes > 0 {
+		memShares = splitWithFloors(memBytes, names, memWeights, workingSets)
+	}
+	requests := map[string]containerRequests{}
+	for _, name := range names {
+		requests[name] = containerRequests{Millis: cpuShares[name], Bytes: memShares[name]}
+	}
+	return requests
+}
+
+// shares sum to exactly total, evenly if no container has a weight
+func splitProportionally(total int64, names []string, weights map[string]int64) map[string]int64 {
+	shares := map[string]int64{}
+	if len(names) == 0 {
+		return shares
+	}
+
+	var sum int64
+	for _, name := range names {
+		sum += weights[name]
+	}
+
+	var assigned int64
+	for _, name := range names {
+		if sum == 0 {
+			shares[name] = total / int64(len(names))
+		} else {
+			shares[name] = int64(float64(total) * float64(weights[name]) / float64(sum))
+		}
+		assigned += shares[name]
+	}
+
+	// hand out the rounding remainder one unit at a time, heaviest container first
+	order := append([]string{}, names...)
+	sort.SliceStable(order, func(i, j int) bool { return weights[order[i]] > weights[order[j]] })
+	for i := 0; assigned < total; i++ {
+		shares[order[i%len(order)]]++
+		assigned++
+	}
+	return shares
+}
+
+// like splitProportionally, but a container whose share falls below its floor is held there
+// and the rest is split again over the others
+func splitWithFloors(total int64, names []string, weights map[string]int64, floors map[string]int64) map[string]int64 {
+	held := map[string]bool{}
+	for {
+		free := []string{}
+		remaining := total
+		for _, name := range names {
+			if held[name] {
+				remaining -= floors[name]
+			} else {
+				free = append(free, name)
+			}
+		}
+
+		shares := splitProportionally(remaining, free, weights)
+		clamped := false
+		for _, name := range free {
+			if shares[name] < floors[name] {
+				held[name], clamped = true, true
+			}
+		}
+		if clamped {
+			continue
+		}
+		for name := range held {
+			shares[name] = floors[name]
+		}
+		return shares
+	}
+}
+
+// largest working set of each app container over the deployment's pods
+func workingSets(state DeploymentState) map[string]int64 {
+	sets := map[string]int64{}
+	for _, pod := range state.Pods {
+		for _, container := range pod.Containers {
+			sets[container.Name] = max(sets[container.Name], container.MemUsage)
+		}
+	}
+	return sets
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// is blocking (see `hScaleFromHSR`)
+func (a *Autoscaler) hScale(ctx context.Context, policy DeploymentPolicy, idealReplicaCt int, deploymentName string, deploymentNamespace string) error {
+	idealReplicaCt = boundReplicas(policy, idealReplicaCt)
+	return a.Metrics.ChangeReplicaCount(ctx, deploymentNamespace, deploymentName, idealReplicaCt, a.Clientset)
+}
+
+// the planner already bounds its actions, these only guard other callers
+func boundReplicas(policy DeploymentPolicy, replicas int) int {
+	bounded, clipped := policy.ClampReplicas(replicas)
+	if clipped {
+		fmt.Printf("📏 Bound: %d replicas clipped to %d (min %d, max %d)\n", replicas, bounded, policy.MinReplicas, policy.MaxReplicas)
+	}
+	return bounded
+}
+
+func boundRequests(policy DeploymentPolicy, millis int64) int64 {
+	bounded, clipped := policy.ClampRequests(millis)
+	if clipped {
+		fmt.Printf("📏 Bound: %dm requests clipped to %dm (min %dm, max %dm)\n", millis, bounded, policy.MinCPU, policy.MaxCPU)
+	}
+	return bounded
+}
+
+func boundMemory(policy DeploymentPolicy, bytes int64) int64 {
+	bounded, clipped := policy.ClampMemory(bytes)
+	if clipped {
+		fmt.Printf("📏 Bound: %d bytes memory clipped to %d (min %d, max %d)\n", bytes, bounded, policy.MinMemory, policy.MaxMemory)
+	}
+	return bounded
+}
@@ -0,0 +1,155 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+/* --- FORECASTING ---
+ * additive holt-winters over each deployment's cpu utilization, one seasonal slot per FORECAST_SLOT of the season
+ * a forecast is only given once a whole season has been seen, and only ever adds capacity ahead of load (see Demand)
+ * history is kept in memory, a restart or failover starts the season over
+ */
+const (
+	FORECAST_SLOT  = time.Minute // one round
+	FORECAST_ALPHA = 0.5         // level smoothing
+	FORECAST_BETA  = 0.1         // trend smoothing
+	FORECAST_GAMMA = 0.3         // seasonal smoothing
+)
+
+type Forecaster struct {
+	Season time.Duration
+
+	start    time.Time // first observation
+	last     int64     // slot of the last observation
+	level    float64
+	trend    float64 // per slot
+	seasonal []float64
+	seen     []bool // seasonal slots observed at least once
+
+	predictions map[int64]float64 // slot -> what was forecast for it, to check once it is observed
+	errSum      float64           // absolute percentage errors of the forecasts checked so far
+	errCount    int
+}
+
+func NewForecaster(season time.Duration) *Forecaster {
+	slots := max(int(season/FORECAST_SLOT), 1)
+	return &Forecaster{Season: season, seasonal: make([]float64, slots), seen: make([]bool, slots), predictions: map[int64]float64{}}
+}
+
+func forecastSlot(t time.Time) int64 {
+	return t.UnixNano() / int64(FORECAST_SLOT)
+}
+
+func (f *Forecaster) seasonIndex(slot int64) int {
+	return int(slot % int64(len(f.seasonal)))
+}
+
+// adds one observation, returns what had been forecast for now if anything was
+// rounds that were missed only stretch the trend, a seasonal slot is learned the first time it is seen
+func (f *Forecaster) Observe(t time.Time, value float64) (float64, bool) {
+	slot := forecastSlot(t)
+	predicted, ok := f.predictions[slot]
+	for s := range f.predictions {
+		if s <= slot {
+			delete(f.predictions, s)
+		}
+	}
+	if ok && value > 0 {
+		f.errSum += math.Abs(value-predicted) / value
+		f.errCount++
+	}
+
+	i := f.seasonIndex(slot)
+	if f.start.IsZero() {
+		f.start, f.last, f.level = t, slot, value
+	}
+	if !f.seen[i] {
+		f.seasonal[i] = value - f.level
+		f.seen[i] = true
+	}
+
+	steps := float64(slot - f.last)
+	level := FORECAST_ALPHA*(value-f.seasonal[i]) + (1-FORECAST_ALPHA)*(f.level+steps*f.trend)
+	if steps > 0 {
+		f.trend = FORECAST_BETA*(level-f.level)/steps + (1-FORECAST_BETA)*f.trend
+	}
+	f.seasonal[i] = FORECAST_GAMMA*(value-level) + (1-FORECAST_GAMMA)*f.seasonal[i]
+	f.level, f.last = level, slot
+	return predicted, ok
+}
+
+// the highest value expected over the horizon after t, false until a whole season has been seen
+// the value expected at the end of the horizon is kept to be checked against what is observed then
+func (f *Forecaster) Forecast(t time.Time, horizon time.Duration) (float64, bool) {
+	if f.start.IsZero() || t.Sub(f.start) < f.Season {
+		return 0, false
+	}
+
+	slot := forecastSlot(t)
+	steps := max(int64(horizon/FORECAST_SLOT), 1)
+	peak := 0.0
+	for h := int64(1); h <= steps; h++ {
+		value := f.level + float64(h)*f.trend + f.seasonal[f.seasonIndex(slot+h)]
+		peak = max(peak, value)
+		if h == steps {
+			f.predictions[slot+h] = value
+		}
+	}
+	return peak, true
+}
+
+// mean absolute percentage error of the forecasts checked so far, in percent
+func (f *Forecaster) Error() (float64, int) {
+	if f.errCount == 0 {
+		return 0, 0
+	}
+	return f.errSum / float64(f.errCount) * 100, f.errCount
+}
+
+// feeds this round's utilization to the deployment's forecaster and returns what it expects within the horizon,
+// 0 if forecasting is off for the deployment or the forecaster has not seen a whole season yet
+// workers run deployments at once, so the forecasters are shared under forecastMu
+func (a *Autoscaler) forecast(state DeploymentState, now time.Time) int64 {
+	policy := state.Policy
+	if policy.ForecastWeight == 0 || len(state.Pods) == 0 {
+		return 0
+	}
+	key := fmt.Sprintf("%s/%s", state.Namespace, state.Name)
+
+	a.forecastMu.Lock()
+	defer a.forecastMu.Unlock()
+	if a.forecasters == nil {
+		a.forecasters = map[string]*Forecaster{}
+	}
+	f, ok := a.forecasters[key]
+	if !ok || f.Season != a.forecastSeason() {
+		f = NewForecaster(a.forecastSeason())
+		a.forecasters[key] = f
+	}
+
+	if predicted, ok := f.Observe(now, float64(state.Utilization)); ok {
+		mape, n := f.Error()
+		fmt.Printf("📈 Forecast error for %s: expected %.0f millicpus, observed %d (%.1f%% mean error over %d forecasts)\n", state.Name, predicted, state.Utilization, mape, n)
+	}
+
+	predicted, ready := f.Forecast(now, policy.ForecastHorizon)
+	if !ready {
+		return 0
+	}
+	return int64(math.Ceil(max(predicted, 0)))
+}
+
+// utilization to size for: the forecast blended in by the policy's weight, never below what is used now
+func (s DeploymentState) Demand() int64 {
+	if s.Forecast <= 0 {
+		return s.Utilization
+	}
+	w := s.Policy.ForecastWeight
+	blended := int64(math.Ceil((1-w)*float64(s.Utilization) + w*float64(s.Forecast)))
+	return max(s.Utilization, blended)
+}
@@ -0,0 +1,122 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+/* --- HPA INTEROP ---
+ * a deployment that also has a HorizontalPodAutoscaler would have two controllers writing spec.replicas, so one has to give way
+ *  refuse   - the deployment is left to the hpa and skipped
+ *  takeover - the hpa is switched off (see util.DisableHPA) and its min/max replicas become the policy's bounds
+ *  vertical - the hpa keeps replicas, only requests are scaled (the hpa then sees utilization against the new requests)
+ * the outcome is logged every round and recorded as an event on the deployment whenever it changes
+ */
+type HPAInteropMode string
+
+const (
+	RefuseHPAInterop   HPAInteropMode = "refuse"
+	TakeoverHPAInterop HPAInteropMode = "takeover"
+	VerticalHPAInterop HPAInteropMode = "vertical"
+)
+
+func ParseHPAInteropMode(value string) (HPAInteropMode, error) {
+	switch mode := HPAInteropMode(strings.TrimSpace(value)); mode {
+	case "", RefuseHPAInterop:
+		return RefuseHPAInterop, nil
+	case TakeoverHPAInterop, VerticalHPAInterop:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%q is not refuse, takeover or vertical", value)
+	}
+}
+
+var ErrHPAConflict = errors.New("deployment is scaled by a horizontal pod autoscaler")
+
+// event reasons
+const (
+	HPA_CONFLICT_EVENT      = "HPAConflict"
+	HPA_TAKEN_OVER_EVENT    = "HPATakenOver"
+	HPA_OWNS_REPLICAS_EVENT = "HPAOwnsReplicas"
+)
+
+// settles an hpa on the deployment per the policy's interop mode
+// returns the policy with any bounds taken over, and whether the replica count has to be left as is
+func (a *Autoscaler) interopHPA(ctx context.Context, deployment *appsv1.Deployment, policy DeploymentPolicy, dplan *DeploymentPlan) (DeploymentPolicy, bool, error) {
+	hpa, err := a.Metrics.GetHPAForDeployment(ctx, a.Clientset, deployment.Name, deployment.Namespace)
+	if err != nil {
+		return policy, false, err // not knowing is no reason to start fighting one
+	}
+	if hpa == nil {
+		a.reportHPA(ctx, deployment, "", v1.EventTypeNormal, "")
+		return policy, false, nil
+	}
+	dplan.HPAInterop = string(policy.HPAInterop)
+
+	switch policy.HPAInterop {
+	case TakeoverHPAInterop:
+		minReplicas := 1
+		if hpa.Spec.MinReplicas != nil {
+			minReplicas = int(*hpa.Spec.MinReplicas)
+		}
+		policy.MinReplicas, policy.MaxReplicas = minReplicas, int(hpa.Spec.MaxReplicas)
+		if !a.DryRun {
+			if err := a.Metrics.DisableHPA(ctx, a.Clientset, hpa); err != nil {
+				return policy, false, err
+			}
+		}
+		message := fmt.Sprintf("HPA %s disabled, its bounds of %d-%d replicas kept", hpa.Name, policy.MinReplicas, policy.MaxReplicas)
+		a.reportHPA(ctx, deployment, HPA_TAKEN_OVER_EVENT, v1.EventTypeNormal, message)
+		return policy, false, nil
+	case VerticalHPAInterop:
+		message := fmt.Sprintf("HPA %s owns replicas, only requests are scaled", hpa.Name)
+		a.reportHPA(ctx, deployment, HPA_OWNS_REPLICAS_EVENT, v1.EventTypeNormal, message)
+		return policy, true, nil
+	default:
+		message := fmt.Sprintf("HPA %s scales the deployment, not managed", hpa.Name)
+		a.reportHPA(ctx, deployment, HPA_CONFLICT_EVENT, v1.EventTypeWarning, message)
+		return policy, false, fmt.Errorf("%w: %s/%s is scaled by %s (hpa interop %s)", ErrHPAConflict, deployment.Namespace, deployment.Name, hpa.Name, policy.HPAInterop)
+	}
+}
+
+// logs the outcome, and records it as an event when it differs from the deployment's last one ("" reason for no hpa)
+// nothing is recorded in dry run
+func (a *Autoscaler) reportHPA(ctx context.Context, deployment *appsv1.Deployment, reason string, eventType string, message string) {
+	key := deployment.Namespace + "/" + deployment.Name
+	a.hpaMu.Lock()
+	if a.hpaOutcomes == nil {
+		a.hpaOutcomes = map[string]string{}
+	}
+	changed := a.hpaOutcomes[key] != reason+message
+	a.hpaOutcomes[key] = reason + message
+	a.hpaMu.Unlock()
+
+	if reason == "" {
+		return
+	}
+	if eventType == v1.EventTypeWarning {
+		fmt.Printf("⚠️ %s: %s\n", reason, message)
+	} else {
+		fmt.Printf("ℹ️ %s: %s\n", reason, message)
+	}
+	if !changed || a.DryRun {
+		return
+	}
+
+	object := v1.ObjectReference{Kind: DEPLOYMENT_KIND, APIVersion: "apps/v1", Namespace: deployment.Namespace, Name: deployment.Name, UID: deployment.UID}
+	err := a.Metrics.RecordEvent(ctx, a.Clientset, object, eventType, reason, message)
+	if err != nil {
+		fmt.Printf("❌ ERROR: %s\n", err.Error())
+		a.hpaMu.Lock()
+		delete(a.hpaOutcomes, key) // tried again next round
+		a.hpaMu.Unlock()
+	}
+}
@@ -4,8 +4,16 @@
 package autoscaler
 
 import (
+	"context"
+	"time"
+
+	"github.com/tholiang/podoscaler/scalers/api/v1alpha1"
+	util "github.com/tholiang/podoscaler/scalers/util"
+
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
 	kube_client "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	metrics_client "k8s.io/metrics/pkg/client/clientset/versioned"
@@ -15,24 +23,32 @@ type AutoscalerMetrics interface {
 	GetKubernetesConfig() (*rest.Config, error)
 	GetClientset(config *rest.Config) (*kube_client.Clientset, error)
 	GetMetricsClientset(config *rest.Config) (*metrics_client.Clientset, error)
-	GetNodeList(clientset kube_client.Interface) (*v1.NodeList, error)
-	GetUnschedulablePodListForDeployment(clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error)
-	GetReadyPodListForDeployment(clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error)
-	GetDeploymentUtilAndAlloc(clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod) (int64, int64, error)
-	GetNodeUsage(metricsClient *metrics_client.Clientset, nodeName string) (int64, error)
-	GetNodeAllocableAndCapacity(clientset kube_client.Interface, nodeName string) (int64, int64, error)
-	GetLatencyMetrics(client_set kube_client.Interface) (map[string]float64, error)
-	VScale(clientset kube_client.Interface, podname string, containername string, cpurequests string, namespace string) error
-	PatchDeploymentReqs(clientset kube_client.Interface, deploymentName string, containeridx int, cpurequests string, namespace string) error
-	ChangeReplicaCount(namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error
-	GetControlledDeployments(clientset kube_client.Interface) (*appsv1.DeploymentList, error)
-	DeletePod(clientset kube_client.Interface, podname string, namespace string) error
+	GetDynamicClient(config *rest.Config) (dynamic.Interface, error)
+	GetNodeList(ctx context.Context, clientset kube_client.Interface) (*v1.NodeList, error)
+	GetUnschedulablePodListForDeployment(ctx context.Context, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error)
+	GetReadyPodListForDeployment(ctx context.Context, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error)
+	GetDeploymentUtilAndAlloc(ctx context.Context, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod, sidecars []string) (int64, int64, error)
+	GetPodContainerUsage(ctx context.Context, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string) (map[string]map[string]util.ContainerUsage, error)
+	GetNodeUsage(ctx context.Context, metricsClient *metrics_client.Clientset, nodeName string) (int64, error)
+	GetNodeAllocableAndCapacity(ctx context.Context, clientset kube_client.Interface, nodeName string) (int64, int64, error)
+	GetNodeScheduling(ctx context.Context, clientset kube_client.Interface, nodeName string) (util.NodeScheduling, error)
+	GetLatencyMetrics(ctx context.Context, client_set kube_client.Interface, source LatencySource) (map[string]float64, error)
+	GetTrafficMetrics(ctx context.Context, client_set kube_client.Interface, source LatencySource, requestsQuery string, errorsQuery string) (util.Traffic, error)
+	VScale(ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string) error
+	WaitForResize(ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string, timeout time.Duration) error
+	PatchDeploymentReqs(ctx context.Context, clientset kube_client.Interface, deploymentName string, containername string, resources util.VerticalPatchContainerResources, namespace string) error
+	ChangeReplicaCount(ctx context.Context, namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error
+	GetPodoscalerPolicies(ctx context.Context, dynamicClient dynamic.Interface) (*v1alpha1.PodoscalerPolicyList, error)
+	UpdatePodoscalerPolicyStatus(ctx context.Context, dynamicClient dynamic.Interface, policy *v1alpha1.PodoscalerPolicy) error
+	GetDeployment(ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*appsv1.Deployment, error)
+	MigratePod(ctx context.Context, clientset kube_client.Interface, podname string, nodename string, namespace string, timeout time.Duration) (string, error)
+	GetHPAForDeployment(ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*autoscalingv2.HorizontalPodAutoscaler, error)
+	DisableHPA(ctx context.Context, clientset kube_client.Interface, hpa *autoscalingv2.HorizontalPodAutoscaler) error
+	RecordEvent(ctx context.Context, clientset kube_client.Interface, object v1.ObjectReference, eventType string, reason string, message string) error
 }
 
 type AutoscalerInterface interface {
 	Init() error
-	RunRound() error
-	isSLOViolated() bool
-	vScaleTo(millis int64) error
-	hScale(idealReplicaCt int) error
+	RunRound(ctx context.Context) error
+	LastPlan() RoundPlan
 }
@@ -4,19 +4,33 @@
 package autoscaler
 
 import (
+	"context"
 	"os"
+	"time"
 
+	"github.com/tholiang/podoscaler/scalers/api/v1alpha1"
 	util "github.com/tholiang/podoscaler/scalers/util"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	kube_client "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	metrics_client "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
-type DefaultAutoscalerMetrics struct{}
+type DefaultAutoscalerMetrics struct {
+	Cache *util.ClusterCache // nodes, pods and deployments are read from here when set, writes always go to the API server
+}
+
+func (m *DefaultAutoscalerMetrics) reader(clientset kube_client.Interface) util.ClusterReader {
+	if m.Cache != nil {
+		return m.Cache
+	}
+	return util.NewAPIReader(clientset)
+}
 
 func (m *DefaultAutoscalerMetrics) GetKubernetesConfig() (*rest.Config, error) {
 	return rest.InClusterConfig()
@@ -30,58 +44,115 @@ func (m *DefaultAutoscalerMetrics) GetMetricsClientset(config *rest.Config) (*me
 	return metrics_client.NewForConfig(config)
 }
 
-func (m *DefaultAutoscalerMetrics) GetNodeList(clientset kube_client.Interface) (*v1.NodeList, error) {
-	return util.GetNodeList(clientset)
+func (m *DefaultAutoscalerMetrics) GetDynamicClient(config *rest.Config) (dynamic.Interface, error) {
+	return util.GetDynamicClient(config)
+}
+
+func (m *DefaultAutoscalerMetrics) GetNodeList(ctx context.Context, clientset kube_client.Interface) (*v1.NodeList, error) {
+	return util.GetNodeList(ctx, m.reader(clientset))
+}
+
+func (m *DefaultAutoscalerMetrics) GetUnschedulablePodListForDeployment(ctx context.Context, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
+	return util.GetUnschedulablePodListForDeployment(ctx, m.reader(clientset), deploymentName, namespace)
 }
 
-func (m *DefaultAutoscalerMetrics) GetUnschedulablePodListForDeployment(clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
-	return util.GetUnschedulablePodListForDeployment(clientset, deploymentName, namespace)
+func (m *DefaultAutoscalerMetrics) GetDeploymentUtilAndAlloc(ctx context.Context, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod, sidecars []string) (int64, int64, error) {
+	return util.GetDeploymentUtilAndAlloc(ctx, m.reader(clientset), metricsClient, deploymentName, namespace, podList, sidecars)
 }
 
-func (m *DefaultAutoscalerMetrics) GetPodListForDeployment(clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
-	return util.GetReadyPodListForDeployment(clientset, deploymentName, namespace)
+func (m *DefaultAutoscalerMetrics) GetPodContainerUsage(ctx context.Context, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string) (map[string]map[string]util.ContainerUsage, error) {
+	return util.GetPodContainerUsage(ctx, m.reader(clientset), metricsClient, deploymentName, namespace)
 }
 
-func (m *DefaultAutoscalerMetrics) GetDeploymentUtilAndAlloc(clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod) (int64, int64, error) {
-	return util.GetDeploymentUtilAndAlloc(clientset, metricsClient, deploymentName, namespace, podList)
+func (m *DefaultAutoscalerMetrics) GetNodeUsage(ctx context.Context, metricsClient *metrics_client.Clientset, nodeName string) (int64, error) {
+	return util.GetNodeUsage(ctx, metricsClient, nodeName)
 }
 
-func (m *DefaultAutoscalerMetrics) GetNodeUsage(metricsClient *metrics_client.Clientset, nodeName string) (int64, error) {
-	return util.GetNodeUsage(metricsClient, nodeName)
+func (m *DefaultAutoscalerMetrics) GetNodeAllocableAndCapacity(ctx context.Context, clientset kube_client.Interface, nodeName string) (int64, int64, error) {
+	return util.GetNodeAllocableAndCapacity(ctx, m.reader(clientset), nodeName)
 }
 
-func (m *DefaultAutoscalerMetrics) GetNodeAllocableAndCapacity(clientset kube_client.Interface, nodeName string) (int64, int64, error) {
-	return util.GetNodeAllocableAndCapacity(clientset, nodeName)
+func (m *DefaultAutoscalerMetrics) GetNodeScheduling(ctx context.Context, clientset kube_client.Interface, nodeName string) (util.NodeScheduling, error) {
+	return util.GetNodeScheduling(ctx, m.reader(clientset), nodeName)
 }
 
-func (m *DefaultAutoscalerMetrics) GetLatencyMetrics(clientset kube_client.Interface) (map[string]float64, error) {
-	lb_name, err := util.GetLoadBalancerName(clientset, os.Getenv("AUTOSCALE_NAMESPACE"), os.Getenv("AUTOSCALE_LB"))
+func (m *DefaultAutoscalerMetrics) GetLatencyMetrics(ctx context.Context, clientset kube_client.Interface, source LatencySource) (map[string]float64, error) {
+	namespace, service := os.Getenv("AUTOSCALE_NAMESPACE"), os.Getenv("AUTOSCALE_LB")
+	switch source.Type {
+	case PromQLLatencySource:
+		return util.GetLatencyPrometheus(ctx, source.Query)
+	case ServiceLatencySource:
+		namespace, service = source.Namespace, source.Name
+	}
+
+	lb_name, err := util.GetLoadBalancerName(ctx, clientset, namespace, service)
 	if err != nil {
 		return nil, err
 	}
-	return util.GetLatencyCloudwatch(lb_name)
+	return util.GetLatencyCloudwatch(ctx, lb_name)
+}
+
+// read from the same place as latency, a promql source needs its own queries for it
+func (m *DefaultAutoscalerMetrics) GetTrafficMetrics(ctx context.Context, clientset kube_client.Interface, source LatencySource, requestsQuery string, errorsQuery string) (util.Traffic, error) {
+	namespace, service := os.Getenv("AUTOSCALE_NAMESPACE"), os.Getenv("AUTOSCALE_LB")
+	switch source.Type {
+	case PromQLLatencySource:
+		return util.GetTrafficPrometheus(ctx, requestsQuery, errorsQuery)
+	case ServiceLatencySource:
+		namespace, service = source.Namespace, source.Name
+	}
+
+	lb_name, err := util.GetLoadBalancerName(ctx, clientset, namespace, service)
+	if err != nil {
+		return util.Traffic{}, err
+	}
+	return util.GetTrafficCloudwatch(ctx, lb_name)
+}
+
+func (m *DefaultAutoscalerMetrics) VScale(ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string) error {
+	return util.VScale(ctx, clientset, podname, containername, resources, namespace)
+}
+
+func (m *DefaultAutoscalerMetrics) WaitForResize(ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string, timeout time.Duration) error {
+	return util.WaitForResize(ctx, clientset, podname, containername, resources, namespace, timeout)
+}
+
+func (m *DefaultAutoscalerMetrics) PatchDeploymentReqs(ctx context.Context, clientset kube_client.Interface, deploymentName string, containername string, resources util.VerticalPatchContainerResources, namespace string) error {
+	return util.PatchDeploymentReqs(ctx, clientset, deploymentName, containername, resources, namespace)
+}
+
+func (m *DefaultAutoscalerMetrics) ChangeReplicaCount(ctx context.Context, namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error {
+	return util.ChangeReplicaCount(ctx, namespace, deploymentName, replicaCt, clientset)
+}
+
+func (m *DefaultAutoscalerMetrics) GetPodoscalerPolicies(ctx context.Context, dynamicClient dynamic.Interface) (*v1alpha1.PodoscalerPolicyList, error) {
+	return util.ListPodoscalerPolicies(ctx, dynamicClient)
+}
+
+func (m *DefaultAutoscalerMetrics) UpdatePodoscalerPolicyStatus(ctx context.Context, dynamicClient dynamic.Interface, policy *v1alpha1.PodoscalerPolicy) error {
+	return util.PatchPodoscalerPolicyStatus(ctx, dynamicClient, policy.Namespace, policy.Name, policy.Status)
 }
 
-func (m *DefaultAutoscalerMetrics) VScale(clientset kube_client.Interface, podname string, containername string, cpurequests string, namespace string) error {
-	return util.VScale(clientset, podname, containername, cpurequests, namespace)
+func (m *DefaultAutoscalerMetrics) GetDeployment(ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*appsv1.Deployment, error) {
+	return util.GetDeployment(ctx, m.reader(clientset), deploymentName, namespace)
 }
 
-func (m *DefaultAutoscalerMetrics) PatchDeploymentReqs(clientset kube_client.Interface, deploymentName string, containeridx int, cpurequests string, namespace string) error {
-	return util.PatchDeploymentReqs(clientset, deploymentName, containeridx, cpurequests, namespace)
+func (m *DefaultAutoscalerMetrics) MigratePod(ctx context.Context, clientset kube_client.Interface, podname string, nodename string, namespace string, timeout time.Duration) (string, error) {
+	return util.MigratePod(ctx, clientset, podname, nodename, namespace, timeout)
 }
 
-func (m *DefaultAutoscalerMetrics) ChangeReplicaCount(namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error {
-	return util.ChangeReplicaCount(namespace, deploymentName, replicaCt, clientset)
+func (m *DefaultAutoscalerMetrics) GetHPAForDeployment(ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	return util.FindHPA(ctx, clientset, deploymentName, namespace)
 }
 
-func (m *DefaultAutoscalerMetrics) GetControlledDeployments(clientset kube_client.Interface) (*appsv1.DeploymentList, error) {
-	return util.GetControlledDeployments(clientset)
+func (m *DefaultAutoscalerMetrics) DisableHPA(ctx context.Context, clientset kube_client.Interface, hpa *autoscalingv2.HorizontalPodAutoscaler) error {
+	return util.DisableHPA(ctx, clientset, hpa)
 }
 
-func (m *DefaultAutoscalerMetrics) DeletePod(clientset kube_client.Interface, podname string, namespace string) error {
-	return util.DeletePod(clientset, podname, namespace)
+func (m *DefaultAutoscalerMetrics) RecordEvent(ctx context.Context, clientset kube_client.Interface, object v1.ObjectReference, eventType string, reason string, message string) error {
+	return util.RecordEvent(ctx, clientset, object, eventType, reason, message)
 }
 
-func (m *DefaultAutoscalerMetrics) GetReadyPodListForDeployment(clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
-	return util.GetReadyPodListForDeployment(clientset, deploymentName, namespace)
+func (m *DefaultAutoscalerMetrics) GetReadyPodListForDeployment(ctx context.Context, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
+	return util.GetReadyPodListForDeployment(ctx, m.reader(clientset), deploymentName, namespace)
 }
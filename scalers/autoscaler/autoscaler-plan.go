@@ -0,0 +1,96 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+/* --- ROUND PLAN ---
+ * every write the autoscaler makes (or would make, in dry run) is recorded here
+ * one RoundPlan is emitted per round as a single json line so rounds can be diffed
+ */
+type ScaleActionType string
+
+const (
+	HScaleAction     ScaleActionType = "hscale"
+	VScaleAction     ScaleActionType = "vscale"
+	MigratePodAction ScaleActionType = "migrate"
+)
+
+// one typed step of a deployment's plan, produced by PlanDeployment and applied by the executor
+type ScaleAction struct {
+	Type        ScaleActionType `json:"type"`
+	Replicas    int             `json:"replicas,omitempty"`    // hscale
+	CpuRequests int64           `json:"cpuRequests,omitempty"` // vscale, in millicpus, 0 leaves cpu as is
+	MemRequests int64           `json:"memRequests,omitempty"` // vscale, in bytes, 0 leaves memory as is
+	PodName     string          `json:"pod,omitempty"`         // migrate
+	Node        string          `json:"node,omitempty"`        // migrate, the node the pod is moved to
+	Reason      string          `json:"reason,omitempty"`
+	Migration   bool            `json:"migration,omitempty"` // part of a node migration, run while no other deployment migrates
+	Fallback    bool            `json:"fallback,omitempty"`  // added after an infeasible resize, never falls back again
+}
+
+type DeploymentPlan struct {
+	Namespace     string             `json:"namespace"`
+	Name          string             `json:"name"`
+	ScalingPolicy string             `json:"scalingPolicy,omitempty"` // the ScalingPolicy that planned the deployment
+	HPAInterop    string             `json:"hpaInterop,omitempty"`    // how an hpa on the deployment was handled, unset without one
+	Replicas      int                `json:"replicas"`                // ready pods at the start of the round
+	CpuRequests   int64              `json:"cpuRequests"`             // per pod, in millicpus
+	Utilization   int64              `json:"utilization"`             // in millicpus
+	Forecast      int64              `json:"forecast,omitempty"`      // expected utilization within the forecast horizon, in millicpus
+	ControlTarget int64              `json:"controlTarget,omitempty"` // total cpu the latency controller asked for, in millicpus
+	Allocation    int64              `json:"allocation"`              // in millicpus
+	MemRequests   int64              `json:"memRequests"`             // per pod, in bytes
+	MemUsage      int64              `json:"memUsage"`                // working set, in bytes
+	Latency       *float64           `json:"latency,omitempty"`       // p99, in milliseconds, unset if it could not be read
+	Latencies     map[string]float64 `json:"latencies,omitempty"`     // by percentile, in milliseconds
+	SLOScore      float64            `json:"sloScore,omitempty"`      // latency over threshold combined across the slo's targets, or 5xx ratio over the error budget if worse, over 1 is a violation
+	RequestRate   float64            `json:"requestRate,omitempty"`   // per second
+	ErrorRate     float64            `json:"errorRate,omitempty"`     // 5xx responses per second
+	SLOViolated   bool               `json:"sloViolated"`             // false if the slo could not be read
+	Actions       []ScaleAction      `json:"actions"`                 // in the order they were (or would be) applied
+	Error         string             `json:"error,omitempty"`         // why the deployment was skipped or stopped early
+	Resizes       []PodResize        `json:"resizes,omitempty"`       // per-pod outcome of every vscale, in order
+
+	observed bool // the fields above were read this round
+}
+
+type RoundPlan struct {
+	Time        time.Time        `json:"time"`
+	DryRun      bool             `json:"dryRun"`
+	Deployments []DeploymentPlan `json:"deployments"`
+}
+
+func (a *Autoscaler) LastPlan() RoundPlan {
+	return a.plan
+}
+
+func (a *Autoscaler) startPlan() {
+	a.plan = RoundPlan{Time: time.Now(), DryRun: a.DryRun, Deployments: []DeploymentPlan{}}
+}
+
+// each worker fills its own entry, they are added to the round plan in policy order once all are done
+func newDeploymentPlan(deploymentName string, deploymentNamespace string) DeploymentPlan {
+	return DeploymentPlan{Namespace: deploymentNamespace, Name: deploymentName, Actions: []ScaleAction{}}
+}
+
+func (a *Autoscaler) writePlan() {
+	if a.PlanWriter == nil {
+		return
+	}
+
+	b, err := json.Marshal(a.plan)
+	if err != nil {
+		fmt.Printf("❌ ERROR: Failed to encode round plan: %s\n", err.Error())
+		return
+	}
+	_, err = a.PlanWriter.Write(append(b, '\n'))
+	if err != nil {
+		fmt.Printf("❌ ERROR: Failed to write round plan: %s\n", err.Error())
+	}
+}
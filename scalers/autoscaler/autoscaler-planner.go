@@ -0,0 +1,424 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"fmt"
+	"math"
+
+	util "github.com/tholiang/podoscaler/scalers/util"
+	v1 "k8s.io/api/core/v1"
+)
+
+/* --- PLANNER ---
+ * pure scaling decision: takes a snapshot of one deployment and returns the ordered actions to take
+ * nothing in here talks to the cluster, see autoscaler-executor.go for that
+ */
+type ContainerState struct {
+	Name        string
+	CpuRequests int64 // in millicpus
+	CpuUsage    int64 // in millicpus
+	MemRequests int64 // in bytes, 0 if unset
+	MemUsage    int64 // working set in bytes
+	OOMKilled   bool  // OOMKilled within OOM_LOOKBACK
+}
+
+type PodState struct {
+	Name       string
+	NodeName   string
+	Containers []ContainerState // app containers only, sidecars are left out
+
+	// totals over the app containers - the planner sizes pods, the executor splits them
+	CpuRequests int64 // in millicpus
+	CpuUsage    int64 // in millicpus
+	MemRequests int64 // in bytes
+	MemUsage    int64 // in bytes
+	OOMKilled   bool  // any app container
+}
+
+func NewPodState(name string, nodeName string, containers []ContainerState) PodState {
+	pod := PodState{Name: name, NodeName: nodeName, Containers: containers}
+	for _, c := range containers {
+		pod.CpuRequests += c.CpuRequests
+		pod.CpuUsage += c.CpuUsage
+		pod.MemRequests += c.MemRequests
+		pod.MemUsage += c.MemUsage
+		pod.OOMKilled = pod.OOMKilled || c.OOMKilled
+	}
+	return pod
+}
+
+type NodeState struct {
+	Name      string
+	Usage     int64 // in millicpus
+	Allocable int64 // unrequested allocatable millicpus
+	Capacity  int64 // in millicpus
+
+	// for the scheduling simulation (see Simulate)
+	MemAllocable  int64 // unrequested allocatable memory, in bytes
+	Labels        map[string]string
+	Taints        []v1.Taint
+	Unschedulable bool // cordoned, no new pods
+}
+
+// cpu a pod placed here could get, neither in use nor requested
+func (n NodeState) Available() int64 {
+	return min(n.Capacity-n.Usage, n.Allocable)
+}
+
+// snapshot of a deployment at the start of its turn in the round
+type DeploymentState struct {
+	Name          string
+	Namespace     string
+	Policy        DeploymentPolicy
+	Utilization   int64 // in millicpus
+	Forecast      int64 // highest utilization expected within the policy's forecast horizon, in millicpus, 0 if none
+	ControlTarget int64 // total cpu the latency controller asks for, in millicpus, 0 outside controller mode
+	Allocation    int64 // in millicpus
+	MemUsage      int64 // working set in bytes
+	MemAllocation int64 // in bytes
+	Pods          []PodState
+	Nodes         map[string]NodeState // nodes hosting the pods and every other schedulable node, by name
+	NodeSelector  map[string]string    // from the pod template
+	Tolerations   []v1.Toleration      // from the pod template
+	Latencies     map[string]float64   // by percentile, in milliseconds, empty if there were no datapoints
+	LatencyErr    error                // set if latency could not be read
+	Traffic       util.Traffic         // requests and 5xx per second
+	TrafficErr    error                // set if traffic could not be read
+}
+
+type DeploymentDecision struct {
+	Actions []ScaleAction // in the order they should be applied
+	Log     []string      // why, for the round log
+}
+
+func (d *DeploymentDecision) logf(format string, args ...any) {
+	d.Log = append(d.Log, fmt.Sprintf(format, args...))
+}
+
+func (d *DeploymentDecision) add(action ScaleAction) {
+	d.Actions = append(d.Actions, action)
+}
+
+func (s DeploymentState) PerPodAllocation() int64 {
+	if len(s.Pods) == 0 {
+		return 0
+	}
+	return int64(math.Ceil(float64(s.Allocation) / float64(len(s.Pods))))
+}
+
+func (s DeploymentState) PerPodMemAllocation() int64 {
+	if len(s.Pods) == 0 {
+		return 0
+	}
+	return int64(math.Ceil(float64(s.MemAllocation) / float64(len(s.Pods))))
+}
+
+// cpu decides replicas and cpu requests, by rules or the latency controller, memory then rides on the last vscale
+func PlanDeployment(state DeploymentState) DeploymentDecision {
+	decision := DeploymentDecision{Actions: []ScaleAction{}}
+
+	if len(state.Pods) == 0 || (state.Allocation == 0 && state.MemAllocation == 0) {
+		decision.logf("ℹ️ No ready pods with requests - no action taken")
+		return decision
+	}
+
+	switch {
+	case state.Allocation > 0 && state.ControlTarget > 0:
+		planController(state, &decision)
+	case state.Allocation > 0:
+		if state.Policy.Mode == ControllerScalingMode {
+			decision.logf("⚠️ No latency for the controller - scaling %s by rules this round", state.Name)
+		}
+		planCPU(state, &decision)
+	}
+	if state.MemAllocation > 0 {
+		planMemory(state, &decision)
+	}
+	planPlacement(state, &decision)
+	return decision
+}
+
+func planCPU(state DeploymentState, decision *DeploymentDecision) {
+	policy := state.Policy
+
+	utilPercent := float64(state.Utilization) / float64(state.Allocation)
+	decision.logf("📊 Current state: %d/%d millicpus (%.1f%%)", state.Utilization, state.Allocation, utilPercent*100)
+	if state.LatencyErr == nil {
+		decision.logf("📊 Latency metrics (%s): %s (slo score %.2f)", policy.LatencySource, state.describeSLO(), state.SLOScore())
+	}
+	if policy.AvailabilityTarget > 0 && state.TrafficErr == nil {
+		decision.logf("📊 Traffic: %.1f req/s, %.2f%% 5xx (availability target %.2f%%)", state.Traffic.Requests, state.Traffic.ErrorRatio()*100, policy.AvailabilityTarget*100)
+	}
+
+	// sized for the forecast blended in, which is just the utilization while forecasting is off or still learning
+	demand := state.Demand()
+	if state.Forecast > 0 {
+		decision.logf("📈 Forecast: %d millicpus within %s, sizing for %d millicpus", state.Forecast, policy.ForecastHorizon, demand)
+	}
+
+	numPods := len(state.Pods)
+	perpodalloc := state.PerPodAllocation()
+
+	idealReplicaCt := int(math.Ceil(float64(demand) / float64(policy.Maps)))
+	idealReplicaCt = decision.boundReplicas(policy, idealReplicaCt)
+	newRequests := int64(math.Ceil(float64(demand) / float64(idealReplicaCt)))
+	newRequests = decision.boundRequests(policy, newRequests)
+
+	slovio := state.SLOViolated()
+	unread := state.SLOUnread()
+	if (slovio || unread) && utilPercent > 1 {
+		decision.logf("⚠️ SLO violation detected for %s", state.Name)
+		// hscale
+		if idealReplicaCt > numPods { // hscale first (total increase) then vscale (possible decrease)
+			decision.logf("🔄 Horizontal scaling: %d -> %d replicas", numPods, idealReplicaCt)
+			decision.add(ScaleAction{Type: HScaleAction, Replicas: idealReplicaCt, Reason: "slo violation, more replicas needed"})
+			decision.logf("🔄 Vertical scaling: %d -> %d millicpus", perpodalloc, newRequests)
+			decision.add(ScaleAction{Type: VScaleAction, CpuRequests: newRequests, Reason: "resize after hscale"})
+			return
+		} else if idealReplicaCt < numPods { // vscale first (total increase) then hscale (decrease)
+			decision.logf("🔄 Vertical scaling: %d -> %d millicpus", perpodalloc, newRequests)
+			decision.add(ScaleAction{Type: VScaleAction, CpuRequests: newRequests, Reason: "slo violation, fewer larger replicas"})
+			decision.logf("🔄 Horizontal scaling: %d -> %d replicas", numPods, idealReplicaCt)
+			decision.add(ScaleAction{Type: HScaleAction, Replicas: idealReplicaCt, Reason: "slo violation, fewer larger replicas"})
+			// have to vscale new pods again
+			decision.logf("🔄 Vertical scaling: %d -> %d millicpus", perpodalloc, newRequests)
+			decision.add(ScaleAction{Type: VScaleAction, CpuRequests: newRequests, Reason: "resize after hscale"})
+			return
+		}
+
+		// vscale
+		if newRequests < perpodalloc {
+			decision.logf("ℹ️ New requests (%d) < per pod alloc (%d) - no action taken", newRequests, perpodalloc)
+			return
+		}
+
+		hasNoCongested := true
+		reserved := map[string]int64{} // cpu on each node already counted on by earlier migrations in this plan
+		for _, pod := range state.Pods {
+			node, ok := state.Nodes[pod.NodeName]
+			if !ok {
+				decision.logf("❌ ERROR: No node metrics for pod %s on node %s", pod.Name, pod.NodeName)
+				continue
+			}
+
+			availablePercentage := float64(node.Available()) / float64(node.Capacity)
+			if availablePercentage > policy.MinNodeAvailabilityThreshold {
+				continue
+			}
+			hasNoCongested = false
+
+			additionalAllocation := newRequests - pod.CpuRequests
+			if additionalAllocation > node.Allocable {
+				// the replacement runs next to the pod until it is ready, which must still fit under the bound
+				if policy.MaxReplicas > 0 && idealReplicaCt+1 > policy.MaxReplicas {
+					decision.logf("📏 Bound: migrating pod %s needs %d replicas (max %d) - not moved", pod.Name, idealReplicaCt+1, policy.MaxReplicas)
+					decision.logf("❌ ERROR: Failed to move pod for deployment %s - assuming no available node space", state.Name)
+					decision.Actions = []ScaleAction{}
+					return
+				}
+				target, ok := pickTargetNode(state, pod.NodeName, newRequests, reserved)
+				if !ok {
+					decision.logf("❌ ERROR: No node has room for pod %s at %d millicpus - not moved", pod.Name, newRequests)
+					decision.logf("❌ ERROR: Failed to move pod for deployment %s - assuming no available node space", state.Name)
+					decision.Actions = []ScaleAction{}
+					return
+				}
+				reserved[target] += newRequests
+				decision.logf("🔄 Node migration: Moving pod %s to node %s", pod.Name, target)
+				reason := fmt.Sprintf("move %s off congested node %s", pod.Name, pod.NodeName)
+				decision.add(ScaleAction{Type: MigratePodAction, PodName: pod.Name, Node: target, Reason: reason, Migration: true})
+			}
+		}
+
+		if hasNoCongested {
+			decision.logf("ℹ️ External bottleneck detected for %s - no action taken", state.Name)
+			return
+		}
+		decision.logf("🔄 Vertical scaling: %d -> %d millicpus", perpodalloc, newRequests)
+		decision.add(ScaleAction{Type: VScaleAction, CpuRequests: newRequests, Reason: "slo violation on congested node"})
+	} else if !slovio && !unread && state.Forecast > 0 && demand > state.Utilization && demand > state.Allocation {
+		// pre-scale ahead of the forecast load, the reactive rules handle what is used now
+		if idealReplicaCt > numPods {
+			decision.logf("🔄 Horizontal scaling: %d -> %d replicas", numPods, idealReplicaCt)
+			decision.add(ScaleAction{Type: HScaleAction, Replicas: idealReplicaCt, Reason: "forecast load"})
+			decision.logf("🔄 Vertical scaling: %d -> %d millicpus", perpodalloc, newRequests)
+			decision.add(ScaleAction{Type: VScaleAction, CpuRequests: newRequests, Reason: "resize after hscale"})
+		} else if newRequests > perpodalloc {
+			decision.logf("🔄 Vertical scaling: %d -> %d millicpus", perpodalloc, newRequests)
+			decision.add(ScaleAction{Type: VScaleAction, CpuRequests: newRequests, Reason: "forecast load"})
+		}
+	} else if !slovio && !unread && utilPercent < policy.DownscaleUtilizationThreshold {
+		if idealReplicaCt < numPods {
+			decision.logf("🔄 Downscaling: %d -> %d replicas", numPods, idealReplicaCt)
+			decision.add(ScaleAction{Type: HScaleAction, Replicas: idealReplicaCt, Reason: "underutilized"})
+		}
+
+		hysteresisMargin := 1 / policy.DownscaleUtilizationThreshold
+		newRequests = int64(math.Ceil(float64(newRequests) * hysteresisMargin))
+		newRequests = decision.boundRequests(policy, newRequests)
+		if newRequests == perpodalloc {
+			return
+		}
+
+		decision.logf("🔄 Downscaling: %d -> %d millicpus", perpodalloc, newRequests)
+		decision.add(ScaleAction{Type: VScaleAction, CpuRequests: newRequests, Reason: "underutilized"})
+	}
+
+}
+
+// grow on OOMKill or pressure, shrink with hysteresis, never below the largest working set
+func planMemory(state DeploymentState, decision *DeploymentDecision) {
+	policy := state.Policy
+	if state.MemUsage == 0 {
+		decision.logf("ℹ️ No memory metrics for %s - memory left as is", state.Name)
+		return
+	}
+
+	perpodmem := state.PerPodMemAllocation()
+	var maxWorkingSet int64
+	oomKilled, pressured := false, false
+	for _, pod := range state.Pods {
+		maxWorkingSet = max(maxWorkingSet, pod.MemUsage)
+		if pod.OOMKilled {
+			decision.logf("⚠️ Pod %s was OOMKilled", pod.Name)
+			oomKilled = true
+		}
+		if pod.MemRequests > 0 && float64(pod.MemUsage)/float64(pod.MemRequests) > policy.MemoryPressureThreshold {
+			pressured = true
+		}
+	}
+
+	memPercent := float64(state.MemUsage) / float64(state.MemAllocation)
+	decision.logf("📊 Memory: %d/%d bytes (%.1f%%), largest working set %d bytes", state.MemUsage, state.MemAllocation, memPercent*100, maxWorkingSet)
+
+	target := int64(math.Ceil(float64(maxWorkingSet) / MEMORY_TARGET_UTILIZATION))
+	var newMem int64
+	var reason string
+	if oomKilled || pressured {
+		reason = "memory pressure"
+		if oomKilled {
+			reason = "oomkilled"
+		}
+		newMem = max(int64(math.Ceil(float64(perpodmem)*MEMORY_GROWTH_FACTOR)), target)
+		newMem = decision.boundMemory(policy, newMem)
+		if newMem <= perpodmem {
+			decision.logf("⚠️ Memory for %s already at max (%d bytes) - no action taken", state.Name, perpodmem)
+			return
+		}
+	} else if memPercent < policy.MemoryDownscaleThreshold {
+		reason = "memory underutilized"
+		newMem = decision.boundMemory(policy, target)
+		if newMem >= perpodmem {
+			return
+		}
+	} else {
+		return
+	}
+
+	decision.logf("🔄 Vertical scaling: %d -> %d bytes memory", perpodmem, newMem)
+	// the last vscale also patches the template, so memory goes with it
+	if n := len(decision.Actions); n > 0 && decision.Actions[n-1].Type == VScaleAction {
+		decision.Actions[n-1].MemRequests = newMem
+		decision.Actions[n-1].Reason += ", " + reason
+		return
+	}
+	decision.add(ScaleAction{Type: VScaleAction, MemRequests: newMem, Reason: reason})
+}
+
+// an in-place resize the kubelet called infeasible does not fit on the pod's node
+// if another node has room the pod is moved there (see MigratePodAction) and the resize retried,
+// otherwise replicas are added at the current size to make up the cpu the resize would have given
+// replicas is how many pods the failed resize covered, podName the one it failed on
+func PlanResizeFallback(state DeploymentState, action ScaleAction, replicas int, podName string) DeploymentDecision {
+	policy := state.Policy
+	decision := DeploymentDecision{Actions: []ScaleAction{}}
+	decision.logf("⚠️ Resize of pod %s is infeasible on its node", podName)
+
+	nodeName := ""
+	for _, pod := range state.Pods {
+		if pod.Name == podName {
+			nodeName = pod.NodeName
+		}
+	}
+	target, roomElsewhere := pickTargetNode(state, nodeName, action.CpuRequests, nil)
+
+	if podName != "" && roomElsewhere && (policy.MaxReplicas == 0 || replicas+1 <= policy.MaxReplicas) {
+		decision.logf("🔄 Node migration: Moving pod %s to node %s before resizing again", podName, target)
+		reason := fmt.Sprintf("move %s, resize infeasible on node %s", podName, nodeName)
+		decision.add(ScaleAction{Type: MigratePodAction, PodName: podName, Node: target, Reason: reason, Migration: true, Fallback: true})
+		retry := action
+		retry.Reason = "retry after moving " + podName
+		retry.Fallback = true
+		decision.add(retry)
+		return decision
+	}
+
+	perpodalloc := state.PerPodAllocation()
+	if perpodalloc == 0 || action.CpuRequests <= perpodalloc {
+		decision.logf("❌ ERROR: No node has room for pod %s and the resize adds no cpu - no fallback", podName)
+		return decision
+	}
+	idealReplicaCt := int(math.Ceil(float64(int64(replicas)*action.CpuRequests) / float64(perpodalloc)))
+	idealReplicaCt = decision.boundReplicas(policy, idealReplicaCt)
+	// only as many as the nodes can take
+	for idealReplicaCt > replicas && state.Simulate(idealReplicaCt, perpodalloc, state.PerPodMemAllocation()).Unplaced > 0 {
+		idealReplicaCt--
+	}
+	if idealReplicaCt <= replicas {
+		decision.logf("❌ ERROR: No node has room for pod %s and no replicas can be added or placed - no fallback", podName)
+		return decision
+	}
+	decision.logf("🔄 Horizontal scaling: %d -> %d replicas instead of resizing", replicas, idealReplicaCt)
+	decision.add(ScaleAction{Type: HScaleAction, Replicas: idealReplicaCt, Reason: "resize infeasible, more replicas instead", Fallback: true})
+	return decision
+}
+
+// the node, other than from, with the most cpu left once a pod with requests is placed on it
+// congested nodes and nodes the pods may not land on are passed over,
+// reserved is what earlier migrations in the same plan already count on
+func pickTargetNode(state DeploymentState, from string, requests int64, reserved map[string]int64) (string, bool) {
+	target, most := "", int64(-1)
+	for _, name := range sortedKeys(state.Nodes) {
+		node := state.Nodes[name]
+		if name == from || node.Capacity == 0 || !state.Schedulable(node) {
+			continue
+		}
+		if float64(node.Available())/float64(node.Capacity) <= state.Policy.MinNodeAvailabilityThreshold {
+			continue
+		}
+		left := node.Available() - reserved[name] - requests
+		if left >= 0 && left > most {
+			target, most = name, left
+		}
+	}
+	return target, target != ""
+}
+
+// clamp to the policy's replica bounds, logging any clipped recommendation
+func (d *DeploymentDecision) boundReplicas(policy DeploymentPolicy, replicas int) int {
+	bounded, clipped := policy.ClampReplicas(replicas)
+	if clipped {
+		d.logf("📏 Bound: %d replicas clipped to %d (min %d, max %d)", replicas, bounded, policy.MinReplicas, policy.MaxReplicas)
+	}
+	return bounded
+}
+
+// clamp to the policy's CPU request bounds, logging any clipped recommendation
+func (d *DeploymentDecision) boundRequests(policy DeploymentPolicy, millis int64) int64 {
+	bounded, clipped := policy.ClampRequests(millis)
+	if clipped {
+		d.logf("📏 Bound: %dm requests clipped to %dm (min %dm, max %dm)", millis, bounded, policy.MinCPU, policy.MaxCPU)
+	}
+	return bounded
+}
+
+// clamp to the policy's memory request bounds, logging any clipped recommendation
+func (d *DeploymentDecision) boundMemory(policy DeploymentPolicy, bytes int64) int64 {
+	bounded, clipped := policy.ClampMemory(bytes)
+	if clipped {
+		d.logf("📏 Bound: %d bytes memory clipped to %d (min %d, max %d)", bytes, bounded, policy.MinMemory, policy.MaxMemory)
+	}
+	return bounded
+}
@@ -0,0 +1,737 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tholiang/podoscaler/scalers/api/v1alpha1"
+	util "github.com/tholiang/podoscaler/scalers/util"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+/* --- PER-DEPLOYMENT ANNOTATIONS --- */
+const ANNOTATION_PREFIX = "vecter.io/"
+
+const (
+	LATENCY_THRESHOLD_ANNOTATION               = ANNOTATION_PREFIX + "latency-threshold"   // in milliseconds
+	LATENCY_TARGETS_ANNOTATION                 = ANNOTATION_PREFIX + "latency-targets"     // see ParseLatencyTargets
+	AVAILABILITY_TARGET_ANNOTATION             = ANNOTATION_PREFIX + "availability-target" // fraction of requests that succeed, e.g. 0.999
+	REQUESTS_QUERY_ANNOTATION                  = ANNOTATION_PREFIX + "requests-query"      // promql, requests per second
+	ERRORS_QUERY_ANNOTATION                    = ANNOTATION_PREFIX + "errors-query"        // promql, 5xx responses per second
+	MAPS_ANNOTATION                            = ANNOTATION_PREFIX + "maps"                // in millicpus
+	MIN_NODE_AVAILABILITY_THRESHOLD_ANNOTATION = ANNOTATION_PREFIX + "min-node-availability-threshold"
+	DOWNSCALE_UTILIZATION_THRESHOLD_ANNOTATION = ANNOTATION_PREFIX + "downscale-utilization-threshold"
+	LATENCY_SOURCE_ANNOTATION                  = ANNOTATION_PREFIX + "latency-source" // see ParseLatencySource
+	MIN_REPLICAS_ANNOTATION                    = ANNOTATION_PREFIX + "min-replicas"
+	MAX_REPLICAS_ANNOTATION                    = ANNOTATION_PREFIX + "max-replicas"
+	MIN_CPU_ANNOTATION                         = ANNOTATION_PREFIX + "min-cpu"    // in millicpus
+	MAX_CPU_ANNOTATION                         = ANNOTATION_PREFIX + "max-cpu"    // in millicpus
+	MIN_MEMORY_ANNOTATION                      = ANNOTATION_PREFIX + "min-memory" // quantity, e.g. 64Mi
+	MAX_MEMORY_ANNOTATION                      = ANNOTATION_PREFIX + "max-memory" // quantity, e.g. 2Gi
+	MEMORY_PRESSURE_THRESHOLD_ANNOTATION       = ANNOTATION_PREFIX + "memory-pressure-threshold"
+	MEMORY_DOWNSCALE_THRESHOLD_ANNOTATION      = ANNOTATION_PREFIX + "memory-downscale-threshold"
+	SIDECARS_ANNOTATION                        = ANNOTATION_PREFIX + "sidecars"                 // comma separated, replaces the default list
+	CPU_LIMIT_ANNOTATION                       = ANNOTATION_PREFIX + "cpu-limit"                // see ParseCPULimitPolicy
+	SCALE_UP_STABILIZATION_ANNOTATION          = ANNOTATION_PREFIX + "scale-up-stabilization"   // duration, e.g. 30s
+	SCALE_DOWN_STABILIZATION_ANNOTATION        = ANNOTATION_PREFIX + "scale-down-stabilization" // duration, e.g. 5m
+	FORECAST_WEIGHT_ANNOTATION                 = ANNOTATION_PREFIX + "forecast-weight"          // in [0, 1], 0 turns forecasting off
+	FORECAST_HORIZON_ANNOTATION                = ANNOTATION_PREFIX + "forecast-horizon"         // duration, e.g. 10m
+	SCALING_MODE_ANNOTATION                    = ANNOTATION_PREFIX + "scaling-mode"             // rules or controller
+	LATENCY_SETPOINT_ANNOTATION                = ANNOTATION_PREFIX + "latency-setpoint"         // fraction of the latency threshold
+	CONTROLLER_MAX_STEP_ANNOTATION             = ANNOTATION_PREFIX + "controller-max-step"      // fraction of the allocation
+	SCALING_POLICY_ANNOTATION                  = ANNOTATION_PREFIX + "scaling-policy"           // vecter, hpa or vpa, see DefaultScalingPolicies
+	HPA_TARGET_UTILIZATION_ANNOTATION          = ANNOTATION_PREFIX + "hpa-target-utilization"   // fraction of cpu requests
+	HPA_INTEROP_ANNOTATION                     = ANNOTATION_PREFIX + "hpa-interop"              // refuse, takeover or vertical, see autoscaler-hpa.go
+)
+
+/* --- SCALING MODES --- */
+type ScalingMode string
+
+const (
+	RulesScalingMode      ScalingMode = "rules"      // scale on slo violations and low utilization, see planCPU
+	ControllerScalingMode ScalingMode = "controller" // size total cpu to hold latency at a setpoint, see autoscaler-controller.go
+)
+
+func ParseScalingMode(value string) (ScalingMode, error) {
+	switch mode := ScalingMode(strings.TrimSpace(value)); mode {
+	case "", RulesScalingMode:
+		return RulesScalingMode, nil
+	case ControllerScalingMode:
+		return ControllerScalingMode, nil
+	default:
+		return "", fmt.Errorf("%q is not rules or controller", value)
+	}
+}
+
+/* --- CPU LIMITS --- */
+type CPULimitMode string
+
+const (
+	KeepCPULimit      CPULimitMode = "keep"      // leave limits alone, only raised when a request would pass them
+	RatioCPULimit     CPULimitMode = "ratio"     // limit follows the request at a fixed ratio
+	UnboundedCPULimit CPULimitMode = "unbounded" // no limit on new pods, running pods are only raised
+)
+
+// what happens to a container's CPU limit when its request changes
+// the zero value is KeepCPULimit
+type CPULimitPolicy struct {
+	Mode  CPULimitMode
+	Ratio float64 // ratio, limit / request, at least 1
+}
+
+func (p CPULimitPolicy) String() string {
+	switch p.Mode {
+	case RatioCPULimit:
+		return fmt.Sprintf("%s:%v", p.Mode, p.Ratio)
+	case UnboundedCPULimit:
+		return string(UnboundedCPULimit)
+	default:
+		return string(KeepCPULimit)
+	}
+}
+
+// accepted formats:
+//
+//	keep
+//	ratio:<limit / request>, e.g. ratio:1.5
+//	unbounded
+func ParseCPULimitPolicy(value string) (CPULimitPolicy, error) {
+	value = strings.TrimSpace(value)
+	switch CPULimitMode(value) {
+	case "", KeepCPULimit:
+		return CPULimitPolicy{Mode: KeepCPULimit}, nil
+	case UnboundedCPULimit:
+		return CPULimitPolicy{Mode: UnboundedCPULimit}, nil
+	}
+
+	kind, rest, found := strings.Cut(value, ":")
+	if !found || CPULimitMode(kind) != RatioCPULimit {
+		return CPULimitPolicy{}, fmt.Errorf("%q is not keep, ratio:<ratio> or unbounded", value)
+	}
+	ratio, err := strconv.ParseFloat(strings.TrimSpace(rest), 64)
+	if err != nil || ratio < 1 {
+		return CPULimitPolicy{}, fmt.Errorf("%q: ratio must be a number of at least 1", value)
+	}
+	return CPULimitPolicy{Mode: RatioCPULimit, Ratio: ratio}, nil
+}
+
+// limit to set alongside a new request, given the container's current limit (0 if unset)
+// returns "" to leave the limit as is, util.REMOVE_RESOURCE (template only) to drop it
+func (p CPULimitPolicy) LimitFor(millis int64, currentLimit int64, template bool) string {
+	switch p.Mode {
+	case RatioCPULimit:
+		return fmt.Sprintf("%dm", int64(math.Ceil(float64(millis)*p.Ratio)))
+	case UnboundedCPULimit:
+		if template {
+			return util.REMOVE_RESOURCE
+		}
+	}
+
+	// never leave the request above the limit
+	if currentLimit > 0 && currentLimit < millis {
+		return fmt.Sprintf("%dm", millis)
+	}
+	return ""
+}
+
+/* --- LATENCY SOURCES --- */
+type LatencySourceType string
+
+const (
+	InheritLatencySource LatencySourceType = "inherit" // the global AUTOSCALE_LB load balancer
+	ServiceLatencySource LatencySourceType = "service" // a LoadBalancer service, looked up in cloudwatch
+	PromQLLatencySource  LatencySourceType = "promql"  // a prometheus query returning seconds
+)
+
+// where a deployment's latency comes from
+type LatencySource struct {
+	Type      LatencySourceType
+	Namespace string // service
+	Name      string // service
+	Query     string // promql
+}
+
+func (s LatencySource) String() string {
+	switch s.Type {
+	case ServiceLatencySource:
+		return fmt.Sprintf("%s:%s/%s", s.Type, s.Namespace, s.Name)
+	case PromQLLatencySource:
+		return fmt.Sprintf("%s:%s", s.Type, s.Query)
+	default:
+		return string(InheritLatencySource)
+	}
+}
+
+// accepted formats:
+//
+//	inherit
+//	service:<name>             (service in the deployment's namespace)
+//	service:<namespace>/<name>
+//	promql:<query>
+func ParseLatencySource(value string, deploymentNamespace string) (LatencySource, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == string(InheritLatencySource) {
+		return LatencySource{Type: InheritLatencySource}, nil
+	}
+
+	kind, rest, found := strings.Cut(value, ":")
+	rest = strings.TrimSpace(rest)
+	if !found || rest == "" {
+		return LatencySource{}, fmt.Errorf("%q is not inherit, service:<name> or promql:<query>", value)
+	}
+
+	switch LatencySourceType(kind) {
+	case ServiceLatencySource:
+		namespace, name, hasNamespace := strings.Cut(rest, "/")
+		if !hasNamespace {
+			namespace, name = deploymentNamespace, rest
+		}
+		if namespace == "" || name == "" {
+			return LatencySource{}, fmt.Errorf("%q has an empty service namespace or name", value)
+		}
+		return LatencySource{Type: ServiceLatencySource, Namespace: namespace, Name: name}, nil
+	case PromQLLatencySource:
+		return LatencySource{Type: PromQLLatencySource, Query: rest}, nil
+	default:
+		return LatencySource{}, fmt.Errorf("unknown latency source type %q", kind)
+	}
+}
+
+// scaling knobs for a single deployment
+// anything not set through its PodoscalerPolicy or annotations falls back to the autoscaler-wide value
+type DeploymentPolicy struct {
+	LatencyThreshold              int64           // in milliseconds
+	LatencyTargets                []LatencyTarget // the slo, see SLO
+	AvailabilityTarget            float64         // fraction of requests that have to succeed, 0 means no availability slo
+	RequestsQuery                 string          // promql latency sources, traffic is read from the load balancer otherwise
+	ErrorsQuery                   string
+	Maps                          int64 // in millicpus
+	MinNodeAvailabilityThreshold  float64
+	DownscaleUtilizationThreshold float64
+	LatencySource                 LatencySource
+	MinReplicas                   int
+	MaxReplicas                   int   // 0 means no limit
+	MinCPU                        int64 // in millicpus
+	MaxCPU                        int64 // in millicpus, 0 means no limit
+	MinMemory                     int64 // in bytes
+	MaxMemory                     int64 // in bytes, 0 means no limit
+	MemoryPressureThreshold       float64
+	MemoryDownscaleThreshold      float64
+	Sidecars                      []string // container names left out of measuring and resizing
+	CPULimit                      CPULimitPolicy
+	ScaleUpStabilization          time.Duration // 0 scales up right away
+	ScaleDownStabilization        time.Duration // 0 scales down right away
+	ForecastWeight                float64       // forecast share of the demand sized for, 0 is purely reactive
+	ForecastHorizon               time.Duration // how far ahead to scale for the forecast
+	Mode                          ScalingMode
+	LatencySetpoint               float64        // controller mode, share of the latency targets to hold latency at
+	ControllerMaxStep             float64        // controller mode, largest change of total cpu per round, as a fraction of the allocation
+	ScalingPolicy                 string         // name of the ScalingPolicy that plans the deployment
+	HPATargetUtilization          float64        // hpa policy, usage / requests to hold
+	HPAInterop                    HPAInteropMode // what to do when a HorizontalPodAutoscaler also targets the deployment
+}
+
+// returns the bounded replica count and whether the bounds changed it
+func (p DeploymentPolicy) ClampReplicas(replicas int) (int, bool) {
+	bounded := max(replicas, p.MinReplicas)
+	if p.MaxReplicas > 0 {
+		bounded = min(bounded, p.MaxReplicas)
+	}
+	return bounded, bounded != replicas
+}
+
+// returns the bounded per-pod memory request and whether the bounds changed it
+func (p DeploymentPolicy) ClampMemory(bytes int64) (int64, bool) {
+	bounded := max(bytes, p.MinMemory)
+	if p.MaxMemory > 0 {
+		bounded = min(bounded, p.MaxMemory)
+	}
+	return bounded, bounded != bytes
+}
+
+// returns the bounded per-pod CPU request and whether the bounds changed it
+func (p DeploymentPolicy) ClampRequests(millis int64) (int64, bool) {
+	bounded := max(millis, p.MinCPU)
+	if p.MaxCPU > 0 {
+		bounded = min(bounded, p.MaxCPU)
+	}
+	return bounded, bounded != millis
+}
+
+func (a *Autoscaler) DefaultPolicy() DeploymentPolicy {
+	minReplicas := a.MinReplicas
+	if minReplicas == 0 {
+		minReplicas = DEFAULT_MIN_REPLICAS
+	}
+	minRequests := a.MinRequests
+	if minRequests == 0 {
+		minRequests = DEFAULT_MIN_REQUESTS
+	}
+	sidecars := a.Sidecars
+	if sidecars == nil {
+		sidecars = util.DEFAULT_SIDECARS
+	}
+	mode := a.ScalingMode
+	if mode == "" {
+		mode = RulesScalingMode
+	}
+	setpoint := a.LatencySetpoint
+	if setpoint == 0 {
+		setpoint = DEFAULT_LATENCY_SETPOINT
+	}
+	maxStep := a.ControllerMaxStep
+	if maxStep == 0 {
+		maxStep = DEFAULT_CONTROLLER_MAX_STEP
+	}
+	scalingPolicy := a.ScalingPolicy
+	if scalingPolicy == "" {
+		scalingPolicy = DEFAULT_SCALING_POLICY
+	}
+	hpaTarget := a.HPATargetUtilization
+	if hpaTarget == 0 {
+		hpaTarget = DEFAULT_HPA_TARGET_UTILIZATION
+	}
+	hpaInterop := a.HPAInterop
+	if hpaInterop == "" {
+		hpaInterop = DEFAULT_HPA_INTEROP
+	}
+
+	return DeploymentPolicy{
+		LatencyThreshold:              a.LatencyThreshold,
+		LatencyTargets:                a.LatencyTargets,
+		AvailabilityTarget:            a.AvailabilityTarget,
+		Maps:                          a.Maps,
+		MinNodeAvailabilityThreshold:  a.MinNodeAvailabilityThreshold,
+		DownscaleUtilizationThreshold: a.DownscaleUtilizationThreshold,
+		LatencySource:                 LatencySource{Type: InheritLatencySource},
+		MinReplicas:                   minReplicas,
+		MaxReplicas:                   a.MaxReplicas,
+		MinCPU:                        minRequests,
+		MaxCPU:                        a.MaxRequests,
+		MinMemory:                     DEFAULT_MIN_MEMORY,
+		MemoryPressureThreshold:       DEFAULT_MEMORY_PRESSURE_THRESHOLD,
+		MemoryDownscaleThreshold:      DEFAULT_MEMORY_DOWNSCALE_THRESHOLD,
+		Sidecars:                      sidecars,
+		CPULimit:                      a.CPULimit,
+		ScaleUpStabilization:          a.ScaleUpStabilization,
+		ScaleDownStabilization:        a.ScaleDownStabilization,
+		ForecastWeight:                a.ForecastWeight,
+		ForecastHorizon:               a.ForecastHorizon,
+		Mode:                          mode,
+		LatencySetpoint:               setpoint,
+		ControllerMaxStep:             maxStep,
+		ScalingPolicy:                 scalingPolicy,
+		HPATargetUtilization:          hpaTarget,
+		HPAInterop:                    hpaInterop,
+	}
+}
+
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+// reads the policy annotations on the deployment on top of the defaults
+func (a *Autoscaler) GetDeploymentPolicy(deployment *appsv1.Deployment) (DeploymentPolicy, error) {
+	return a.ResolvePolicy(deployment, nil)
+}
+
+// defaults, then the deployment's annotations, then the PodoscalerPolicy spec (may be nil)
+// returns every bad setting at once so they can all be fixed in one go
+func (a *Autoscaler) ResolvePolicy(deployment *appsv1.Deployment, spec *v1alpha1.PodoscalerPolicySpec) (DeploymentPolicy, error) {
+	policy := a.DefaultPolicy()
+	errs := applyAnnotations(&policy, deployment)
+	if spec != nil {
+		errs = append(errs, applyPolicySpec(&policy, spec, deployment.Namespace)...)
+	}
+	errs = append(errs, validatePolicy(policy)...)
+
+	if len(errs) > 0 {
+		return policy, fmt.Errorf("%w for deployment %s/%s: %w", ErrInvalidPolicy, deployment.Namespace, deployment.Name, errors.Join(errs...))
+	}
+	return policy, nil
+}
+
+func applyAnnotations(policy *DeploymentPolicy, deployment *appsv1.Deployment) []error {
+	annotations := deployment.Annotations
+
+	var errs []error
+	if v, ok := annotations[LATENCY_THRESHOLD_ANNOTATION]; ok {
+		policy.LatencyThreshold, errs = parsePositiveInt(LATENCY_THRESHOLD_ANNOTATION, v, policy.LatencyThreshold, errs)
+	}
+	if v, ok := annotations[LATENCY_TARGETS_ANNOTATION]; ok {
+		targets, err := ParseLatencyTargets(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", LATENCY_TARGETS_ANNOTATION, err))
+		} else {
+			policy.LatencyTargets = targets
+		}
+	}
+	if v, ok := annotations[AVAILABILITY_TARGET_ANNOTATION]; ok {
+		policy.AvailabilityTarget, errs = parseAvailability(AVAILABILITY_TARGET_ANNOTATION, v, policy.AvailabilityTarget, errs)
+	}
+	if v, ok := annotations[REQUESTS_QUERY_ANNOTATION]; ok {
+		policy.RequestsQuery = v
+	}
+	if v, ok := annotations[ERRORS_QUERY_ANNOTATION]; ok {
+		policy.ErrorsQuery = v
+	}
+	if v, ok := annotations[MAPS_ANNOTATION]; ok {
+		policy.Maps, errs = parsePositiveInt(MAPS_ANNOTATION, v, policy.Maps, errs)
+	}
+	if v, ok := annotations[MIN_NODE_AVAILABILITY_THRESHOLD_ANNOTATION]; ok {
+		policy.MinNodeAvailabilityThreshold, errs = parseFraction(MIN_NODE_AVAILABILITY_THRESHOLD_ANNOTATION, v, policy.MinNodeAvailabilityThreshold, errs)
+	}
+	if v, ok := annotations[DOWNSCALE_UTILIZATION_THRESHOLD_ANNOTATION]; ok {
+		policy.DownscaleUtilizationThreshold, errs = parseFraction(DOWNSCALE_UTILIZATION_THRESHOLD_ANNOTATION, v, policy.DownscaleUtilizationThreshold, errs)
+	}
+	if v, ok := annotations[LATENCY_SOURCE_ANNOTATION]; ok {
+		source, err := ParseLatencySource(v, deployment.Namespace)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", LATENCY_SOURCE_ANNOTATION, err))
+		} else {
+			policy.LatencySource = source
+		}
+	}
+	if v, ok := annotations[MIN_REPLICAS_ANNOTATION]; ok {
+		var n int64
+		n, errs = parsePositiveInt(MIN_REPLICAS_ANNOTATION, v, int64(policy.MinReplicas), errs)
+		policy.MinReplicas = int(n)
+	}
+	if v, ok := annotations[MAX_REPLICAS_ANNOTATION]; ok {
+		var n int64
+		n, errs = parsePositiveInt(MAX_REPLICAS_ANNOTATION, v, int64(policy.MaxReplicas), errs)
+		policy.MaxReplicas = int(n)
+	}
+	if v, ok := annotations[MIN_CPU_ANNOTATION]; ok {
+		policy.MinCPU, errs = parsePositiveInt(MIN_CPU_ANNOTATION, v, policy.MinCPU, errs)
+	}
+	if v, ok := annotations[MAX_CPU_ANNOTATION]; ok {
+		policy.MaxCPU, errs = parsePositiveInt(MAX_CPU_ANNOTATION, v, policy.MaxCPU, errs)
+	}
+	if v, ok := annotations[MIN_MEMORY_ANNOTATION]; ok {
+		policy.MinMemory, errs = parseBytes(MIN_MEMORY_ANNOTATION, v, policy.MinMemory, errs)
+	}
+	if v, ok := annotations[MAX_MEMORY_ANNOTATION]; ok {
+		policy.MaxMemory, errs = parseBytes(MAX_MEMORY_ANNOTATION, v, policy.MaxMemory, errs)
+	}
+	if v, ok := annotations[MEMORY_PRESSURE_THRESHOLD_ANNOTATION]; ok {
+		policy.MemoryPressureThreshold, errs = parseFraction(MEMORY_PRESSURE_THRESHOLD_ANNOTATION, v, policy.MemoryPressureThreshold, errs)
+	}
+	if v, ok := annotations[MEMORY_DOWNSCALE_THRESHOLD_ANNOTATION]; ok {
+		policy.MemoryDownscaleThreshold, errs = parseFraction(MEMORY_DOWNSCALE_THRESHOLD_ANNOTATION, v, policy.MemoryDownscaleThreshold, errs)
+	}
+	if v, ok := annotations[CPU_LIMIT_ANNOTATION]; ok {
+		limit, err := ParseCPULimitPolicy(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", CPU_LIMIT_ANNOTATION, err))
+		} else {
+			policy.CPULimit = limit
+		}
+	}
+	if v, ok := annotations[SIDECARS_ANNOTATION]; ok {
+		policy.Sidecars = util.ParseContainerList(v)
+	}
+	if v, ok := annotations[SCALE_UP_STABILIZATION_ANNOTATION]; ok {
+		policy.ScaleUpStabilization, errs = parseDuration(SCALE_UP_STABILIZATION_ANNOTATION, v, policy.ScaleUpStabilization, errs)
+	}
+	if v, ok := annotations[SCALE_DOWN_STABILIZATION_ANNOTATION]; ok {
+		policy.ScaleDownStabilization, errs = parseDuration(SCALE_DOWN_STABILIZATION_ANNOTATION, v, policy.ScaleDownStabilization, errs)
+	}
+	if v, ok := annotations[FORECAST_WEIGHT_ANNOTATION]; ok {
+		policy.ForecastWeight, errs = parseWeight(FORECAST_WEIGHT_ANNOTATION, v, policy.ForecastWeight, errs)
+	}
+	if v, ok := annotations[FORECAST_HORIZON_ANNOTATION]; ok {
+		policy.ForecastHorizon, errs = parseDuration(FORECAST_HORIZON_ANNOTATION, v, policy.ForecastHorizon, errs)
+	}
+	if v, ok := annotations[SCALING_MODE_ANNOTATION]; ok {
+		mode, err := ParseScalingMode(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", SCALING_MODE_ANNOTATION, err))
+		} else {
+			policy.Mode = mode
+		}
+	}
+	if v, ok := annotations[LATENCY_SETPOINT_ANNOTATION]; ok {
+		policy.LatencySetpoint, errs = parseFraction(LATENCY_SETPOINT_ANNOTATION, v, policy.LatencySetpoint, errs)
+	}
+	if v, ok := annotations[CONTROLLER_MAX_STEP_ANNOTATION]; ok {
+		policy.ControllerMaxStep, errs = parseFraction(CONTROLLER_MAX_STEP_ANNOTATION, v, policy.ControllerMaxStep, errs)
+	}
+	if v, ok := annotations[SCALING_POLICY_ANNOTATION]; ok {
+		name, err := ParseScalingPolicy(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", SCALING_POLICY_ANNOTATION, err))
+		} else {
+			policy.ScalingPolicy = name
+		}
+	}
+	if v, ok := annotations[HPA_TARGET_UTILIZATION_ANNOTATION]; ok {
+		policy.HPATargetUtilization, errs = parseFraction(HPA_TARGET_UTILIZATION_ANNOTATION, v, policy.HPATargetUtilization, errs)
+	}
+	if v, ok := annotations[HPA_INTEROP_ANNOTATION]; ok {
+		mode, err := ParseHPAInteropMode(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", HPA_INTEROP_ANNOTATION, err))
+		} else {
+			policy.HPAInterop = mode
+		}
+	}
+	return errs
+}
+
+// unset fields in the spec leave the policy as is
+func applyPolicySpec(policy *DeploymentPolicy, spec *v1alpha1.PodoscalerPolicySpec, namespace string) []error {
+	var errs []error
+	if v := spec.SLO.LatencyThresholdMillis; v != nil {
+		policy.LatencyThreshold, errs = specPositiveInt("slo.latencyThresholdMillis", *v, policy.LatencyThreshold, errs)
+	}
+	if v := spec.SLO.LatencyTargets; v != nil {
+		targets, err := latencyTargetsFromSpec(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("slo.latencyTargets: %w", err))
+		} else {
+			policy.LatencyTargets = targets
+		}
+	}
+	if v := spec.SLO.AvailabilityPercent; v != "" {
+		policy.AvailabilityTarget, errs = specAvailability("slo.availabilityPercent", v, policy.AvailabilityTarget, errs)
+	}
+	if v := spec.SLO.RequestsQuery; v != "" {
+		policy.RequestsQuery = v
+	}
+	if v := spec.SLO.ErrorsQuery; v != "" {
+		policy.ErrorsQuery = v
+	}
+	if v := spec.SLO.LatencySource; v != "" {
+		source, err := ParseLatencySource(v, namespace)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("slo.latencySource: %w", err))
+		} else {
+			policy.LatencySource = source
+		}
+	}
+	if v := spec.SLO.LatencySetpointPercent; v != nil {
+		policy.LatencySetpoint, errs = specPercent("slo.latencySetpointPercent", *v, policy.LatencySetpoint, errs)
+	}
+
+	bounds := spec.Bounds
+	if v := bounds.MinReplicas; v != nil {
+		var n int64
+		n, errs = specPositiveInt("bounds.minReplicas", int64(*v), int64(policy.MinReplicas), errs)
+		policy.MinReplicas = int(n)
+	}
+	if v := bounds.MaxReplicas; v != nil {
+		var n int64
+		n, errs = specPositiveInt("bounds.maxReplicas", int64(*v), int64(policy.MaxReplicas), errs)
+		policy.MaxReplicas = int(n)
+	}
+	if v := bounds.MinCPU; v != nil {
+		policy.MinCPU, errs = specPositiveInt("bounds.minCPU", v.MilliValue(), policy.MinCPU, errs)
+	}
+	if v := bounds.MaxCPU; v != nil {
+		policy.MaxCPU, errs = specPositiveInt("bounds.maxCPU", v.MilliValue(), policy.MaxCPU, errs)
+	}
+	if v := bounds.MinMemory; v != nil {
+		policy.MinMemory, errs = specPositiveInt("bounds.minMemory", v.Value(), policy.MinMemory, errs)
+	}
+	if v := bounds.MaxMemory; v != nil {
+		policy.MaxMemory, errs = specPositiveInt("bounds.maxMemory", v.Value(), policy.MaxMemory, errs)
+	}
+
+	scaling := spec.Scaling
+	if v := scaling.Maps; v != nil {
+		policy.Maps, errs = specPositiveInt("scaling.maps", v.MilliValue(), policy.Maps, errs)
+	}
+	if v := scaling.MinNodeAvailabilityPercent; v != nil {
+		policy.MinNodeAvailabilityThreshold, errs = specPercent("scaling.minNodeAvailabilityPercent", *v, policy.MinNodeAvailabilityThreshold, errs)
+	}
+	if v := scaling.DownscaleUtilizationPercent; v != nil {
+		policy.DownscaleUtilizationThreshold, errs = specPercent("scaling.downscaleUtilizationPercent", *v, policy.DownscaleUtilizationThreshold, errs)
+	}
+	if v := scaling.MemoryPressurePercent; v != nil {
+		policy.MemoryPressureThreshold, errs = specPercent("scaling.memoryPressurePercent", *v, policy.MemoryPressureThreshold, errs)
+	}
+	if v := scaling.MemoryDownscalePercent; v != nil {
+		policy.MemoryDownscaleThreshold, errs = specPercent("scaling.memoryDownscalePercent", *v, policy.MemoryDownscaleThreshold, errs)
+	}
+	if v := scaling.CPULimit; v != "" {
+		limit, err := ParseCPULimitPolicy(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scaling.cpuLimit: %w", err))
+		} else {
+			policy.CPULimit = limit
+		}
+	}
+	if scaling.Sidecars != nil {
+		policy.Sidecars = scaling.Sidecars
+	}
+	if v := scaling.ScaleUpStabilizationSeconds; v != nil {
+		policy.ScaleUpStabilization, errs = specSeconds("scaling.scaleUpStabilizationSeconds", *v, policy.ScaleUpStabilization, errs)
+	}
+	if v := scaling.ScaleDownStabilizationSeconds; v != nil {
+		policy.ScaleDownStabilization, errs = specSeconds("scaling.scaleDownStabilizationSeconds", *v, policy.ScaleDownStabilization, errs)
+	}
+	if v := scaling.ForecastWeightPercent; v != nil {
+		policy.ForecastWeight, errs = specWeightPercent("scaling.forecastWeightPercent", *v, policy.ForecastWeight, errs)
+	}
+	if v := scaling.ForecastHorizonSeconds; v != nil {
+		policy.ForecastHorizon, errs = specSeconds("scaling.forecastHorizonSeconds", *v, policy.ForecastHorizon, errs)
+	}
+	if v := scaling.Mode; v != "" {
+		mode, err := ParseScalingMode(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scaling.mode: %w", err))
+		} else {
+			policy.Mode = mode
+		}
+	}
+	if v := scaling.ControllerMaxStepPercent; v != nil {
+		policy.ControllerMaxStep, errs = specPercent("scaling.controllerMaxStepPercent", *v, policy.ControllerMaxStep, errs)
+	}
+	if v := scaling.Policy; v != "" {
+		name, err := ParseScalingPolicy(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scaling.policy: %w", err))
+		} else {
+			policy.ScalingPolicy = name
+		}
+	}
+	if v := scaling.HPATargetUtilizationPercent; v != nil {
+		policy.HPATargetUtilization, errs = specPercent("scaling.hpaTargetUtilizationPercent", *v, policy.HPATargetUtilization, errs)
+	}
+	if v := scaling.HPAInterop; v != "" {
+		mode, err := ParseHPAInteropMode(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scaling.hpaInterop: %w", err))
+		} else {
+			policy.HPAInterop = mode
+		}
+	}
+	return errs
+}
+
+// checks that hold however the policy was put together
+func validatePolicy(policy DeploymentPolicy) []error {
+	var errs []error
+	if policy.MaxReplicas > 0 && policy.MinReplicas > policy.MaxReplicas {
+		errs = append(errs, fmt.Errorf("min replicas %d is above max replicas %d", policy.MinReplicas, policy.MaxReplicas))
+	}
+	if policy.MaxCPU > 0 && policy.MinCPU > policy.MaxCPU {
+		errs = append(errs, fmt.Errorf("min cpu %dm is above max cpu %dm", policy.MinCPU, policy.MaxCPU))
+	}
+	if policy.MaxMemory > 0 && policy.MinMemory > policy.MaxMemory {
+		errs = append(errs, fmt.Errorf("min memory %d is above max memory %d", policy.MinMemory, policy.MaxMemory))
+	}
+	if policy.MemoryDownscaleThreshold >= policy.MemoryPressureThreshold {
+		errs = append(errs, fmt.Errorf("memory downscale threshold %v must be below memory pressure threshold %v", policy.MemoryDownscaleThreshold, policy.MemoryPressureThreshold))
+	}
+	if policy.LatencySource.Type == PromQLLatencySource {
+		// the query's result is the only percentile a promql source reports
+		for _, target := range policy.SLO() {
+			if target.Percentile != DEFAULT_SLO_PERCENTILE {
+				errs = append(errs, fmt.Errorf("a promql latency source only reports %s, %s can't be targeted", DEFAULT_SLO_PERCENTILE, target.Percentile))
+			}
+		}
+	}
+	if policy.AvailabilityTarget > 0 && policy.LatencySource.Type == PromQLLatencySource && (policy.RequestsQuery == "" || policy.ErrorsQuery == "") {
+		errs = append(errs, errors.New("an availability target with a promql latency source needs requests and errors queries"))
+	}
+	return errs
+}
+
+func parsePositiveInt(key string, value string, fallback int64, errs []error) (int64, []error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fallback, append(errs, fmt.Errorf("%s: %q is not an integer", key, value))
+	}
+	if n <= 0 {
+		return fallback, append(errs, fmt.Errorf("%s: %d must be positive", key, n))
+	}
+	return n, errs
+}
+
+// memory is a kubernetes quantity (e.g. 256Mi), returned in bytes
+func parseBytes(key string, value string, fallback int64, errs []error) (int64, []error) {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return fallback, append(errs, fmt.Errorf("%s: %q is not a quantity", key, value))
+	}
+	if q.Sign() <= 0 {
+		return fallback, append(errs, fmt.Errorf("%s: %s must be positive", key, value))
+	}
+	return q.Value(), errs
+}
+
+// durations use go syntax (e.g. 90s, 5m), 0 turns them off
+func parseDuration(key string, value string, fallback time.Duration, errs []error) (time.Duration, []error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback, append(errs, fmt.Errorf("%s: %q is not a duration", key, value))
+	}
+	if d < 0 {
+		return fallback, append(errs, fmt.Errorf("%s: %s must not be negative", key, value))
+	}
+	return d, errs
+}
+
+// the CRD schema already checks most of these, but not every cluster validates
+func specPositiveInt(key string, n int64, fallback int64, errs []error) (int64, []error) {
+	if n <= 0 {
+		return fallback, append(errs, fmt.Errorf("%s: %d must be positive", key, n))
+	}
+	return n, errs
+}
+
+// windows and other spans in the spec are whole seconds, 0 turns them off
+func specSeconds(key string, seconds int32, fallback time.Duration, errs []error) (time.Duration, []error) {
+	if seconds < 0 {
+		return fallback, append(errs, fmt.Errorf("%s: %d must not be negative", key, seconds))
+	}
+	return time.Duration(seconds) * time.Second, errs
+}
+
+// spec thresholds are percentages in (0, 100], returned as fractions
+func specPercent(key string, percent int32, fallback float64, errs []error) (float64, []error) {
+	if percent <= 0 || percent > 100 {
+		return fallback, append(errs, fmt.Errorf("%s: %d must be in (0, 100]", key, percent))
+	}
+	return float64(percent) / 100, errs
+}
+
+// weights are fractions in [0, 1], 0 turns off what they weigh in
+func parseWeight(key string, value string, fallback float64, errs []error) (float64, []error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fallback, append(errs, fmt.Errorf("%s: %q is not a number", key, value))
+	}
+	if f < 0 || f > 1 {
+		return fallback, append(errs, fmt.Errorf("%s: %v must be in [0, 1]", key, f))
+	}
+	return f, errs
+}
+
+// spec weights are percentages in [0, 100], returned as fractions
+func specWeightPercent(key string, percent int32, fallback float64, errs []error) (float64, []error) {
+	if percent < 0 || percent > 100 {
+		return fallback, append(errs, fmt.Errorf("%s: %d must be in [0, 100]", key, percent))
+	}
+	return float64(percent) / 100, errs
+}
+
+// thresholds are fractions in (0, 1]
+func parseFraction(key string, value string, fallback float64, errs []error) (float64, []error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fallback, append(errs, fmt.Errorf("%s: %q is not a number", key, value))
+	}
+	if f <= 0 || f > 1 {
+		return fallback, append(errs, fmt.Errorf("%s: %v must be in (0, 1]", key, f))
+	}
+	return f, errs
+}
@@ -0,0 +1,134 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	util "github.com/tholiang/podoscaler/scalers/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+/* --- RESIZE ROLLBACK ---
+ * a vscale either lands on every ready pod and the template, or is rolled back
+ * what each patch replaced is recorded as it goes through, and put back newest first on the first failure
+ */
+const ROLLBACK_TIMEOUT = 10 * time.Second // a rollback still runs after the action's own deadline, for at most this long
+
+type ResizeOutcome string
+
+const (
+	PodResized      ResizeOutcome = "resized"
+	PodReverted     ResizeOutcome = "reverted"      // resized, then put back
+	PodRevertFailed ResizeOutcome = "revert-failed" // left (partly) at the new size
+	PodFailed       ResizeOutcome = "failed"        // the resize itself failed, nothing on the pod is left changed
+	PodUntouched    ResizeOutcome = "untouched"     // not reached before the failure
+)
+
+// where one pod ended up after a vscale
+type PodResize struct {
+	Pod         string        `json:"pod"`
+	Outcome     ResizeOutcome `json:"outcome"`
+	ApplyMillis int64         `json:"applyMillis,omitempty"` // from the first patch until the kubelet applied the last container
+	Error       string        `json:"error,omitempty"`
+}
+
+// a vscale that did not go through everywhere, with every pod's outcome after the rollback
+type ResizeError struct {
+	Err  error
+	Pod  string // the pod the resize failed on, empty if it was the template
+	Pods []PodResize
+}
+
+func (e *ResizeError) Error() string {
+	counts := map[ResizeOutcome]int{}
+	for _, pod := range e.Pods {
+		counts[pod.Outcome]++
+	}
+	return fmt.Sprintf("resize rolled back (%d pods reverted, %d left resized): %s", counts[PodReverted], counts[PodRevertFailed], e.Err.Error())
+}
+
+func (e *ResizeError) Unwrap() error {
+	return e.Err
+}
+
+// one container patch that went through, with the patch that undoes it
+type appliedResize struct {
+	pod       string // empty for the deployment template
+	container string
+	previous  util.VerticalPatchContainerResources
+}
+
+// puts back every applied patch, newest first, and reports where each pod ended up
+func (a *Autoscaler) rollbackResize(ctx context.Context, deploymentName string, deploymentNamespace string, applied []appliedResize, outcomes []PodResize, failedPod string, cause error) error {
+	// the resize may have failed on its own deadline, the rollback gets a budget of its own
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ROLLBACK_TIMEOUT)
+	defer cancel()
+
+	index := map[string]int{}
+	for i, outcome := range outcomes {
+		index[outcome.Pod] = i
+	}
+
+	for i := len(applied) - 1; i >= 0; i-- {
+		r := applied[i]
+		if r.previous == (util.VerticalPatchContainerResources{}) {
+			continue // nothing that can be put back
+		}
+
+		if r.pod == "" {
+			err := a.Metrics.PatchDeploymentReqs(ctx, a.Clientset, deploymentName, r.container, r.previous, deploymentNamespace)
+			if err != nil {
+				fmt.Printf("❌ ERROR: Failed to revert container %s of deployment %s: %s\n", r.container, deploymentName, err.Error())
+			}
+			continue
+		}
+
+		outcome := &outcomes[index[r.pod]]
+		err := a.Metrics.VScale(ctx, a.Clientset, r.pod, r.container, r.previous, deploymentNamespace)
+		if err != nil {
+			fmt.Printf("❌ ERROR: Failed to revert container %s of pod %s: %s\n", r.container, r.pod, err.Error())
+			outcome.Outcome, outcome.Error = PodRevertFailed, err.Error()
+		} else if outcome.Outcome == PodResized {
+			outcome.Outcome = PodReverted
+		}
+	}
+
+	fmt.Printf("⚠️ Resize of deployment %s rolled back:\n", deploymentName)
+	for _, outcome := range outcomes {
+		fmt.Printf("   %s: %s\n", outcome.Pod, outcome.Outcome)
+	}
+	return &ResizeError{Err: cause, Pod: failedPod, Pods: outcomes}
+}
+
+// the patch that puts back whatever patch changed on current
+// a resource current did not set can only be dropped again from the template, running pods keep it
+func previousResources(current v1.Container, patch util.VerticalPatchContainerResources, template bool) util.VerticalPatchContainerResources {
+	previous := util.VerticalPatchContainerResources{}
+	previous.Requests.CPU = previousValue(current.Resources.Requests, v1.ResourceCPU, patch.Requests.CPU, template)
+	previous.Requests.Memory = previousValue(current.Resources.Requests, v1.ResourceMemory, patch.Requests.Memory, template)
+	previous.Limits.CPU = previousValue(current.Resources.Limits, v1.ResourceCPU, patch.Limits.CPU, template)
+	previous.Limits.Memory = previousValue(current.Resources.Limits, v1.ResourceMemory, patch.Limits.Memory, template)
+	return previous
+}
+
+func previousValue(resources v1.ResourceList, name v1.ResourceName, patched string, template bool) string {
+	if patched == "" {
+		return ""
+	}
+	q, ok := resources[name]
+	if !ok {
+		if template {
+			return util.REMOVE_RESOURCE
+		}
+		return ""
+	}
+	if name == v1.ResourceCPU {
+		return fmt.Sprintf("%dm", q.MilliValue())
+	}
+	return resource.NewQuantity(q.Value(), resource.BinarySI).String()
+}
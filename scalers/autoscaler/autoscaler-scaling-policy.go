@@ -0,0 +1,110 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+/* --- SCALING POLICIES ---
+ * the algorithm that plans a deployment, chosen per deployment so baselines run with the same binary, metrics and logs
+ *  vecter - latency slo rules or the latency controller (see PlanDeployment)
+ *  hpa    - the horizontal pod autoscaler's target utilization formula, replicas only
+ *  vpa    - a vertical pod autoscaler style recommender over usage history, requests only
+ * gathering, stabilization and execution are the same for all of them
+ */
+const (
+	VECTER_SCALING_POLICY = "vecter"
+	HPA_SCALING_POLICY    = "hpa"
+	VPA_SCALING_POLICY    = "vpa"
+)
+
+type ScalingPolicy interface {
+	Name() string
+	// ordered actions for one deployment's snapshot
+	// called by several workers at once, policies that keep history between rounds guard it themselves
+	Plan(state DeploymentState, now time.Time) DeploymentDecision
+}
+
+// the built-in policies by name
+func DefaultScalingPolicies() map[string]ScalingPolicy {
+	policies := map[string]ScalingPolicy{}
+	for _, policy := range []ScalingPolicy{VecterPolicy{}, HPAPolicy{}, NewVPAPolicy()} {
+		policies[policy.Name()] = policy
+	}
+	return policies
+}
+
+// only the built-in names, so a typo fails the deployment's policy instead of its round
+func ParseScalingPolicy(value string) (string, error) {
+	switch name := strings.TrimSpace(value); name {
+	case VECTER_SCALING_POLICY, HPA_SCALING_POLICY, VPA_SCALING_POLICY:
+		return name, nil
+	default:
+		return "", fmt.Errorf("%q is not vecter, hpa or vpa", value)
+	}
+}
+
+// nil when the name is not registered
+func (a *Autoscaler) scalingPolicy(name string) ScalingPolicy {
+	a.scalingPoliciesOnce.Do(func() {
+		if a.ScalingPolicies == nil {
+			a.ScalingPolicies = DefaultScalingPolicies()
+		}
+	})
+	return a.ScalingPolicies[name]
+}
+
+/* --- VECTER --- */
+type VecterPolicy struct{}
+
+func (VecterPolicy) Name() string {
+	return VECTER_SCALING_POLICY
+}
+
+func (VecterPolicy) Plan(state DeploymentState, now time.Time) DeploymentDecision {
+	return PlanDeployment(state)
+}
+
+/* --- HPA ---
+ * desired = ceil(replicas x utilization / target), nothing done within HPA_TOLERANCE of the target
+ * utilization is usage over requests across the deployment, like the hpa's resource metric
+ * the default target matches the cpu-percent of hack/hotel-hpa-up.sh, hpa's scale-down window is the stabilization window
+ */
+const HPA_TOLERANCE = 0.1
+
+type HPAPolicy struct{}
+
+func (HPAPolicy) Name() string {
+	return HPA_SCALING_POLICY
+}
+
+func (HPAPolicy) Plan(state DeploymentState, now time.Time) DeploymentDecision {
+	policy := state.Policy
+	decision := DeploymentDecision{Actions: []ScaleAction{}}
+	if len(state.Pods) == 0 || state.Allocation == 0 {
+		decision.logf("ℹ️ No ready pods with cpu requests - no action taken")
+		return decision
+	}
+
+	utilPercent := float64(state.Utilization) / float64(state.Allocation)
+	ratio := utilPercent / policy.HPATargetUtilization
+	decision.logf("📊 HPA: %.1f%% utilization against a %.1f%% target", utilPercent*100, policy.HPATargetUtilization*100)
+	if math.Abs(ratio-1) <= HPA_TOLERANCE {
+		decision.logf("ℹ️ Within %.0f%% of the target - no action taken", HPA_TOLERANCE*100)
+		return decision
+	}
+
+	numPods := len(state.Pods)
+	replicas := decision.boundReplicas(policy, int(math.Ceil(float64(numPods)*ratio)))
+	if replicas == numPods {
+		return decision
+	}
+	decision.logf("🔄 Horizontal scaling: %d -> %d replicas", numPods, replicas)
+	decision.add(ScaleAction{Type: HScaleAction, Replicas: replicas, Reason: "hpa target utilization"})
+	return decision
+}
@@ -0,0 +1,159 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"math"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+/* --- SCHEDULING SIMULATION ---
+ * a rough placement of the deployment's pods onto the node snapshot, to catch plans the scheduler could not carry out
+ * cpu and memory requests, taints and the pod template's nodeSelector are checked - affinity, spread and ports are not
+ * running pods are resized where they are, new pods go where the most cpu is left (like least-allocated scoring)
+ */
+const SIMULATION_MAX_REPLICAS = 100 // replica counts tried for a feasible mix when the policy sets no maximum
+
+type Placement struct {
+	Pods     map[string]int // pods of the deployment on each node
+	Unplaced int            // pods no node can take
+}
+
+// places replicas pods of millis and memBytes each, the deployment's own pods give back what they request first
+func (s DeploymentState) Simulate(replicas int, millis int64, memBytes int64) Placement {
+	freeCPU, freeMem := map[string]int64{}, map[string]int64{}
+	for name, node := range s.Nodes {
+		freeCPU[name], freeMem[name] = node.Allocable, node.MemAllocable
+	}
+	for _, pod := range s.Pods {
+		freeCPU[pod.NodeName] += pod.CpuRequests
+		freeMem[pod.NodeName] += pod.MemRequests
+	}
+
+	placement := Placement{Pods: map[string]int{}}
+	fits := func(name string) bool {
+		return freeCPU[name] >= millis && freeMem[name] >= memBytes
+	}
+	place := func(name string) {
+		freeCPU[name] -= millis
+		freeMem[name] -= memBytes
+		placement.Pods[name]++
+	}
+
+	// a pod that cannot grow in place is the first to go on a scale down, or is placed again like a new one
+	kept := 0
+	for _, pod := range s.Pods {
+		if kept < replicas && fits(pod.NodeName) {
+			place(pod.NodeName)
+			kept++
+		}
+	}
+
+	names := sortedKeys(s.Nodes)
+	for range replicas - kept {
+		best := ""
+		for _, name := range names {
+			if s.Schedulable(s.Nodes[name]) && fits(name) && (best == "" || freeCPU[name] > freeCPU[best]) {
+				best = name
+			}
+		}
+		if best == "" {
+			placement.Unplaced++
+			continue
+		}
+		place(best)
+	}
+	return placement
+}
+
+// whether a new pod of the deployment may land on node at all, leaving resources aside
+func (s DeploymentState) Schedulable(node NodeState) bool {
+	if node.Unschedulable {
+		return false
+	}
+	for key, value := range s.NodeSelector {
+		if node.Labels[key] != value {
+			return false
+		}
+	}
+	for _, taint := range node.Taints {
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for _, toleration := range s.Tolerations {
+			if toleration.ToleratesTaint(&taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// checks the plan's end state can be placed, otherwise switches to the feasible mix of replicas and requests
+// closest to it that still gives the same total cpu
+// migrations move pods between nodes, which the simulation does not follow, so plans with them are left as is
+func planPlacement(state DeploymentState, decision *DeploymentDecision) {
+	for _, action := range decision.Actions {
+		if action.Migration {
+			return
+		}
+	}
+	current := Recommend(state, nil, time.Time{})
+	recommended := Recommend(state, decision.Actions, time.Time{})
+	if recommended.same(current) || recommended.Replicas == 0 {
+		return
+	}
+
+	perpodalloc := int64(math.Ceil(float64(recommended.CpuAllocation) / float64(recommended.Replicas)))
+	placement := state.Simulate(recommended.Replicas, perpodalloc, recommended.MemRequests)
+	if placement.Unplaced == 0 {
+		return
+	}
+	decision.logf("⚠️ Scheduling: %d of %d pods at %d millicpus would not fit on any node", placement.Unplaced, recommended.Replicas, perpodalloc)
+
+	target, ok := feasibleMix(state, recommended)
+	if !ok {
+		decision.logf("❌ ERROR: No mix of replicas and requests for %s fits on the cluster - keeping the plan", state.Name)
+		return
+	}
+	decision.logf("🔄 Scheduling: %d replicas at %d millicpus instead", target.Replicas, target.CpuAllocation/int64(target.Replicas))
+	decision.Actions = rebuildActions(state, decision.Actions, current, recommended, target, "placed")
+}
+
+// the replica count closest to recommended (fewer on a tie) whose pods all fit,
+// with requests sized so the total cpu stays at least what was recommended
+func feasibleMix(state DeploymentState, recommended Recommendation) (Recommendation, bool) {
+	policy := state.Policy
+	upper := policy.MaxReplicas
+	if upper == 0 {
+		upper = SIMULATION_MAX_REPLICAS
+	}
+
+	best, found := Recommendation{}, false
+	for replicas := max(policy.MinReplicas, 1); replicas <= upper; replicas++ {
+		requests, _ := policy.ClampRequests(int64(math.Ceil(float64(recommended.CpuAllocation) / float64(replicas))))
+		if int64(replicas)*requests < recommended.CpuAllocation {
+			continue // held down by the request bound
+		}
+		if state.Simulate(replicas, requests, recommended.MemRequests).Unplaced > 0 {
+			continue
+		}
+		if !found || abs(replicas-recommended.Replicas) < abs(best.Replicas-recommended.Replicas) {
+			best = Recommendation{Time: recommended.Time, Replicas: replicas, CpuAllocation: int64(replicas) * requests, MemRequests: recommended.MemRequests}
+			found = true
+		}
+	}
+	return best, found
+}
+
+func abs(n int) int {
+	return max(n, -n)
+}
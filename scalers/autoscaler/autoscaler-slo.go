@@ -0,0 +1,191 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tholiang/podoscaler/scalers/api/v1alpha1"
+)
+
+/* --- SLO ---
+ * an slo is a set of latency percentile targets, e.g. p95 under 30ms and p99 under 80ms, and optionally an availability target
+ * each target is scored latency / threshold, and the latency score is the worst of them, so every target has to hold
+ * with weights the latency score is their weighted mean instead, one target can then be slightly over while the others have room
+ * availability is scored 5xx ratio / error budget (1 - target), so a service that fast-fails under load is seen as violating
+ * the slo's score is the worse of the two, over 1 is a violation and scales up, downscaling is only safe at or under 1
+ * without targets the slo is p99 under the latency threshold, which the controller holds p99 against in any case
+ */
+const (
+	DEFAULT_SLO_PERCENTILE        = "p99"
+	AVAILABILITY_MIN_REQUEST_RATE = 1.0 // requests per second, too few to judge availability on below this
+)
+
+type LatencyTarget struct {
+	Percentile string  // as the latency source reports it, e.g. p95
+	Threshold  int64   // in milliseconds
+	Weight     float64 // share of the combined score, 0 for every target scores the worst one
+}
+
+// the policy's latency targets, p99 under LatencyThreshold if none are set
+func (p DeploymentPolicy) SLO() []LatencyTarget {
+	if len(p.LatencyTargets) > 0 {
+		return p.LatencyTargets
+	}
+	return []LatencyTarget{{Percentile: DEFAULT_SLO_PERCENTILE, Threshold: p.LatencyThreshold}}
+}
+
+// accepted format: comma separated <percentile>=<milliseconds>[:<weight>], e.g.
+//
+//	p95=30,p99=80
+//	p95=30:0.3,p99=80:0.7
+func ParseLatencyTargets(value string) ([]LatencyTarget, error) {
+	var targets []LatencyTarget
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		percentile, rest, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not <percentile>=<milliseconds>[:<weight>]", entry)
+		}
+		threshold, weight, weighted := strings.Cut(rest, ":")
+		target := LatencyTarget{Percentile: strings.TrimSpace(percentile)}
+
+		ms, err := strconv.ParseInt(strings.TrimSpace(threshold), 10, 64)
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("%s: %q is not a positive number of milliseconds", target.Percentile, threshold)
+		}
+		target.Threshold = ms
+		if weighted {
+			w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+			if err != nil || w < 0 || w > 1 {
+				return nil, fmt.Errorf("%s: weight %q must be in [0, 1]", target.Percentile, weight)
+			}
+			target.Weight = w
+		}
+		targets = append(targets, target)
+	}
+	return targets, validateLatencyTargets(targets)
+}
+
+// spec weights are percentages, like every other spec fraction
+func latencyTargetsFromSpec(specs []v1alpha1.LatencyTargetSpec) ([]LatencyTarget, error) {
+	targets := make([]LatencyTarget, 0, len(specs))
+	for _, spec := range specs {
+		target := LatencyTarget{Percentile: spec.Percentile, Threshold: spec.ThresholdMillis}
+		if spec.WeightPercent != nil {
+			target.Weight = float64(*spec.WeightPercent) / 100
+		}
+		targets = append(targets, target)
+	}
+	return targets, validateLatencyTargets(targets)
+}
+
+func validateLatencyTargets(targets []LatencyTarget) error {
+	if len(targets) == 0 {
+		return errors.New("no latency targets")
+	}
+	seen := map[string]bool{}
+	var total float64
+	for _, target := range targets {
+		p, err := strconv.ParseFloat(strings.TrimPrefix(target.Percentile, "p"), 64)
+		if !strings.HasPrefix(target.Percentile, "p") || err != nil || p <= 0 || p > 100 {
+			return fmt.Errorf("%q is not a percentile like p95 or p99.9", target.Percentile)
+		}
+		if seen[target.Percentile] {
+			return fmt.Errorf("%s has more than one target", target.Percentile)
+		}
+		seen[target.Percentile] = true
+		if target.Threshold <= 0 {
+			return fmt.Errorf("%s: threshold %dms must be positive", target.Percentile, target.Threshold)
+		}
+		if target.Weight < 0 || target.Weight > 1 {
+			return fmt.Errorf("%s: weight %v must be in [0, 1]", target.Percentile, target.Weight)
+		}
+		total += target.Weight
+	}
+	if total == 0 {
+		return nil
+	}
+	for _, target := range targets {
+		if target.Weight == 0 {
+			return fmt.Errorf("%s has no weight, weights are for every target or none", target.Percentile)
+		}
+	}
+	return nil
+}
+
+// availability targets are fractions in [0, 1), 0 turns the availability slo off
+func parseAvailability(key string, value string, fallback float64, errs []error) (float64, []error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fallback, append(errs, fmt.Errorf("%s: %q is not a number", key, value))
+	}
+	if f < 0 || f >= 1 {
+		return fallback, append(errs, fmt.Errorf("%s: %v must be in [0, 1)", key, f))
+	}
+	return f, errs
+}
+
+// spec availability is a percentage string, e.g. "99.9", returned as a fraction
+func specAvailability(key string, percent string, fallback float64, errs []error) (float64, []error) {
+	f, err := strconv.ParseFloat(percent, 64)
+	if err != nil || f < 0 || f >= 100 {
+		return fallback, append(errs, fmt.Errorf("%s: %q must be a percentage in [0, 100)", key, percent))
+	}
+	return f / 100, errs
+}
+
+// the slo's combined score, the worse of the latency and availability scores
+func (s DeploymentState) SLOScore() float64 {
+	return max(s.latencyScore(), s.availabilityScore())
+}
+
+// latency over threshold of the worst target or the weighted mean of them all, 0 if latency could not be read
+func (s DeploymentState) latencyScore() float64 {
+	if s.LatencyErr != nil {
+		return 0
+	}
+	score, weighted, total := 0.0, 0.0, 0.0
+	for _, target := range s.Policy.SLO() {
+		ratio := s.Latencies[target.Percentile] / float64(target.Threshold)
+		score = math.Max(score, ratio)
+		weighted += target.Weight * ratio
+		total += target.Weight
+	}
+	if total > 0 {
+		return weighted / total
+	}
+	return score
+}
+
+// 5xx ratio over the error budget, 0 without an availability target, traffic to read or enough of it
+func (s DeploymentState) availabilityScore() float64 {
+	target := s.Policy.AvailabilityTarget
+	if target == 0 || s.TrafficErr != nil || s.Traffic.Requests < AVAILABILITY_MIN_REQUEST_RATE {
+		return 0
+	}
+	return s.Traffic.ErrorRatio() / (1 - target)
+}
+
+func (s DeploymentState) SLOViolated() bool {
+	return s.SLOScore() > 1
+}
+
+// whether part of the slo could not be read, the slo may be violated without it showing in the score
+func (s DeploymentState) SLOUnread() bool {
+	return s.LatencyErr != nil || (s.Policy.AvailabilityTarget > 0 && s.TrafficErr != nil)
+}
+
+// e.g. "p95 25.00ms/30ms, p99 90.00ms/80ms"
+func (s DeploymentState) describeSLO() string {
+	parts := []string{}
+	for _, target := range s.Policy.SLO() {
+		parts = append(parts, fmt.Sprintf("%s %.2fms/%dms", target.Percentile, s.Latencies[target.Percentile], target.Threshold))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,195 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+/* --- STABILIZATION ---
+ * every round's recommendation is kept per deployment, and a change only goes through once it has held for its window:
+ *  scale down - to the highest recommendation in the scale-down window, never above the current value
+ *  scale up   - to the lowest recommendation in the scale-up window, never below the current value
+ * replicas, total cpu and per-pod memory are stabilized separately, a zero window lets changes through right away
+ */
+
+// where one round's plan would leave a deployment
+type Recommendation struct {
+	Time          time.Time
+	Replicas      int
+	CpuAllocation int64 // replicas x per-pod requests, in millicpus
+	MemRequests   int64 // per pod, in bytes
+}
+
+func (r Recommendation) same(other Recommendation) bool {
+	return r.Replicas == other.Replicas && r.CpuAllocation == other.CpuAllocation && r.MemRequests == other.MemRequests
+}
+
+// what the deployment ends up at once actions are applied, nil actions gives the current values
+// migrations move pods around without changing replicas or requests, so they are left out
+func Recommend(state DeploymentState, actions []ScaleAction, now time.Time) Recommendation {
+	rec := Recommendation{Time: now, Replicas: len(state.Pods), MemRequests: state.PerPodMemAllocation()}
+	perpodalloc := state.PerPodAllocation()
+	for _, action := range actions {
+		switch {
+		case action.Type == HScaleAction && !action.Migration:
+			rec.Replicas = action.Replicas
+		case action.Type == VScaleAction:
+			if action.CpuRequests > 0 {
+				perpodalloc = action.CpuRequests
+			}
+			if action.MemRequests > 0 {
+				rec.MemRequests = action.MemRequests
+			}
+		}
+	}
+	rec.CpuAllocation = int64(rec.Replicas) * perpodalloc
+	return rec
+}
+
+// holds back the parts of decision that have not been recommended for their whole window
+// history is the deployment's earlier recommendations, oldest first
+func Stabilize(state DeploymentState, decision DeploymentDecision, history []Recommendation, now time.Time) DeploymentDecision {
+	policy := state.Policy
+	if len(state.Pods) == 0 || (policy.ScaleUpStabilization == 0 && policy.ScaleDownStabilization == 0) {
+		return decision
+	}
+
+	current := Recommend(state, nil, now)
+	recommended := Recommend(state, decision.Actions, now)
+
+	stable := Recommendation{
+		Time:          now,
+		Replicas:      int(stabilizeValue(policy, int64(current.Replicas), int64(recommended.Replicas), history, now, func(r Recommendation) int64 { return int64(r.Replicas) })),
+		CpuAllocation: stabilizeValue(policy, current.CpuAllocation, recommended.CpuAllocation, history, now, func(r Recommendation) int64 { return r.CpuAllocation }),
+		MemRequests:   stabilizeValue(policy, current.MemRequests, recommended.MemRequests, history, now, func(r Recommendation) int64 { return r.MemRequests }),
+	}
+	if stable.same(recommended) {
+		return decision
+	}
+
+	if stable.Replicas != recommended.Replicas {
+		decision.logf("⏳ Stabilization: holding %d replicas (recommended %d)", stable.Replicas, recommended.Replicas)
+	}
+	if stable.CpuAllocation != recommended.CpuAllocation {
+		decision.logf("⏳ Stabilization: holding %d millicpus in total (recommended %d)", stable.CpuAllocation, recommended.CpuAllocation)
+	}
+	if stable.MemRequests != recommended.MemRequests {
+		decision.logf("⏳ Stabilization: holding %d bytes memory per pod (recommended %d)", stable.MemRequests, recommended.MemRequests)
+	}
+	decision.Actions = rebuildActions(state, decision.Actions, current, recommended, stable, "stabilized")
+	return decision
+}
+
+// the recommendation for one dimension once every earlier value in its window is taken into account
+func stabilizeValue(policy DeploymentPolicy, current int64, recommended int64, history []Recommendation, now time.Time, value func(Recommendation) int64) int64 {
+	stable := recommended
+	switch {
+	case recommended < current:
+		for _, r := range history {
+			if now.Sub(r.Time) < policy.ScaleDownStabilization {
+				stable = max(stable, value(r))
+			}
+		}
+		return min(stable, current)
+	case recommended > current:
+		for _, r := range history {
+			if now.Sub(r.Time) < policy.ScaleUpStabilization {
+				stable = min(stable, value(r))
+			}
+		}
+		return max(stable, current)
+	}
+	return current
+}
+
+// rebuilds the plan to land on stable instead of recommended, in the same order the planner uses
+// note says why, it is added to the reasons of the rebuilt actions
+func rebuildActions(state DeploymentState, actions []ScaleAction, current Recommendation, recommended Recommendation, stable Recommendation, note string) []ScaleAction {
+	hscaleReason, vscaleReason := note, note
+	stabilized := []ScaleAction{}
+	for _, action := range actions {
+		switch {
+		case action.Migration:
+			// a migration only makes room for the cpu increase, it goes if the increase does
+			if stable.CpuAllocation == recommended.CpuAllocation {
+				stabilized = append(stabilized, action)
+			}
+		case action.Type == HScaleAction:
+			hscaleReason = action.Reason + " (" + note + ")"
+		case action.Type == VScaleAction:
+			vscaleReason = action.Reason + " (" + note + ")"
+		}
+	}
+
+	perpodalloc := state.PerPodAllocation()
+	vscale := ScaleAction{Type: VScaleAction, Reason: vscaleReason}
+	if stable.CpuAllocation > 0 && stable.Replicas > 0 {
+		newRequests := int64(math.Ceil(float64(stable.CpuAllocation) / float64(stable.Replicas)))
+		newRequests, _ = state.Policy.ClampRequests(newRequests)
+		if newRequests != perpodalloc {
+			vscale.CpuRequests = newRequests
+		}
+	}
+	if stable.MemRequests != current.MemRequests {
+		vscale.MemRequests = stable.MemRequests
+	}
+	resize := vscale.CpuRequests > 0 || vscale.MemRequests > 0
+	hscale := ScaleAction{Type: HScaleAction, Replicas: stable.Replicas, Reason: hscaleReason}
+
+	switch {
+	case stable.Replicas > current.Replicas: // hscale first (total increase) then vscale (possible decrease)
+		stabilized = append(stabilized, hscale)
+		if resize {
+			stabilized = append(stabilized, vscale)
+		}
+	case stable.Replicas < current.Replicas && vscale.CpuRequests > perpodalloc: // fewer larger, grow before dropping pods
+		stabilized = append(stabilized, vscale, hscale, vscale)
+	case stable.Replicas < current.Replicas:
+		stabilized = append(stabilized, hscale)
+		if resize {
+			stabilized = append(stabilized, vscale)
+		}
+	case resize:
+		stabilized = append(stabilized, vscale)
+	}
+	return stabilized
+}
+
+// records this round's recommendation and returns the decision to apply
+// workers run deployments at once, so the history is shared under historyMu
+func (a *Autoscaler) stabilize(state DeploymentState, decision DeploymentDecision, now time.Time) DeploymentDecision {
+	if len(state.Pods) == 0 {
+		return decision
+	}
+	key := fmt.Sprintf("%s/%s", state.Namespace, state.Name)
+	keep := max(state.Policy.ScaleUpStabilization, state.Policy.ScaleDownStabilization)
+
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
+	if a.history == nil {
+		a.history = map[string][]Recommendation{}
+	}
+
+	// drop what no window reaches anymore
+	history := a.history[key]
+	for len(history) > 0 && now.Sub(history[0].Time) >= keep {
+		history = history[1:]
+	}
+	// nothing is known about a deployment seen for the first time (or after a restart or failover),
+	// so it has to want a change for a whole window like any other
+	if len(history) == 0 {
+		history = []Recommendation{Recommend(state, nil, now)}
+	}
+
+	stabilized := Stabilize(state, decision, history, now)
+	if keep > 0 {
+		a.history[key] = append(history, Recommend(state, decision.Actions, now))
+	} else {
+		delete(a.history, key)
+	}
+	return stabilized
+}
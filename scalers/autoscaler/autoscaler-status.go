@@ -0,0 +1,150 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/tholiang/podoscaler/scalers/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+/* --- PODOSCALER POLICIES ---
+ * each policy selects one deployment, the round's outcome for it is written back to the policy's status
+ * the status is written in dry run too, with the decision marked as not applied
+ */
+const DEPLOYMENT_KIND = "Deployment"
+
+// for each policy, the earlier policy that already scales the same deployment this round ("" if none)
+func claimTargets(policies []v1alpha1.PodoscalerPolicy) []string {
+	owners := make([]string, len(policies))
+	claimed := map[string]string{} // namespace/deployment to the policy scaling it this round
+	for i, pp := range policies {
+		if pp.Spec.TargetRef.Kind != DEPLOYMENT_KIND {
+			continue
+		}
+		key := pp.Namespace + "/" + pp.Spec.TargetRef.Name
+		if owner, ok := claimed[key]; ok {
+			owners[i] = owner
+			continue
+		}
+		claimed[key] = pp.Name
+	}
+	return owners
+}
+
+// owner is the policy that claimed the same deployment first, if any, nodes the round's node snapshot
+func (a *Autoscaler) reconcilePolicy(ctx context.Context, pp *v1alpha1.PodoscalerPolicy, owner string, nodes map[string]NodeState) DeploymentPlan {
+	target := pp.Spec.TargetRef
+	fmt.Printf("\n📜 Reconciling policy %s/%s -> %s %s\n", pp.Namespace, pp.Name, target.Kind, target.Name)
+
+	dplan := newDeploymentPlan(target.Name, pp.Namespace)
+	reason, err := a.reconcileTarget(ctx, pp, owner, nodes, &dplan)
+	if err != nil {
+		fmt.Printf("❌ ERROR: %s\n", err.Error())
+		dplan.Error = err.Error()
+	}
+
+	// the status still gets written when the deployment ran out of time
+	statusCtx, cancel := a.graceContext(ctx)
+	defer cancel()
+	pp.Status = a.policyStatus(pp, &dplan, reason, err)
+	err = a.Metrics.UpdatePodoscalerPolicyStatus(statusCtx, a.DynamicClient, pp)
+	if err != nil {
+		fmt.Printf("❌ ERROR: %s\n", err.Error())
+	}
+	return dplan
+}
+
+// returns the Reconciled condition reason alongside the error
+func (a *Autoscaler) reconcileTarget(ctx context.Context, pp *v1alpha1.PodoscalerPolicy, owner string, nodes map[string]NodeState, dplan *DeploymentPlan) (string, error) {
+	target := pp.Spec.TargetRef
+	if target.Kind != DEPLOYMENT_KIND {
+		return v1alpha1.InvalidTargetReason, fmt.Errorf("policy %s/%s targets a %q, only %s is supported", pp.Namespace, pp.Name, target.Kind, DEPLOYMENT_KIND)
+	}
+
+	key := pp.Namespace + "/" + target.Name
+	if owner != "" {
+		return v1alpha1.InvalidTargetReason, fmt.Errorf("policy %s/%s targets deployment %s, which policy %s already scales", pp.Namespace, pp.Name, key, owner)
+	}
+
+	deployment, err := a.Metrics.GetDeployment(ctx, a.Clientset, target.Name, pp.Namespace)
+	if apierrors.IsNotFound(err) {
+		return v1alpha1.InvalidTargetReason, fmt.Errorf("policy %s/%s targets missing deployment %s: %w", pp.Namespace, pp.Name, key, err)
+	}
+	if err != nil {
+		return v1alpha1.ReconcileErrorReason, err
+	}
+
+	err = a.processDeployment(ctx, deployment, &pp.Spec, nodes, dplan)
+	if errors.Is(err, ErrInvalidPolicy) {
+		return v1alpha1.InvalidPolicyReason, err
+	}
+	if errors.Is(err, ErrHPAConflict) {
+		return v1alpha1.HPAConflictReason, err
+	}
+	if err != nil {
+		return v1alpha1.ReconcileErrorReason, err
+	}
+	return v1alpha1.ReconciledReason, nil
+}
+
+// observed state is only replaced when the deployment was read this round
+func (a *Autoscaler) policyStatus(pp *v1alpha1.PodoscalerPolicy, dplan *DeploymentPlan, reason string, err error) v1alpha1.PodoscalerPolicyStatus {
+	now := metav1.Now()
+	status := *pp.Status.DeepCopy()
+	status.ObservedGeneration = pp.Generation
+
+	if dplan.observed {
+		observed := &v1alpha1.ObservedState{
+			Time:                now,
+			Replicas:            int32(dplan.Replicas),
+			CPURequestsMillis:   dplan.CpuRequests,
+			UtilizationMillis:   dplan.Utilization,
+			AllocationMillis:    dplan.Allocation,
+			MemoryRequestsBytes: dplan.MemRequests,
+			MemoryUsageBytes:    dplan.MemUsage,
+			SLOViolated:         dplan.SLOViolated,
+		}
+		if dplan.Latency != nil {
+			latency := int64(math.Round(*dplan.Latency))
+			observed.LatencyMillis = &latency
+		}
+		status.Observed = observed
+	}
+
+	decision := &v1alpha1.Decision{Time: now, DryRun: a.DryRun}
+	for _, action := range dplan.Actions {
+		decision.Actions = append(decision.Actions, v1alpha1.DecisionAction{
+			Type:                string(action.Type),
+			Replicas:            int32(action.Replicas),
+			CPURequestsMillis:   action.CpuRequests,
+			MemoryRequestsBytes: action.MemRequests,
+			PodName:             action.PodName,
+			Node:                action.Node,
+			Reason:              action.Reason,
+		})
+	}
+
+	condition := metav1.Condition{
+		Type:               v1alpha1.ReconciledCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            "last round completed",
+		ObservedGeneration: pp.Generation,
+	}
+	if err != nil {
+		decision.Error = err.Error()
+		condition.Status = metav1.ConditionFalse
+		condition.Message = err.Error()
+	}
+	status.LastDecision = decision
+	meta.SetStatusCondition(&status.Conditions, condition)
+	return status
+}
@@ -0,0 +1,150 @@
+//go:build autoscaler || autoscalertest
+// +build autoscaler autoscalertest
+
+package autoscaler
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+/* --- VPA ---
+ * a recommender in the style of the vertical pod autoscaler: every round adds each pod's usage to the deployment's history,
+ * and requests are set to a percentile of it plus a safety margin once they fall outside the recommended range
+ * cpu samples decay with VPA_CPU_HALF_LIFE, memory counts every sample alike, an OOMKill grows memory right away
+ * history is kept in memory, a restart or failover starts it over
+ */
+const (
+	VPA_CPU_HALF_LIFE     = 24 * time.Hour
+	VPA_MAX_AGE           = 8 * 24 * time.Hour // samples older than this are dropped
+	VPA_MAX_SAMPLES       = 50000              // per deployment, oldest dropped first
+	VPA_MIN_SAMPLES       = 10                 // no recommendation with less history
+	VPA_SAFETY_MARGIN     = 0.15
+	VPA_TARGET_PERCENTILE = 0.9  // what requests are set to
+	VPA_LOWER_PERCENTILE  = 0.5  // requests below this are raised
+	VPA_UPPER_PERCENTILE  = 0.95 // requests above this are lowered
+)
+
+type vpaSample struct {
+	Time     time.Time
+	CpuUsage int64 // in millicpus
+	MemUsage int64 // in bytes
+}
+
+type VPAPolicy struct {
+	mu      sync.Mutex
+	samples map[string][]vpaSample // by namespace/name, oldest first
+}
+
+func NewVPAPolicy() *VPAPolicy {
+	return &VPAPolicy{samples: map[string][]vpaSample{}}
+}
+
+func (p *VPAPolicy) Name() string {
+	return VPA_SCALING_POLICY
+}
+
+// adds this round's usage to the deployment's history and returns a copy of it
+func (p *VPAPolicy) observe(state DeploymentState, now time.Time) []vpaSample {
+	key := fmt.Sprintf("%s/%s", state.Namespace, state.Name)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	samples := p.samples[key]
+	for _, pod := range state.Pods {
+		if pod.CpuUsage > 0 || pod.MemUsage > 0 {
+			samples = append(samples, vpaSample{Time: now, CpuUsage: pod.CpuUsage, MemUsage: pod.MemUsage})
+		}
+	}
+	first := max(len(samples)-VPA_MAX_SAMPLES, 0)
+	for first < len(samples) && now.Sub(samples[first].Time) > VPA_MAX_AGE {
+		first++
+	}
+	if first > 0 {
+		samples = slices.Clone(samples[first:])
+	}
+	p.samples[key] = samples
+	return slices.Clone(samples)
+}
+
+func (p *VPAPolicy) Plan(state DeploymentState, now time.Time) DeploymentDecision {
+	policy := state.Policy
+	decision := DeploymentDecision{Actions: []ScaleAction{}}
+	samples := p.observe(state, now)
+	if len(state.Pods) == 0 || state.Allocation == 0 {
+		decision.logf("ℹ️ No ready pods with cpu requests - no action taken")
+		return decision
+	}
+	if len(samples) < VPA_MIN_SAMPLES {
+		decision.logf("ℹ️ VPA: %d of %d samples needed for a recommendation - no action taken", len(samples), VPA_MIN_SAMPLES)
+		return decision
+	}
+
+	cpuWeight := func(s vpaSample) float64 {
+		return math.Exp2(-now.Sub(s.Time).Hours() / VPA_CPU_HALF_LIFE.Hours())
+	}
+	cpu := func(s vpaSample) int64 { return s.CpuUsage }
+	margin := 1 + VPA_SAFETY_MARGIN
+
+	action := ScaleAction{Type: VScaleAction, Reason: "vpa recommendation"}
+	perpodalloc := state.PerPodAllocation()
+	lower := float64(weightedPercentile(samples, VPA_LOWER_PERCENTILE, cpu, cpuWeight)) * margin
+	upper := float64(weightedPercentile(samples, VPA_UPPER_PERCENTILE, cpu, cpuWeight)) * margin
+	target := int64(math.Ceil(float64(weightedPercentile(samples, VPA_TARGET_PERCENTILE, cpu, cpuWeight)) * margin))
+	decision.logf("📊 VPA: %d millicpus recommended (range %.0f-%.0f), %d requested", target, lower, upper, perpodalloc)
+	if float64(perpodalloc) < lower || float64(perpodalloc) > upper {
+		if requests := decision.boundRequests(policy, target); requests != perpodalloc {
+			decision.logf("🔄 Vertical scaling: %d -> %d millicpus", perpodalloc, requests)
+			action.CpuRequests = requests
+		}
+	}
+
+	if state.MemAllocation > 0 {
+		perpodmem := state.PerPodMemAllocation()
+		mem := func(s vpaSample) int64 { return s.MemUsage }
+		even := func(vpaSample) float64 { return 1 }
+		memLower := float64(weightedPercentile(samples, VPA_LOWER_PERCENTILE, mem, even)) * margin
+		memUpper := float64(weightedPercentile(samples, VPA_UPPER_PERCENTILE, mem, even)) * margin
+		memTarget := int64(math.Ceil(float64(weightedPercentile(samples, VPA_TARGET_PERCENTILE, mem, even)) * margin))
+		oomKilled := slices.ContainsFunc(state.Pods, func(pod PodState) bool { return pod.OOMKilled })
+		if oomKilled {
+			memTarget = max(memTarget, int64(math.Ceil(float64(perpodmem)*MEMORY_GROWTH_FACTOR)))
+		}
+		if oomKilled || float64(perpodmem) < memLower || float64(perpodmem) > memUpper {
+			if newMem := decision.boundMemory(policy, memTarget); newMem != perpodmem {
+				decision.logf("🔄 Vertical scaling: %d -> %d bytes memory", perpodmem, newMem)
+				action.MemRequests = newMem
+			}
+		}
+	}
+
+	if action.CpuRequests == 0 && action.MemRequests == 0 {
+		decision.logf("ℹ️ Requests within the recommended range - no action taken")
+		return decision
+	}
+	decision.add(action)
+	return decision
+}
+
+// the smallest value with at least p of the total weight at or below it
+func weightedPercentile(samples []vpaSample, p float64, value func(vpaSample) int64, weight func(vpaSample) float64) int64 {
+	sorted := slices.SortedFunc(slices.Values(samples), func(a, b vpaSample) int {
+		return cmp.Compare(value(a), value(b))
+	})
+	total := 0.0
+	for _, s := range sorted {
+		total += weight(s)
+	}
+	seen := 0.0
+	for _, s := range sorted {
+		seen += weight(s)
+		if seen >= p*total {
+			return value(s)
+		}
+	}
+	return 0
+}
@@ -4,11 +4,17 @@
 package autoscaler
 
 import (
+	"context"
 	"fmt"
-	"math"
+	"io"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/tholiang/podoscaler/scalers/api/v1alpha1"
+	util "github.com/tholiang/podoscaler/scalers/util"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/dynamic"
 	kube_client "k8s.io/client-go/kubernetes"
 	metrics_client "k8s.io/metrics/pkg/client/clientset/versioned"
 )
@@ -22,7 +28,47 @@ const (
 const (
 	DEFAULT_MAPS              = 500 // in millicpus
 	DEFAULT_MIN_REQUESTS      = 50  // in millicpus
-	DEFAULT_LATENCY_THRESHOLD = 40  // in milliseconds
+	DEFAULT_MIN_REPLICAS      = 1
+	DEFAULT_LATENCY_THRESHOLD = 40 // in milliseconds
+)
+
+const (
+	DEFAULT_MIN_MEMORY                 = 32 * 1024 * 1024 // in bytes
+	DEFAULT_MEMORY_PRESSURE_THRESHOLD  = 0.9              // grow when working set / requests goes above
+	DEFAULT_MEMORY_DOWNSCALE_THRESHOLD = 0.5              // shrink when working set / requests goes below
+	MEMORY_TARGET_UTILIZATION          = 0.7              // working set / requests after a memory change
+	MEMORY_GROWTH_FACTOR               = 1.5              // minimum growth on pressure or OOM
+	OOM_LOOKBACK                       = 2 * time.Minute  // OOMKills older than this were already handled
+)
+
+const (
+	DEFAULT_WORKERS            = 4
+	DEFAULT_DEPLOYMENT_TIMEOUT = 30 * time.Second // gather, plan and execute for one deployment
+	DEFAULT_GRACE_PERIOD       = 20 * time.Second // an action already started keeps going this long past a deadline or shutdown
+	DEFAULT_RESIZE_TIMEOUT     = 15 * time.Second // for the kubelet to apply one pod's in-place resize
+	DEFAULT_MIGRATION_TIMEOUT  = 30 * time.Second // for a migrated pod's replacement to become ready
+)
+
+const (
+	DEFAULT_SCALE_UP_STABILIZATION   = 0               // scale up as soon as it is recommended
+	DEFAULT_SCALE_DOWN_STABILIZATION = 5 * time.Minute // scale down once it has been recommended this long
+)
+
+const (
+	DEFAULT_FORECAST_SEASON  = 24 * time.Hour  // daily traffic pattern
+	DEFAULT_FORECAST_HORIZON = 5 * time.Minute // how far ahead to scale, roughly how long new pods take to serve
+	DEFAULT_FORECAST_WEIGHT  = 0.5             // forecast share of the demand sized for, 0 turns forecasting off
+)
+
+const (
+	DEFAULT_LATENCY_SETPOINT    = 0.8  // controller mode holds latency at this fraction of the threshold
+	DEFAULT_CONTROLLER_MAX_STEP = 0.25 // controller mode changes total cpu by at most this fraction per round
+)
+
+const (
+	DEFAULT_SCALING_POLICY         = VECTER_SCALING_POLICY
+	DEFAULT_HPA_TARGET_UTILIZATION = 0.9 // the cpu-percent the hpa baseline experiments use
+	DEFAULT_HPA_INTEROP            = RefuseHPAInterop
 )
 
 type Autoscaler struct {
@@ -31,10 +77,56 @@ type Autoscaler struct {
 	DownscaleUtilizationThreshold float64
 	Maps                          int64
 	LatencyThreshold              int64
+	LatencyTargets                []LatencyTarget // nil means p99 under LatencyThreshold
+	AvailabilityTarget            float64         // fraction of requests that have to succeed, 0 means no availability slo
+	MinReplicas                   int             // 0 means DEFAULT_MIN_REPLICAS
+	MaxReplicas                   int             // 0 means no limit
+	MinRequests                   int64           // in millicpus, 0 means DEFAULT_MIN_REQUESTS
+	MaxRequests                   int64           // in millicpus, 0 means no limit
+	Sidecars                      []string        // containers left out of measuring and resizing, nil means util.DEFAULT_SIDECARS
+	CPULimit                      CPULimitPolicy
+
+	DryRun     bool      // compute and report the round plan without changing anything
+	PlanWriter io.Writer // receives one json RoundPlan per round, may be nil
+	plan       RoundPlan
+
+	Workers           int           // deployments processed at once, 0 means DEFAULT_WORKERS
+	DeploymentTimeout time.Duration // per deployment, 0 means DEFAULT_DEPLOYMENT_TIMEOUT
+	GracePeriod       time.Duration // 0 means DEFAULT_GRACE_PERIOD
+	ResizeTimeout     time.Duration // 0 means DEFAULT_RESIZE_TIMEOUT
+	MigrationTimeout  time.Duration // 0 means DEFAULT_MIGRATION_TIMEOUT
+	migrationMu       sync.Mutex    // held across a migration's actions, node headroom is shared
+
+	ScaleUpStabilization   time.Duration               // 0 scales up right away
+	ScaleDownStabilization time.Duration               // 0 scales down right away
+	history                map[string][]Recommendation // by namespace/name, oldest first
+	historyMu              sync.Mutex
+
+	ForecastSeason  time.Duration // 0 means DEFAULT_FORECAST_SEASON
+	ForecastHorizon time.Duration
+	ForecastWeight  float64                // 0 turns forecasting off
+	forecasters     map[string]*Forecaster // by namespace/name
+	forecastMu      sync.Mutex
+
+	ScalingMode       ScalingMode            // "" means RulesScalingMode
+	LatencySetpoint   float64                // 0 means DEFAULT_LATENCY_SETPOINT
+	ControllerMaxStep float64                // 0 means DEFAULT_CONTROLLER_MAX_STEP
+	controllers       map[string]*Controller // by namespace/name
+	controllerMu      sync.Mutex
+
+	ScalingPolicy        string                   // name of the default policy, "" means DEFAULT_SCALING_POLICY
+	HPATargetUtilization float64                  // 0 means DEFAULT_HPA_TARGET_UTILIZATION
+	ScalingPolicies      map[string]ScalingPolicy // by built-in name (see ParseScalingPolicy), nil means DefaultScalingPolicies()
+	scalingPoliciesOnce  sync.Once
+
+	HPAInterop  HPAInteropMode    // "" means DEFAULT_HPA_INTEROP
+	hpaOutcomes map[string]string // last reported hpa outcome by namespace/name
+	hpaMu       sync.Mutex
 
 	Metrics          AutoscalerMetrics
 	Clientset        kube_client.Interface
 	MetricsClientset *metrics_client.Clientset
+	DynamicClient    dynamic.Interface // PodoscalerPolicies
 }
 
 func (a *Autoscaler) Init() error {
@@ -54,280 +146,364 @@ func (a *Autoscaler) Init() error {
 	if err != nil {
 		return err
 	}
+	a.DynamicClient, err = a.Metrics.GetDynamicClient(config)
+	if err != nil {
+		return err
+	}
 
 	// set env variable for Prometheus service url
 	os.Setenv("PROMETHEUS_URL", a.PrometheusUrl)
 	return nil
 }
 
-func (a *Autoscaler) RunRound() error {
+// once ctx is done no new policy or action is started, the ones in flight finish within the grace period
+func (a *Autoscaler) RunRound(ctx context.Context) error {
 	fmt.Printf("\n=== Autoscaler Round %s ===\n", time.Now().Format(time.RFC3339))
+	if a.DryRun {
+		fmt.Printf("🧪 Dry run - no changes will be made\n")
+	}
+	a.startPlan()
+	defer a.writePlan()
 
-	// get node usages
+	// get node usages, read once per round and shared by every deployment
 	fmt.Printf("\nGetting node usages...\n")
-	nodelist, err := a.Metrics.GetNodeList(a.Clientset)
+	nodelist, err := a.Metrics.GetNodeList(ctx, a.Clientset)
 	if err != nil {
 		fmt.Printf("❌ ERROR: Failed to get node list: %s\n", err.Error())
 		return err
 	}
 
+	nodes := map[string]NodeState{}
 	for _, node := range nodelist.Items {
-		nodeName := node.Name
-		usage, err := a.Metrics.GetNodeUsage(a.MetricsClientset, nodeName)
-		if err != nil {
-			fmt.Printf("❌ ERROR: Failed to get usage for node %s: %s\n", nodeName, err.Error())
-			continue
-		}
-
-		allocable, capacity, err := a.Metrics.GetNodeAllocableAndCapacity(a.Clientset, nodeName)
+		nodeState, err := a.getNodeState(ctx, node.Name)
 		if err != nil {
-			fmt.Printf("❌ ERROR: Failed to get node metrics for node %s: %s\n", nodeName, err.Error())
+			fmt.Printf("❌ ERROR: %s\n", err.Error())
 			continue
 		}
+		nodes[node.Name] = nodeState
 
-		fmt.Printf("%s: %d in use, %d allocable, %d capacity\n", nodeName, usage, allocable, capacity)
+		fmt.Printf("%s: %d in use, %d allocable, %d capacity\n", node.Name, nodeState.Usage, nodeState.Allocable, nodeState.Capacity)
 	}
 
-	// Get all deployments in the namespace
-	deployments, err := a.Metrics.GetControlledDeployments(a.Clientset)
+	// every PodoscalerPolicy selects one deployment to scale
+	policies, err := a.Metrics.GetPodoscalerPolicies(ctx, a.DynamicClient)
 	if err != nil {
-		fmt.Printf("❌ ERROR: Failed to get deployments: %s\n", err.Error())
+		fmt.Printf("❌ ERROR: Failed to get podoscaler policies: %s\n", err.Error())
 		return err
 	}
 
-	for _, deployment := range deployments.Items {
-		deploymentName := deployment.Name
-		deploymentNamespace := deployment.Namespace
-		fmt.Printf("\n📦 Processing deployment: %s\n", deploymentName)
-
-		podList, err := a.Metrics.GetReadyPodListForDeployment(a.Clientset, deploymentName, deploymentNamespace)
-		if err != nil {
-			fmt.Printf("❌ ERROR: Failed to get pod list for deployment %s: %s\n", deploymentName, err.Error())
-			continue
-		}
-
-		unschedulablePodList, err := a.Metrics.GetUnschedulablePodListForDeployment(a.Clientset, deploymentName, deploymentNamespace)
-		if err != nil {
-			fmt.Printf("❌ ERROR: Failed to get unschedulable pod list for deployment %s: %s\n", deploymentName, err.Error())
-		} else {
-			if len(unschedulablePodList) > 0 {
-				fmt.Print("⚠️ Unschedulable pods: ")
-				for _, pod := range unschedulablePodList {
-					fmt.Printf("%s ", pod.Name)
-				}
-				fmt.Println()
+	// duplicates are settled up front so the outcome does not depend on which worker is faster
+	owners := claimTargets(policies.Items)
+	plans := make([]DeploymentPlan, len(policies.Items))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(a.workers(), len(policies.Items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				deploymentCtx, cancel := context.WithTimeout(ctx, a.deploymentTimeout())
+				plans[i] = a.reconcilePolicy(deploymentCtx, &policies.Items[i], owners[i], nodes)
+				cancel()
 			}
+		}()
+	}
+	dispatched := 0
+	for dispatched < len(policies.Items) && ctx.Err() == nil {
+		select {
+		case jobs <- dispatched:
+			dispatched++
+		case <-ctx.Done():
 		}
+	}
+	close(jobs)
+	wg.Wait()
+	a.plan.Deployments = append(a.plan.Deployments, plans[:dispatched]...)
 
-		utilization, alloc, err := a.Metrics.GetDeploymentUtilAndAlloc(a.Clientset, a.MetricsClientset, deploymentName, deploymentNamespace, podList)
-		if err != nil {
-			fmt.Printf("❌ ERROR: Failed to get utilization metrics for deployment %s: %s\n", deploymentName, err.Error())
-			continue
+	if ctx.Err() != nil {
+		fmt.Printf("⚠️ Round interrupted, %d of %d policies not reconciled\n", len(policies.Items)-dispatched, len(policies.Items))
+		return fmt.Errorf("round interrupted: %w", ctx.Err())
+	}
+	fmt.Printf("\n=== Round completed ===\n\n")
+	return nil
+}
+
+func (a *Autoscaler) workers() int {
+	if a.Workers > 0 {
+		return a.Workers
+	}
+	return DEFAULT_WORKERS
+}
+
+func (a *Autoscaler) deploymentTimeout() time.Duration {
+	if a.DeploymentTimeout > 0 {
+		return a.DeploymentTimeout
+	}
+	return DEFAULT_DEPLOYMENT_TIMEOUT
+}
+
+func (a *Autoscaler) gracePeriod() time.Duration {
+	if a.GracePeriod > 0 {
+		return a.GracePeriod
+	}
+	return DEFAULT_GRACE_PERIOD
+}
+
+func (a *Autoscaler) resizeTimeout() time.Duration {
+	if a.ResizeTimeout > 0 {
+		return a.ResizeTimeout
+	}
+	return DEFAULT_RESIZE_TIMEOUT
+}
+
+func (a *Autoscaler) forecastSeason() time.Duration {
+	if a.ForecastSeason > 0 {
+		return a.ForecastSeason
+	}
+	return DEFAULT_FORECAST_SEASON
+}
+
+func (a *Autoscaler) migrationTimeout() time.Duration {
+	if a.MigrationTimeout > 0 {
+		return a.MigrationTimeout
+	}
+	return DEFAULT_MIGRATION_TIMEOUT
+}
+
+// a context that outlives ctx by the grace period, for work that should not be cut off halfway
+// (a resize across several pods, the status write that reports a deadline)
+func (a *Autoscaler) graceContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	graceCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		timer := time.NewTimer(a.gracePeriod())
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel()
+		case <-graceCtx.Done():
 		}
-		utilPercent := float64(utilization) / float64(alloc)
-		fmt.Printf("📊 Current state: %d/%d millicpus (%.1f%%)\n", utilization, alloc, utilPercent*100)
+	})
+	return graceCtx, func() {
+		stop()
+		cancel()
+	}
+}
 
-		numPods := len(podList)
-		idealReplicaCt := int(math.Ceil(float64(utilization) / float64(a.Maps)))
-		newRequests := int64(math.Ceil(float64(utilization) / float64(idealReplicaCt)))
+// gather -> plan -> execute for one deployment, recorded in dplan
+// spec is the deployment's PodoscalerPolicy, layered over its annotations, nodes is the round's node snapshot
+func (a *Autoscaler) processDeployment(ctx context.Context, deployment *appsv1.Deployment, spec *v1alpha1.PodoscalerPolicySpec, nodes map[string]NodeState, dplan *DeploymentPlan) error {
+	fmt.Printf("\n📦 Processing deployment: %s\n", deployment.Name)
+
+	policy, err := a.ResolvePolicy(deployment, spec)
+	if err != nil {
+		return fmt.Errorf("skipping deployment %s: %w", deployment.Name, err)
+	}
+	scalingPolicy := a.scalingPolicy(policy.ScalingPolicy)
+	if scalingPolicy == nil {
+		return fmt.Errorf("skipping deployment %s: %w: unknown scaling policy %q", deployment.Name, ErrInvalidPolicy, policy.ScalingPolicy)
+	}
+	dplan.ScalingPolicy = scalingPolicy.Name()
+	policy, pinReplicas, err := a.interopHPA(ctx, deployment, policy, dplan)
+	if err != nil {
+		return fmt.Errorf("skipping deployment %s: %w", deployment.Name, err)
+	}
 
-		perpodalloc := int64(math.Ceil(float64(alloc) / float64(numPods)))
+	state, err := a.gatherDeploymentState(ctx, deployment, policy, nodes)
+	if err != nil {
+		return err
+	}
+	if pinReplicas { // the hpa's to change
+		state.Policy.MinReplicas, state.Policy.MaxReplicas = max(len(state.Pods), 1), max(len(state.Pods), 1)
+	}
+	dplan.Replicas = len(state.Pods)
+	dplan.CpuRequests = state.PerPodAllocation()
+	dplan.Utilization = state.Utilization
+	dplan.Allocation = state.Allocation
+	dplan.MemRequests = state.PerPodMemAllocation()
+	dplan.MemUsage = state.MemUsage
+	dplan.SLOViolated = state.SLOViolated()
+	if state.LatencyErr == nil {
+		latency := state.Latencies[DEFAULT_SLO_PERCENTILE]
+		dplan.Latency = &latency
+		dplan.Latencies = state.Latencies
+	}
+	dplan.SLOScore = state.SLOScore()
+	if state.TrafficErr == nil {
+		dplan.RequestRate, dplan.ErrorRate = state.Traffic.Requests, state.Traffic.Errors
+	}
+	now := time.Now()
+	state.Forecast = a.forecast(state, now)
+	dplan.Forecast = state.Forecast
+	state.ControlTarget = a.control(state)
+	dplan.ControlTarget = state.ControlTarget
+	dplan.observed = true
+
+	decision := a.stabilize(state, scalingPolicy.Plan(state, now), now)
+	for _, line := range decision.Log {
+		fmt.Println(line)
+	}
+	dplan.Actions = decision.Actions
 
-		slovio, slo_err := a.isSLOViolated(deploymentName)
+	if a.DryRun {
+		return nil
+	}
+	return a.execute(ctx, state, decision.Actions, dplan)
+}
 
-		if (slovio || slo_err != nil) && utilPercent > 1 {
-			fmt.Printf("⚠️ SLO violation detected for %s\n", deploymentName)
-			// hscale
-			if idealReplicaCt < 1 {
-				fmt.Printf("ℹ️ Ideal replica count (%d) < 1 - no action taken\n", idealReplicaCt)
-				continue
-			}
+// read everything the planner needs for this deployment
+func (a *Autoscaler) gatherDeploymentState(ctx context.Context, deployment *appsv1.Deployment, policy DeploymentPolicy, nodes map[string]NodeState) (DeploymentState, error) {
+	deploymentName := deployment.Name
+	deploymentNamespace := deployment.Namespace
+	state := DeploymentState{Name: deploymentName, Namespace: deploymentNamespace, Policy: policy, Nodes: map[string]NodeState{}}
+	state.NodeSelector = deployment.Spec.Template.Spec.NodeSelector
+	state.Tolerations = deployment.Spec.Template.Spec.Tolerations
 
-			if idealReplicaCt > numPods { // hscale first (total increase) then vscale (possible decrease)
-				fmt.Printf("🔄 Horizontal scaling: %d -> %d replicas\n", numPods, idealReplicaCt)
-				err = a.hScale(idealReplicaCt, deploymentName, deploymentNamespace)
-				if err != nil {
-					fmt.Printf("❌ ERROR: Failed to hscale deployment %s: %s\n", deploymentName, err.Error())
-					continue
-				}
-				fmt.Printf("🔄 Vertical scaling: %d -> %d millicpus\n", perpodalloc, newRequests)
-				err = a.vScaleTo(newRequests, deploymentName, deploymentNamespace)
-				if err != nil {
-					fmt.Printf("❌ ERROR: Failed to vscale deployment %s: %s\n", deploymentName, err.Error())
-					continue
-				}
-				continue
-			} else if idealReplicaCt < numPods { // vscale first (total increase) then hscale (decrease)
-				fmt.Printf("🔄 Vertical scaling: %d -> %d millicpus\n", perpodalloc, newRequests)
-				err = a.vScaleTo(newRequests, deploymentName, deploymentNamespace)
-				if err != nil {
-					fmt.Printf("❌ ERROR: Failed to vscale deployment %s: %s\n", deploymentName, err.Error())
-					continue
-				}
-				fmt.Printf("🔄 Horizontal scaling: %d -> %d replicas\n", numPods, idealReplicaCt)
-				err = a.hScale(idealReplicaCt, deploymentName, deploymentNamespace)
-				if err != nil {
-					fmt.Printf("❌ ERROR: Failed to hscale deployment %s: %s\n", deploymentName, err.Error())
-					continue
-				}
-
-				// have to vscale new pods again
-				fmt.Printf("🔄 Vertical scaling: %d -> %d millicpus\n", perpodalloc, newRequests)
-				err = a.vScaleTo(newRequests, deploymentName, deploymentNamespace)
-				if err != nil {
-					fmt.Printf("❌ ERROR: Failed to vscale deployment %s: %s\n", deploymentName, err.Error())
-					continue
-				}
-				continue
-			}
+	podList, err := a.Metrics.GetReadyPodListForDeployment(ctx, a.Clientset, deploymentName, deploymentNamespace)
+	if err != nil {
+		return state, fmt.Errorf("failed to get pod list for deployment %s: %w", deploymentName, err)
+	}
 
-			// vscale
-			if newRequests < perpodalloc {
-				fmt.Printf("ℹ️ New requests (%d) < per pod alloc (%d) - no action taken\n", newRequests, perpodalloc)
-				continue
+	unschedulablePodList, err := a.Metrics.GetUnschedulablePodListForDeployment(ctx, a.Clientset, deploymentName, deploymentNamespace)
+	if err != nil {
+		fmt.Printf("❌ ERROR: Failed to get unschedulable pod list for deployment %s: %s\n", deploymentName, err.Error())
+	} else {
+		if len(unschedulablePodList) > 0 {
+			fmt.Print("⚠️ Unschedulable pods: ")
+			for _, pod := range unschedulablePodList {
+				fmt.Printf("%s ", pod.Name)
 			}
+			fmt.Println()
+		}
+	}
 
-			hasNoCongested := true
-			moveFailed := false
-			for _, pod := range podList {
-				usage, err := a.Metrics.GetNodeUsage(a.MetricsClientset, pod.Spec.NodeName)
-				if err != nil {
-					fmt.Printf("❌ ERROR: Failed to get node usage for pod %s: %s\n", pod.Name, err.Error())
-					continue
-				}
-
-				allocable, capacity, err := a.Metrics.GetNodeAllocableAndCapacity(a.Clientset, pod.Spec.NodeName)
-				if err != nil {
-					fmt.Printf("❌ ERROR: Failed to get node metrics for pod %s: %s\n", pod.Name, err.Error())
-					continue
-				}
-
-				availableCPU := min(capacity-usage, allocable)
-				availablePercentage := float64(availableCPU) / float64(capacity)
-				if availablePercentage > a.MinNodeAvailabilityThreshold {
-					continue
-				}
-				hasNoCongested = false
-
-				idx := 0
-				if pod.Spec.Containers[0].Name == "linkerd-proxy" {
-					idx = 1
-				}
-				currentRequests := pod.Spec.Containers[idx].Resources.Requests.Cpu().MilliValue()
-				additionalAllocation := newRequests - currentRequests
-				if additionalAllocation > allocable {
-					fmt.Printf("🔄 Node migration: Moving pod %s to uncongested node\n", pod.Name)
-					err = a.hScale(idealReplicaCt+1, deploymentName, deploymentNamespace)
-					if err != nil {
-						fmt.Printf("❌ ERROR: Failed to add affinity pod for deployment %s: %s\n", deploymentName, err.Error())
-						moveFailed = true
-						break
-					}
-					a.Metrics.DeletePod(a.Clientset, pod.Name, deploymentNamespace)
-					err = a.hScale(idealReplicaCt, deploymentName, deploymentNamespace)
-					if err != nil {
-						fmt.Printf("❌ ERROR: Failed to remove affinity pod for deployment %s: %s\n", deploymentName, err.Error())
-						continue
-					}
-				}
-			}
+	state.Utilization, state.Allocation, err = a.Metrics.GetDeploymentUtilAndAlloc(ctx, a.Clientset, a.MetricsClientset, deploymentName, deploymentNamespace, podList, policy.Sidecars)
+	if err != nil {
+		return state, fmt.Errorf("failed to get utilization metrics for deployment %s: %w", deploymentName, err)
+	}
 
-			if moveFailed {
-				fmt.Printf("❌ ERROR: Failed to move pod for deployment %s - assuming no available node space\n", deploymentName)
-				continue
-			} else if hasNoCongested {
-				fmt.Printf("ℹ️ External bottleneck detected for %s - no action taken\n", deploymentName)
-				continue
-			} else {
-				fmt.Printf("🔄 Vertical scaling: %d -> %d millicpus\n", perpodalloc, newRequests)
-				err = a.vScaleTo(newRequests, deploymentName, deploymentNamespace)
-				if err != nil {
-					fmt.Printf("❌ ERROR: Failed to vscale deployment %s: %s\n", deploymentName, err.Error())
-					continue
-				}
-			}
-		} else if (!slovio && slo_err == nil) && utilPercent < a.DownscaleUtilizationThreshold {
-			idealReplicaCt = max(idealReplicaCt, 1)
-			if idealReplicaCt < numPods {
-				fmt.Printf("🔄 Downscaling: %d -> %d replicas\n", numPods, idealReplicaCt)
-				err = a.hScale(max(idealReplicaCt, 1), deploymentName, deploymentNamespace)
-				if err != nil {
-					fmt.Printf("❌ ERROR: Failed to hscale down deployment %s: %s\n", deploymentName, err.Error())
-					continue
-				}
-			}
+	containerUsage, err := a.Metrics.GetPodContainerUsage(ctx, a.Clientset, a.MetricsClientset, deploymentName, deploymentNamespace)
+	if err != nil {
+		return state, fmt.Errorf("failed to get container metrics for deployment %s: %w", deploymentName, err)
+	}
+	oomSince := time.Now().Add(-OOM_LOOKBACK)
 
-			hysteresisMargin := 1 / a.DownscaleUtilizationThreshold
-			newRequests = int64(math.Ceil(float64(newRequests) * hysteresisMargin))
-			newRequests = max(newRequests, DEFAULT_MIN_REQUESTS)
-			if newRequests == perpodalloc {
+	for _, pod := range podList {
+		containers := []ContainerState{}
+		for _, container := range pod.Spec.Containers {
+			if util.IsSidecar(container.Name, policy.Sidecars) {
 				continue
 			}
+			usage := containerUsage[pod.Name][container.Name]
+			containers = append(containers, ContainerState{
+				Name:        container.Name,
+				CpuRequests: container.Resources.Requests.Cpu().MilliValue(),
+				CpuUsage:    usage.CPU,
+				MemRequests: container.Resources.Requests.Memory().Value(),
+				MemUsage:    usage.Memory,
+				OOMKilled:   util.RecentlyOOMKilled(pod, container.Name, oomSince),
+			})
+		}
+		podState := NewPodState(pod.Name, pod.Spec.NodeName, containers)
+		state.Pods = append(state.Pods, podState)
+		state.MemAllocation += podState.MemRequests
+		state.MemUsage += podState.MemUsage
 
-			fmt.Printf("🔄 Downscaling: %d -> %d millicpus\n", perpodalloc, newRequests)
-			err = a.vScaleTo(newRequests, deploymentName, deploymentNamespace)
+		if _, ok := state.Nodes[pod.Spec.NodeName]; ok {
+			continue
+		}
+		nodeState, ok := nodes[pod.Spec.NodeName]
+		if !ok {
+			// only a node that joined after the round started, or failed to read, is missing from the snapshot
+			nodeState, err = a.getNodeState(ctx, pod.Spec.NodeName)
 			if err != nil {
-				fmt.Printf("❌ ERROR: Failed to vscale down deployment %s: %s\n", deploymentName, err.Error())
+				fmt.Printf("❌ ERROR: Failed to get node state for pod %s: %s\n", pod.Name, err.Error())
 				continue
 			}
 		}
+		state.Nodes[pod.Spec.NodeName] = nodeState
 	}
 
-	fmt.Printf("\n=== Round completed ===\n\n")
-	return nil
+	// every other schedulable node is somewhere a pod could be migrated to
+	for name, nodeState := range nodes {
+		if _, ok := state.Nodes[name]; ok || nodeState.Unschedulable {
+			continue
+		}
+		state.Nodes[name] = nodeState
+	}
+
+	state.Latencies, state.LatencyErr = a.getLatencies(ctx, deploymentName, policy)
+	state.Traffic, state.TrafficErr = a.getTraffic(ctx, deploymentName, policy)
+	return state, nil
 }
 
-func (a *Autoscaler) isSLOViolated(deploymentName string) (bool, error) {
-	metrics, err := a.Metrics.GetLatencyMetrics(a.Clientset)
+func (a *Autoscaler) getNodeState(ctx context.Context, nodeName string) (NodeState, error) {
+	usage, err := a.Metrics.GetNodeUsage(ctx, a.MetricsClientset, nodeName)
 	if err != nil {
-		fmt.Printf("❌ ERROR: Failed to get latency metrics for %s: %s\n", deploymentName, err.Error())
-		return false, err
+		return NodeState{}, fmt.Errorf("failed to get usage for node %s: %w", nodeName, err)
 	}
 
-	if len(metrics) == 0 {
-		fmt.Printf("ℹ️ No latency metrics found for deployment %s\n", deploymentName)
-		return false, err
+	allocable, capacity, err := a.Metrics.GetNodeAllocableAndCapacity(ctx, a.Clientset, nodeName)
+	if err != nil {
+		return NodeState{}, fmt.Errorf("failed to get node metrics for node %s: %w", nodeName, err)
 	}
-	latency := metrics["p99"] * 1000 // convert from s to ms
-	dist := latency / float64(a.LatencyThreshold)
-	fmt.Printf("📊 Latency metrics: %.2fms (threshold: %dms)\n", latency, a.LatencyThreshold)
 
-	return dist > 1, nil
+	scheduling, err := a.Metrics.GetNodeScheduling(ctx, a.Clientset, nodeName)
+	if err != nil {
+		return NodeState{}, fmt.Errorf("failed to get scheduling info for node %s: %w", nodeName, err)
+	}
+
+	return NodeState{
+		Name:          nodeName,
+		Usage:         usage,
+		Allocable:     allocable,
+		Capacity:      capacity,
+		MemAllocable:  scheduling.MemAllocable,
+		Labels:        scheduling.Labels,
+		Taints:        scheduling.Taints,
+		Unschedulable: scheduling.Unschedulable,
+	}, nil
 }
 
-// in-place scale all pods to the given CPU request
-func (a *Autoscaler) vScaleTo(millis int64, deploymentName string, deploymentNamespace string) error {
-	podList, err := a.Metrics.GetReadyPodListForDeployment(a.Clientset, deploymentName, deploymentNamespace)
+// latency percentiles in milliseconds from the deployment's own latency source
+// every percentile the slo targets has to be there, unless there were no datapoints at all
+func (a *Autoscaler) getLatencies(ctx context.Context, deploymentName string, policy DeploymentPolicy) (map[string]float64, error) {
+	metrics, err := a.Metrics.GetLatencyMetrics(ctx, a.Clientset, policy.LatencySource)
 	if err != nil {
-		return err
+		fmt.Printf("❌ ERROR: Failed to get latency metrics for %s: %s\n", deploymentName, err.Error())
+		return nil, err
 	}
 
-	containeridx := 0
-	reqstr := fmt.Sprintf("%dm", millis)
-	for _, pod := range podList {
-		idx := 0
-		if pod.Spec.Containers[0].Name == "linkerd-proxy" {
-			idx = 1
-			containeridx = 1
-		}
-		container := pod.Spec.Containers[idx] // TODO: handle multiple containers
-		err = a.Metrics.VScale(a.Clientset, pod.Name, container.Name, reqstr, deploymentNamespace)
-		if err != nil {
-			fmt.Printf("Failed to vscale pod %s: %s\n", pod.Name, err.Error())
-			return err
+	latencies := map[string]float64{}
+	if len(metrics) == 0 {
+		fmt.Printf("ℹ️ No latency metrics found for deployment %s\n", deploymentName)
+		return latencies, nil
+	}
+	for percentile, seconds := range metrics {
+		latencies[percentile] = seconds * 1000 // convert from s to ms
+	}
+	for _, target := range policy.SLO() {
+		if _, ok := latencies[target.Percentile]; !ok {
+			err := fmt.Errorf("latency source %s has no %s for the slo", policy.LatencySource, target.Percentile)
+			fmt.Printf("❌ ERROR: %s\n", err.Error())
+			return nil, err
 		}
 	}
+	return latencies, nil
+}
 
-	err = a.Metrics.PatchDeploymentReqs(a.Clientset, deploymentName, containeridx, reqstr, deploymentNamespace)
+// requests and 5xx per second, from the latency source's load balancer or the policy's promql
+// only read for an availability slo, so without a target a promql source needs no queries
+func (a *Autoscaler) getTraffic(ctx context.Context, deploymentName string, policy DeploymentPolicy) (util.Traffic, error) {
+	if policy.AvailabilityTarget == 0 {
+		return util.Traffic{}, nil
+	}
+	if policy.LatencySource.Type == PromQLLatencySource && (policy.RequestsQuery == "" || policy.ErrorsQuery == "") {
+		return util.Traffic{}, nil
+	}
+	traffic, err := a.Metrics.GetTrafficMetrics(ctx, a.Clientset, policy.LatencySource, policy.RequestsQuery, policy.ErrorsQuery)
 	if err != nil {
-		fmt.Printf("Failed to vscale deployment %s: %s\n", deploymentName, err.Error())
-		return err
+		fmt.Printf("❌ ERROR: Failed to get traffic metrics for %s: %s\n", deploymentName, err.Error())
+		return util.Traffic{}, err
 	}
-
-	return nil
-}
-
-// is blocking (see `hScaleFromHSR`)
-func (a *Autoscaler) hScale(idealReplicaCt int, deploymentName string, deploymentNamespace string) error {
-	return a.Metrics.ChangeReplicaCount(deploymentNamespace, deploymentName, idealReplicaCt, a.Clientset)
+	return traffic, nil
 }
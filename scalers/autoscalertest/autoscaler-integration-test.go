@@ -4,6 +4,7 @@
 package autoscalertest
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -12,8 +13,9 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-func integration_make_autoscaler(node_avail_threshold float64, downscale_threshold float64, namespace string, Maps int64, LatencyThreshold int64, mm *MockMetrics) (autoscaler.Autoscaler, error) {
-	a := autoscaler.Autoscaler{
+func integration_make_autoscaler(node_avail_threshold float64, downscale_threshold float64, namespace string, Maps int64, LatencyThreshold int64, mm *MockMetrics) (*autoscaler.Autoscaler, error) {
+	ctx := context.Background()
+	a := &autoscaler.Autoscaler{
 		PrometheusUrl:                 util.DEFAULT_PROMETHEUS_URL,
 		MinNodeAvailabilityThreshold:  node_avail_threshold,
 		DownscaleUtilizationThreshold: downscale_threshold,
@@ -26,7 +28,7 @@ func integration_make_autoscaler(node_avail_threshold float64, downscale_thresho
 		return a, err
 	}
 
-	_, node_cap, err := util.GetNodeAllocableAndCapacity(a.Clientset, "minikube")
+	_, node_cap, err := util.GetNodeAllocableAndCapacity(ctx, util.NewAPIReader(a.Clientset), "minikube")
 	IntAssertNoError(err)
 	mm.NodeCapacities = map[string]int64{
 		"minikube": node_cap, // workaround
@@ -36,21 +38,22 @@ func integration_make_autoscaler(node_avail_threshold float64, downscale_thresho
 }
 
 func reset_dummy(clientset kubernetes.Interface) {
+	ctx := context.Background()
 	fmt.Println("<<< reseting dummy deployment >>>")
-	err := util.ChangeReplicaCount("default", "dummy", 1, clientset)
+	err := util.ChangeReplicaCount(ctx, "default", "dummy", 1, clientset)
 	IntAssertNoError(err)
 
-	podlist, err := util.GetReadyPodListForDeployment(clientset, "dummy", "default")
+	podlist, err := util.GetReadyPodListForDeployment(ctx, util.NewAPIReader(clientset), "dummy", "default")
 	IntAssertNoError(err)
 	IntAssertIntsEqual(1, len(podlist))
 
 	podname := podlist[0].Name
-	err = util.VScale(clientset, podname, "dummy-container", "300m", "default")
+	err = util.VScale(ctx, clientset, podname, "dummy-container", util.VerticalPatchContainerResources{Requests: util.VerticalPatchResourceSpec{CPU: "300m"}}, "default")
 	IntAssertNoError(err)
 
 	time.Sleep(100 * time.Millisecond)
 
-	podlist, err = util.GetReadyPodListForDeployment(clientset, "dummy", "default")
+	podlist, err = util.GetReadyPodListForDeployment(ctx, util.NewAPIReader(clientset), "dummy", "default")
 	IntAssertNoError(err)
 	IntAssertIntsEqual(1, len(podlist))
 	newsize := podlist[0].Spec.Containers[0].Resources.Requests.Cpu().MilliValue()
@@ -61,28 +64,29 @@ func reset_dummy(clientset kubernetes.Interface) {
 }
 
 func add_dummy_pod(clientset kubernetes.Interface) {
+	ctx := context.Background()
 	fmt.Println("<<< \"manually\" adding new dummy pod >>>")
-	err := util.ChangeReplicaCount("default", "dummy", 1, clientset)
+	err := util.ChangeReplicaCount(ctx, "default", "dummy", 1, clientset)
 	IntAssertNoError(err)
 
-	podlist, err := util.GetReadyPodListForDeployment(clientset, "dummy", "default")
+	podlist, err := util.GetReadyPodListForDeployment(ctx, util.NewAPIReader(clientset), "dummy", "default")
 	IntAssertNoError(err)
 	starting_pod_ct := len(podlist)
 
-	err = util.ChangeReplicaCount("default", "dummy", starting_pod_ct+1, clientset)
+	err = util.ChangeReplicaCount(ctx, "default", "dummy", starting_pod_ct+1, clientset)
 	IntAssertNoError(err)
 
 	// wait for hscale to work
 	timeout_millis := 5000
 	for range timeout_millis / 500 { // gross
-		podlist, err := util.GetReadyPodListForDeployment(clientset, "dummy", "default")
+		podlist, err := util.GetReadyPodListForDeployment(ctx, util.NewAPIReader(clientset), "dummy", "default")
 		IntAssertNoError(err)
 		if len(podlist) == starting_pod_ct+1 {
 			break
 		}
 		time.Sleep(500 * time.Millisecond)
 	}
-	podlist, err = util.GetReadyPodListForDeployment(clientset, "dummy", "default")
+	podlist, err = util.GetReadyPodListForDeployment(ctx, util.NewAPIReader(clientset), "dummy", "default")
 	IntAssertNoError(err)
 	IntAssertIntsEqual(starting_pod_ct+1, len(podlist))
 
@@ -102,6 +106,7 @@ func add_dummy_pod(clientset kubernetes.Interface) {
 /* all start with 1 pod at 300m cpu */
 
 func IntegrationTest_BasicStable() {
+	ctx := context.Background()
 	name := "Test_BasicStable"
 	fmt.Printf("<<< %s >>>\n", name)
 
@@ -114,7 +119,7 @@ func IntegrationTest_BasicStable() {
 
 	reset_dummy(a.Clientset)
 
-	err = a.RunRound()
+	err = a.RunRound(ctx)
 	IntAssertNoError(err)
 
 	IntSummarizeActions(mm)
@@ -124,6 +129,7 @@ func IntegrationTest_BasicStable() {
 }
 
 func IntegrationTest_BasicVscaleUp() {
+	ctx := context.Background()
 	name := "Test_BasicVscaleUp"
 	fmt.Printf("<<< %s >>>\n", name)
 
@@ -146,7 +152,7 @@ func IntegrationTest_BasicVscaleUp() {
 
 	reset_dummy(a.Clientset)
 
-	err = a.RunRound()
+	err = a.RunRound(ctx)
 	IntAssertNoError(err)
 
 	IntSummarizeActions(mm)
@@ -154,7 +160,7 @@ func IntegrationTest_BasicVscaleUp() {
 	IntAssertAction(mm, Action{Type: VscaleAction, PodName: "pod", ContainerName: "container", CpuRequests: "330m"})
 	IntAssertNoActions(mm)
 
-	podlist, err := util.GetReadyPodListForDeployment(a.Clientset, mm.DeploymentName, mm.DeploymentNamespace)
+	podlist, err := util.GetReadyPodListForDeployment(ctx, util.NewAPIReader(a.Clientset), mm.DeploymentName, mm.DeploymentNamespace)
 	IntAssertNoError(err)
 	IntAssertPodListsEqual(podlist, correctEndPods)
 
@@ -162,6 +168,7 @@ func IntegrationTest_BasicVscaleUp() {
 }
 
 func IntegrationTest_BasicHscaleUp() {
+	ctx := context.Background()
 	name := "Test_BasicHscaleUp"
 	fmt.Printf("<<< %s >>>\n", name)
 
@@ -185,7 +192,7 @@ func IntegrationTest_BasicHscaleUp() {
 
 	reset_dummy(a.Clientset)
 
-	err = a.RunRound()
+	err = a.RunRound(ctx)
 	IntAssertNoError(err)
 
 	IntSummarizeActions(mm)
@@ -195,7 +202,7 @@ func IntegrationTest_BasicHscaleUp() {
 	IntAssertAction(mm, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "450m"})
 	IntAssertNoActions(mm)
 
-	podlist, err := util.GetReadyPodListForDeployment(a.Clientset, mm.DeploymentName, mm.DeploymentNamespace)
+	podlist, err := util.GetReadyPodListForDeployment(ctx, util.NewAPIReader(a.Clientset), mm.DeploymentName, mm.DeploymentNamespace)
 	IntAssertNoError(err)
 	IntAssertPodListsEqual(podlist, correctEndPods)
 
@@ -203,6 +210,7 @@ func IntegrationTest_BasicHscaleUp() {
 }
 
 func IntegrationTest_BasicVscaleDown() {
+	ctx := context.Background()
 	name := "Test_BasicVscaleDown"
 	fmt.Printf("<<< %s >>>\n", name)
 
@@ -222,7 +230,7 @@ func IntegrationTest_BasicVscaleDown() {
 
 	reset_dummy(a.Clientset)
 
-	err = a.RunRound()
+	err = a.RunRound(ctx)
 	IntAssertNoError(err)
 
 	IntSummarizeActions(mm)
@@ -230,7 +238,7 @@ func IntegrationTest_BasicVscaleDown() {
 	IntAssertAction(mm, Action{Type: VscaleAction, PodName: "pod", ContainerName: "container", CpuRequests: "177m"})
 	IntAssertNoActions(mm)
 
-	podlist, err := util.GetReadyPodListForDeployment(a.Clientset, mm.DeploymentName, mm.DeploymentNamespace)
+	podlist, err := util.GetReadyPodListForDeployment(ctx, util.NewAPIReader(a.Clientset), mm.DeploymentName, mm.DeploymentNamespace)
 	IntAssertNoError(err)
 	IntAssertPodListsEqual(podlist, correctEndPods)
 
@@ -238,6 +246,7 @@ func IntegrationTest_BasicVscaleDown() {
 }
 
 func IntegrationTest_BasicHscaleDown() {
+	ctx := context.Background()
 	name := "Test_BasicHscaleDown"
 	fmt.Printf("<<< %s >>>\n", name)
 
@@ -258,7 +267,7 @@ func IntegrationTest_BasicHscaleDown() {
 	reset_dummy(a.Clientset)
 	add_dummy_pod(a.Clientset) // start two pods at 300m
 
-	err = a.RunRound()
+	err = a.RunRound(ctx)
 	IntAssertNoError(err)
 
 	IntSummarizeActions(mm)
@@ -267,7 +276,7 @@ func IntegrationTest_BasicHscaleDown() {
 	IntAssertAction(mm, Action{Type: VscaleAction, PodName: "pod", ContainerName: "container", CpuRequests: "353m"})
 	IntAssertNoActions(mm)
 
-	podlist, err := util.GetReadyPodListForDeployment(a.Clientset, mm.DeploymentName, mm.DeploymentNamespace)
+	podlist, err := util.GetReadyPodListForDeployment(ctx, util.NewAPIReader(a.Clientset), mm.DeploymentName, mm.DeploymentNamespace)
 	IntAssertNoError(err)
 	IntAssertPodListsEqual(podlist, correctEndPods)
 
@@ -4,9 +4,27 @@
 package autoscalertest
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/tholiang/podoscaler/scalers/api/v1alpha1"
 	"github.com/tholiang/podoscaler/scalers/autoscaler"
+	"github.com/tholiang/podoscaler/scalers/util"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	kube_client "k8s.io/client-go/kubernetes"
+	metrics_client "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 func UnitMakeAutoscaler(node_avail_threshold float64, downscale_threshold float64, namespace string, Maps int64, LatencyThreshold int64, metrics autoscaler.AutoscalerMetrics) autoscaler.Autoscaler {
@@ -30,7 +48,7 @@ func TestUnit_BasicStable(t *testing.T) {
 	err := a.Init()
 	AssertNoError(err, t)
 
-	err = a.RunRound()
+	err = a.RunRound(t.Context())
 	AssertNoError(err, t)
 
 	AssertNoActions(mm, t)
@@ -58,7 +76,7 @@ func TestUnit_BasicVscaleUp(t *testing.T) {
 	err := a.Init()
 	AssertNoError(err, t)
 
-	err = a.RunRound()
+	err = a.RunRound(t.Context())
 	AssertNoError(err, t)
 
 	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "330m"})
@@ -92,7 +110,7 @@ func TestUnit_BasicHscaleUp(t *testing.T) {
 	err := a.Init()
 	AssertNoError(err, t)
 
-	err = a.RunRound()
+	err = a.RunRound(t.Context())
 	AssertNoError(err, t)
 
 	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 4})
@@ -123,7 +141,7 @@ func TestUnit_BasicVscaleDown(t *testing.T) {
 	err := a.Init()
 	AssertNoError(err, t)
 
-	err = a.RunRound()
+	err = a.RunRound(t.Context())
 	AssertNoError(err, t)
 
 	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "283m"})
@@ -151,7 +169,7 @@ func TestUnit_BasicHscaleDown(t *testing.T) {
 	err := a.Init()
 	AssertNoError(err, t)
 
-	err = a.RunRound()
+	err = a.RunRound(t.Context())
 	AssertNoError(err, t)
 
 	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 2})
@@ -162,6 +180,35 @@ func TestUnit_BasicHscaleDown(t *testing.T) {
 	AssertPodListsEqual(mm.Pods, correctEndPods, t)
 }
 
+// a downscale waits out the window, counted from when the deployment was first seen
+func TestUnit_ScaleDownStabilization(t *testing.T) {
+	// setup - same as BasicHscaleDown
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 0.9
+	mm.RelDeploymentUtil = 0.5
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 300, 100, mm)
+	a.ScaleDownStabilization = time.Hour
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+	AssertNoActions(mm, t)
+
+	// the policy turns the window off, so the same recommendation goes through
+	off := int32(0)
+	mm.PolicySpec.Scaling.ScaleDownStabilizationSeconds = &off
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 2})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "265m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "265m"})
+	AssertNoActions(mm, t)
+}
+
 func TestUnit_NoCongestion(t *testing.T) {
 	// values to test
 	correctEndPods := map[string]PodData{
@@ -184,7 +231,7 @@ func TestUnit_NoCongestion(t *testing.T) {
 	err := a.Init()
 	AssertNoError(err, t)
 
-	err = a.RunRound()
+	err = a.RunRound(t.Context())
 	AssertNoError(err, t)
 
 	AssertNoActions(mm, t)
@@ -218,7 +265,7 @@ func TestUnit_NoCongestion(t *testing.T) {
 // 	err := a.Init()
 // 	AssertNoError(err, t)
 
-// 	err = a.RunRound()
+// 	err = a.RunRound(t.Context())
 // 	AssertNoError(err, t)
 
 // 	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 4})
@@ -232,4 +279,899 @@ func TestUnit_NoCongestion(t *testing.T) {
 // 	AssertPodListsEqual(mm.Pods, correctEndPods, t)
 // }
 
+func TestUnit_AnnotationPolicy(t *testing.T) {
+	// values to test
+	correctEndPods := map[string]PodData{
+		"pod1": {PodName: "pod1", NodeName: "node1", ContainerName: "container", CpuRequests: 450},
+		"pod2": {PodName: "pod2", NodeName: "node1", ContainerName: "container", CpuRequests: 450},
+		"pod3": {PodName: "pod3", NodeName: "node2", ContainerName: "container", CpuRequests: 450},
+		"pod4": {PodName: "pod4", NodeName: "node2", ContainerName: "container", CpuRequests: 450},
+	}
+
+	// setup - same as BasicHscaleUp but with the knobs moved onto the deployment
+	mm := CreateSimpleMockMetrics() // start 3 pods at 300 each
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+	mm.RelDeploymentUtil = 2
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+	mm.DeploymentAnnotations = map[string]string{
+		autoscaler.MAPS_ANNOTATION:              "500",
+		autoscaler.LATENCY_THRESHOLD_ANNOTATION: "100",
+	}
+
+	// test - global latency threshold would never be violated
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 100, 10000, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 4})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "450m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "450m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod3", ContainerName: "container", CpuRequests: "450m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod4", ContainerName: "container", CpuRequests: "450m"})
+	AssertNoActions(mm, t)
+
+	AssertPodListsEqual(mm.Pods, correctEndPods, t)
+}
+
+func TestUnit_InvalidAnnotationPolicy(t *testing.T) {
+	// setup - would be a vscale down with a valid policy
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 0.9
+	mm.RelDeploymentUtil = 0.8
+	mm.DeploymentAnnotations = map[string]string{
+		autoscaler.MAPS_ANNOTATION:                            "lots",
+		autoscaler.DOWNSCALE_UTILIZATION_THRESHOLD_ANNOTATION: "1.5",
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 300, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	deployment, err := mm.GetDeployment(t.Context(), a.Clientset, mm.DeploymentName, mm.DeploymentNamespace)
+	AssertNoError(err, t)
+	_, err = a.GetDeploymentPolicy(deployment)
+	if err == nil {
+		t.Errorf("expected invalid policy error")
+	}
+
+	// bad policy only skips the deployment, not the round
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertNoActions(mm, t)
+}
+
+func TestUnit_LatencySourcePerDeployment(t *testing.T) {
+	// values to test
+	correctEndPods := map[string]PodData{
+		"pod1": {PodName: "pod1", NodeName: "node1", ContainerName: "container", CpuRequests: 330},
+		"pod2": {PodName: "pod2", NodeName: "node1", ContainerName: "container", CpuRequests: 330},
+		"pod3": {PodName: "pod3", NodeName: "node2", ContainerName: "container", CpuRequests: 330},
+	}
+
+	// setup - inherited (frontend) latency is fine, but this deployment's own query is not
+	mm := CreateSimpleMockMetrics() // start 3 pods at 300 each
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 0.5
+	mm.SourceLatencies = map[string]float64{
+		"promql:histogram_quantile(0.99, rate(testapp_latency_bucket[1m]))": MOCK_LATENCY_THRESHOLD * 1.5,
+	}
+	mm.RelDeploymentUtil = 1.1
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+	mm.DeploymentAnnotations = map[string]string{
+		autoscaler.LATENCY_SOURCE_ANNOTATION: "promql:histogram_quantile(0.99, rate(testapp_latency_bucket[1m]))",
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 400, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "330m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "330m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod3", ContainerName: "container", CpuRequests: "330m"})
+	AssertNoActions(mm, t)
+
+	AssertPodListsEqual(mm.Pods, correctEndPods, t)
+}
+
+func TestUnit_ParseLatencySource(t *testing.T) {
+	source, err := autoscaler.ParseLatencySource("service:frontend", "hotelres")
+	AssertNoError(err, t)
+	if source.Type != autoscaler.ServiceLatencySource || source.Namespace != "hotelres" || source.Name != "frontend" {
+		t.Errorf("incorrect service source, got %s", source)
+	}
+
+	source, err = autoscaler.ParseLatencySource("service:edge/frontend", "hotelres")
+	AssertNoError(err, t)
+	if source.Namespace != "edge" || source.Name != "frontend" {
+		t.Errorf("incorrect namespaced service source, got %s", source)
+	}
+
+	source, err = autoscaler.ParseLatencySource("inherit", "hotelres")
+	AssertNoError(err, t)
+	if source.Type != autoscaler.InheritLatencySource {
+		t.Errorf("incorrect inherit source, got %s", source)
+	}
+
+	for _, bad := range []string{"service:", "promql:", "cloudwatch:lb", "frontend"} {
+		_, err = autoscaler.ParseLatencySource(bad, "hotelres")
+		if err == nil {
+			t.Errorf("expected error for latency source %q", bad)
+		}
+	}
+}
+
+func TestUnit_MaxBoundsClipHscaleUp(t *testing.T) {
+	// values to test
+	correctEndPods := map[string]PodData{
+		"pod1": {PodName: "pod1", NodeName: "node1", ContainerName: "container", CpuRequests: 500},
+		"pod2": {PodName: "pod2", NodeName: "node1", ContainerName: "container", CpuRequests: 500},
+		"pod3": {PodName: "pod3", NodeName: "node2", ContainerName: "container", CpuRequests: 500},
+	}
+
+	// setup - same as BasicHscaleUp, which would go to 4 pods at 450
+	mm := CreateSimpleMockMetrics() // start 3 pods at 300 each
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+	mm.RelDeploymentUtil = 2
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+	mm.DeploymentAnnotations = map[string]string{
+		autoscaler.MAX_REPLICAS_ANNOTATION: "3",   // 4 -> 3 replicas, so 600m each
+		autoscaler.MAX_CPU_ANNOTATION:      "500", // 600m -> 500m
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 500, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "500m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "500m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod3", ContainerName: "container", CpuRequests: "500m"})
+	AssertNoActions(mm, t)
+
+	AssertPodListsEqual(mm.Pods, correctEndPods, t)
+}
+
+func TestUnit_MinReplicasClipHscaleDown(t *testing.T) {
+	// values to test
+	correctEndPods := map[string]PodData{
+		"pod1": {PodName: "pod1", NodeName: "node1", ContainerName: "container", CpuRequests: 177}, // ceil(150 / 0.85) = 177
+		"pod2": {PodName: "pod2", NodeName: "node1", ContainerName: "container", CpuRequests: 177},
+		"pod3": {PodName: "pod3", NodeName: "node2", ContainerName: "container", CpuRequests: 177},
+	}
+
+	// setup - same as BasicHscaleDown, which would go to 2 pods
+	mm := CreateSimpleMockMetrics() // start 3 pods at 300 each
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 0.9
+	mm.RelDeploymentUtil = 0.5 // default alloc is 900
+	mm.DeploymentAnnotations = map[string]string{
+		autoscaler.MIN_REPLICAS_ANNOTATION: "3",
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 300, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "177m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "177m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod3", ContainerName: "container", CpuRequests: "177m"})
+	AssertNoActions(mm, t)
+
+	AssertPodListsEqual(mm.Pods, correctEndPods, t)
+}
+
+func TestUnit_InvertedBoundsPolicy(t *testing.T) {
+	mm := CreateSimpleMockMetrics()
+	mm.DeploymentAnnotations = map[string]string{
+		autoscaler.MIN_REPLICAS_ANNOTATION: "5",
+		autoscaler.MAX_REPLICAS_ANNOTATION: "2",
+	}
+
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 300, 100, mm)
+	deployment, err := mm.GetDeployment(t.Context(), a.Clientset, mm.DeploymentName, mm.DeploymentNamespace)
+	AssertNoError(err, t)
+	_, err = a.GetDeploymentPolicy(deployment)
+	if err == nil {
+		t.Errorf("expected error for min replicas above max replicas")
+	}
+}
+
+func TestUnit_DryRunHscaleUp(t *testing.T) {
+	// values to test - nothing should change
+	correctEndPods := map[string]PodData{
+		"pod1": {PodName: "pod1", NodeName: "node1", ContainerName: "container", CpuRequests: 300},
+		"pod2": {PodName: "pod2", NodeName: "node1", ContainerName: "container", CpuRequests: 300},
+		"pod3": {PodName: "pod3", NodeName: "node2", ContainerName: "container", CpuRequests: 300},
+	}
+
+	// setup - same as BasicHscaleUp
+	mm := CreateSimpleMockMetrics() // start 3 pods at 300 each
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+	mm.RelDeploymentUtil = 2
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+
+	// test
+	var planout bytes.Buffer
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 500, 100, mm)
+	a.DryRun = true
+	a.PlanWriter = &planout
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertNoActions(mm, t)
+	AssertPodListsEqual(mm.Pods, correctEndPods, t)
+
+	plan := a.LastPlan()
+	if !plan.DryRun || len(plan.Deployments) != 1 {
+		t.Fatalf("expected a dry run plan for 1 deployment, got %+v", plan)
+	}
+	dplan := plan.Deployments[0]
+	AssertIntsEqual(3, dplan.Replicas, t)
+	AssertIntsEqual(300, int(dplan.CpuRequests), t)
+	if len(dplan.Actions) != 2 {
+		t.Fatalf("expected 2 planned actions, got %+v", dplan.Actions)
+	}
+	if dplan.Actions[0].Type != autoscaler.HScaleAction || dplan.Actions[0].Replicas != 4 {
+		t.Errorf("expected hscale to 4 replicas, got %+v", dplan.Actions[0])
+	}
+	if dplan.Actions[1].Type != autoscaler.VScaleAction || dplan.Actions[1].CpuRequests != 450 {
+		t.Errorf("expected vscale to 450m, got %+v", dplan.Actions[1])
+	}
+
+	// the emitted plan is one json line per round
+	var emitted autoscaler.RoundPlan
+	err = json.Unmarshal(bytes.TrimSpace(planout.Bytes()), &emitted)
+	AssertNoError(err, t)
+	AssertIntsEqual(2, len(emitted.Deployments[0].Actions), t)
+}
+
+func TestUnit_MemoryVscaleUp(t *testing.T) {
+	// values to test
+	correctEndPods := map[string]PodData{
+		"pod1": {PodName: "pod1", NodeName: "node1", ContainerName: "container", CpuRequests: 300, MemRequests: 384 * MiB},
+		"pod2": {PodName: "pod2", NodeName: "node1", ContainerName: "container", CpuRequests: 300, MemRequests: 384 * MiB},
+		"pod3": {PodName: "pod3", NodeName: "node2", ContainerName: "container", CpuRequests: 300, MemRequests: 384 * MiB},
+	}
+
+	// setup - stable on cpu, one pod close to its memory request
+	mm := CreateSimpleMockMetrics()
+	for k, p := range mm.Pods {
+		p.MemRequests = 256 * MiB
+		p.MemUsage = 150 * MiB
+		mm.Pods[k] = p
+	}
+	pod2 := mm.Pods["pod2"]
+	pod2.MemUsage = 240 * MiB
+	mm.Pods["pod2"] = pod2
+	mm.DeploymentAnnotations = map[string]string{
+		autoscaler.MAX_MEMORY_ANNOTATION: "1Gi",
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 500, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	// cpu is left as is
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", MemRequests: "384Mi"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", MemRequests: "384Mi"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod3", ContainerName: "container", MemRequests: "384Mi"})
+	AssertNoActions(mm, t)
+
+	AssertPodListsEqual(mm.Pods, correctEndPods, t)
+}
+
+func TestUnit_MultiContainerVscaleUp(t *testing.T) {
+	// values to test - 330 per pod split 2:1 over the app containers, sidecar untouched
+	correctExtra := []ContainerData{{Name: "istio-proxy", CpuRequests: 100}, {Name: "worker", CpuRequests: 110}}
+	correctEndPods := map[string]PodData{
+		"pod1": {PodName: "pod1", NodeName: "node1", ContainerName: "container", CpuRequests: 220, Extra: correctExtra},
+		"pod2": {PodName: "pod2", NodeName: "node1", ContainerName: "container", CpuRequests: 220, Extra: correctExtra},
+		"pod3": {PodName: "pod3", NodeName: "node2", ContainerName: "container", CpuRequests: 220, Extra: correctExtra},
+	}
+
+	// setup - same as BasicVscaleUp with each pod's 300 split over two app containers
+	mm := CreateSimpleMockMetrics()
+	for k, p := range mm.Pods {
+		p.CpuRequests = 200
+		p.Extra = []ContainerData{{Name: "istio-proxy", CpuRequests: 100}, {Name: "worker", CpuRequests: 100}}
+		mm.Pods[k] = p
+	}
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+	mm.RelDeploymentUtil = 1.1
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 400, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	for range 3 {
+		AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "worker", CpuRequests: "110m"})
+		AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", CpuRequests: "220m"})
+	}
+	AssertNoActions(mm, t)
+
+	AssertPodListsEqual(mm.Pods, correctEndPods, t)
+}
+
+func TestUnit_MultiContainerMemoryWorkingSet(t *testing.T) {
+	// values to test - shrinking to ceil(200Mi / 0.7) split 3:1 would put worker at ~71Mi,
+	// under its 150Mi working set, so worker holds 150Mi and container gets the rest
+	correctExtra := []ContainerData{{Name: "worker", CpuRequests: 100, MemRequests: 150 * MiB, MemUsage: 150 * MiB}}
+	correctEndPods := map[string]PodData{
+		"pod1": {PodName: "pod1", NodeName: "node1", ContainerName: "container", CpuRequests: 300, MemRequests: 142306743, MemUsage: 50 * MiB, Extra: correctExtra},
+		"pod2": {PodName: "pod2", NodeName: "node1", ContainerName: "container", CpuRequests: 300, MemRequests: 142306743, MemUsage: 50 * MiB, Extra: correctExtra},
+		"pod3": {PodName: "pod3", NodeName: "node2", ContainerName: "container", CpuRequests: 300, MemRequests: 142306743, MemUsage: 50 * MiB, Extra: correctExtra},
+	}
+
+	// setup - stable on cpu, memory well under its requests
+	mm := CreateSimpleMockMetrics()
+	for k, p := range mm.Pods {
+		p.MemRequests = 768 * MiB
+		p.MemUsage = 50 * MiB
+		p.Extra = []ContainerData{{Name: "worker", CpuRequests: 100, MemRequests: 256 * MiB, MemUsage: 150 * MiB}}
+		mm.Pods[k] = p
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 500, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	for range 3 {
+		AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "worker", MemRequests: "150Mi"})
+		AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", MemRequests: "142306743"})
+	}
+	AssertNoActions(mm, t)
+
+	AssertPodListsEqual(mm.Pods, correctEndPods, t)
+}
+
+func TestUnit_SidecarAnnotation(t *testing.T) {
+	// values to test
+	correctExtra := []ContainerData{{Name: "log-shipper", CpuRequests: 100}}
+	correctEndPods := map[string]PodData{
+		"pod1": {PodName: "pod1", NodeName: "node1", ContainerName: "container", CpuRequests: 330, Extra: correctExtra},
+		"pod2": {PodName: "pod2", NodeName: "node1", ContainerName: "container", CpuRequests: 330, Extra: correctExtra},
+		"pod3": {PodName: "pod3", NodeName: "node2", ContainerName: "container", CpuRequests: 330, Extra: correctExtra},
+	}
+
+	// setup - same as BasicVscaleUp with a log shipper next to the app
+	mm := CreateSimpleMockMetrics()
+	for k, p := range mm.Pods {
+		p.Extra = []ContainerData{{Name: "log-shipper", CpuRequests: 100}}
+		mm.Pods[k] = p
+	}
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+	mm.RelDeploymentUtil = 1.1
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+	mm.DeploymentAnnotations = map[string]string{
+		autoscaler.SIDECARS_ANNOTATION: "linkerd-proxy, log-shipper",
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 400, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "330m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "330m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod3", ContainerName: "container", CpuRequests: "330m"})
+	AssertNoActions(mm, t)
+
+	AssertPodListsEqual(mm.Pods, correctEndPods, t)
+}
+
+func TestUnit_CPULimitPolicy(t *testing.T) {
+	// same as BasicVscaleUp (300m -> 330m) under each limit policy
+	cases := []struct {
+		policy        string
+		startLimit    int64
+		podLimit      int64  // after the round
+		actionLimit   string // sent with each pod resize
+		templateLimit string
+	}{
+		{"keep", 310, 330, "330m", "330m"}, // raised so requests stay under it
+		{"keep", 500, 500, "", ""},
+		{"ratio:2", 310, 660, "660m", "660m"},
+		{"unbounded", 310, 330, "330m", util.REMOVE_RESOURCE}, // running pods can't drop it
+		{"unbounded", 0, 0, "", util.REMOVE_RESOURCE},
+	}
+
+	for _, c := range cases {
+		// setup
+		mm := CreateSimpleMockMetrics()
+		for k, p := range mm.Pods {
+			p.CpuLimits = c.startLimit
+			mm.Pods[k] = p
+		}
+		mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+		mm.RelDeploymentUtil = 1.1
+		mm.RelNodeUsages = map[string]float64{
+			"node1": 0.9,
+			"node2": 0.5,
+		}
+		mm.DeploymentAnnotations = map[string]string{
+			autoscaler.CPU_LIMIT_ANNOTATION: c.policy,
+		}
+
+		// test
+		a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 400, 100, mm)
+		err := a.Init()
+		AssertNoError(err, t)
+
+		err = a.RunRound(t.Context())
+		AssertNoError(err, t)
+
+		for range 3 {
+			AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", CpuRequests: "330m", CpuLimits: c.actionLimit})
+		}
+		AssertNoActions(mm, t)
+
+		for _, p := range mm.Pods {
+			if p.CpuRequests != 330 || p.CpuLimits != c.podLimit {
+				t.Errorf("%s from %dm: expected pod %s at 330m/%dm, got %dm/%dm", c.policy, c.startLimit, p.PodName, c.podLimit, p.CpuRequests, p.CpuLimits)
+			}
+		}
+		if template := mm.Templates["container"]; template.Requests.CPU != "330m" || template.Limits.CPU != c.templateLimit {
+			t.Errorf("%s from %dm: expected template 330m/%q, got %+v", c.policy, c.startLimit, c.templateLimit, template)
+		}
+	}
+}
+
+func TestUnit_ParseCPULimitPolicy(t *testing.T) {
+	limit, err := autoscaler.ParseCPULimitPolicy("ratio:1.5")
+	AssertNoError(err, t)
+	if limit.Mode != autoscaler.RatioCPULimit || limit.Ratio != 1.5 {
+		t.Errorf("incorrect ratio limit policy, got %s", limit)
+	}
+
+	limit, err = autoscaler.ParseCPULimitPolicy("")
+	AssertNoError(err, t)
+	if limit.Mode != autoscaler.KeepCPULimit {
+		t.Errorf("incorrect default limit policy, got %s", limit)
+	}
+
+	for _, bad := range []string{"ratio:0.5", "ratio:", "ratio", "none", "keep:2"} {
+		_, err = autoscaler.ParseCPULimitPolicy(bad)
+		if err == nil {
+			t.Errorf("expected error for cpu limit policy %q", bad)
+		}
+	}
+}
+
+func TestUnit_PolicySpecOverridesAnnotations(t *testing.T) {
+	// values to test - the policy's max cpu wins over the annotation's
+	correctEndPods := map[string]PodData{
+		"pod1": {PodName: "pod1", NodeName: "node1", ContainerName: "container", CpuRequests: 320},
+		"pod2": {PodName: "pod2", NodeName: "node1", ContainerName: "container", CpuRequests: 320},
+		"pod3": {PodName: "pod3", NodeName: "node2", ContainerName: "container", CpuRequests: 320},
+	}
+
+	// setup - same as BasicVscaleUp, which goes to 330m unbounded
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+	mm.RelDeploymentUtil = 1.1
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+	mm.DeploymentAnnotations = map[string]string{
+		autoscaler.MAX_CPU_ANNOTATION: "310",
+	}
+	maxCPU := resource.MustParse("320m")
+	mm.PolicySpec.Bounds.MaxCPU = &maxCPU
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 400, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "320m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "320m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod3", ContainerName: "container", CpuRequests: "320m"})
+	AssertNoActions(mm, t)
+
+	AssertPodListsEqual(mm.Pods, correctEndPods, t)
+}
+
+// node usage comes from the metrics API, it is read once per node per round, not again for every deployment
+func TestUnit_NodeSnapshot(t *testing.T) {
+	// setup - same as BasicVscaleUp
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+	mm.RelDeploymentUtil = 1.1
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+	reads := map[string]int{}
+	mm.MockGetNodeUsage = func(m *MockMetrics, ctx context.Context, metricsClient *metrics_client.Clientset, nodeName string) (int64, error) {
+		reads[nodeName]++
+		return MockNodeUsage(m, ctx, metricsClient, nodeName)
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 400, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "330m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "330m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod3", ContainerName: "container", CpuRequests: "330m"})
+	AssertNoActions(mm, t)
+	if !maps.Equal(reads, map[string]int{"node1": 1, "node2": 1}) {
+		t.Errorf("expected every node read once, got %v", reads)
+	}
+}
+
+func TestUnit_PolicyStatus(t *testing.T) {
+	// setup - same as BasicVscaleUp
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+	mm.RelDeploymentUtil = 1.1
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 400, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	observed := mm.PolicyStatus.Observed
+	if observed == nil {
+		t.Fatalf("expected observed state in the policy status")
+	}
+	AssertIntsEqual(3, int(observed.Replicas), t)
+	AssertIntsEqual(300, int(observed.CPURequestsMillis), t)
+	if observed.LatencyMillis == nil || *observed.LatencyMillis != 150 || !observed.SLOViolated {
+		t.Errorf("expected a violated SLO at 150ms, got %+v", observed)
+	}
+
+	decision := mm.PolicyStatus.LastDecision
+	if decision == nil || decision.DryRun || len(decision.Actions) != 1 {
+		t.Fatalf("expected one applied action in the last decision, got %+v", decision)
+	}
+	if decision.Actions[0].Type != string(autoscaler.VScaleAction) || decision.Actions[0].CPURequestsMillis != 330 {
+		t.Errorf("expected vscale to 330m, got %+v", decision.Actions[0])
+	}
+
+	condition := meta.FindStatusCondition(mm.PolicyStatus.Conditions, v1alpha1.ReconciledCondition)
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		t.Errorf("expected Reconciled to be True, got %+v", condition)
+	}
+}
+
+func TestUnit_InvalidPolicyStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   v1alpha1.PodoscalerPolicySpec
+		reason string
+	}{
+		{"zero memory pressure", v1alpha1.PodoscalerPolicySpec{Scaling: v1alpha1.ScalingSpec{MemoryPressurePercent: new(int32)}}, v1alpha1.InvalidPolicyReason},
+		{"unsupported kind", v1alpha1.PodoscalerPolicySpec{TargetRef: v1alpha1.TargetReference{Kind: "StatefulSet", Name: MOCK_DEPLOYMENT_NAME}}, v1alpha1.InvalidTargetReason},
+		{"missing deployment", v1alpha1.PodoscalerPolicySpec{TargetRef: v1alpha1.TargetReference{Kind: "Deployment", Name: "missing"}}, v1alpha1.InvalidTargetReason},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm := CreateSimpleMockMetrics()
+			mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+			mm.RelDeploymentUtil = 1.1
+			mm.PolicySpec = tt.spec
+
+			a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 400, 100, mm)
+			err := a.Init()
+			AssertNoError(err, t)
+
+			// a bad policy only skips its target, not the round
+			err = a.RunRound(t.Context())
+			AssertNoError(err, t)
+			AssertNoActions(mm, t)
+
+			condition := meta.FindStatusCondition(mm.PolicyStatus.Conditions, v1alpha1.ReconciledCondition)
+			if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != tt.reason {
+				t.Errorf("expected Reconciled to be False with %s, got %+v", tt.reason, condition)
+			}
+			if mm.PolicyStatus.Observed != nil || mm.PolicyStatus.LastDecision.Error == "" {
+				t.Errorf("expected only an error in the status, got %+v", mm.PolicyStatus)
+			}
+		})
+	}
+}
+
+func TestUnit_DeploymentTimeout(t *testing.T) {
+	// setup - same as BasicHscaleUp, with an hscale that outlasts the deployment's deadline
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+	mm.RelDeploymentUtil = 2
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+	mm.MockChangeReplicaCount = func(m *MockMetrics, ctx context.Context, namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error {
+		time.Sleep(50 * time.Millisecond)
+		return MockChangeReplicaCount(m, ctx, namespace, deploymentName, replicaCt, clientset)
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 500, 100, mm)
+	a.DeploymentTimeout = 10 * time.Millisecond
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	// the hscale finishes, the vscale after it is never started
+	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 4})
+	AssertNoActions(mm, t)
+
+	condition := meta.FindStatusCondition(mm.PolicyStatus.Conditions, v1alpha1.ReconciledCondition)
+	if condition == nil || condition.Reason != v1alpha1.ReconcileErrorReason {
+		t.Errorf("expected Reconciled to be False with %s, got %+v", v1alpha1.ReconcileErrorReason, condition)
+	}
+	plan := a.LastPlan()
+	if len(plan.Deployments) != 1 || !strings.Contains(plan.Deployments[0].Error, "deadline exceeded") {
+		t.Errorf("expected the deadline in the plan, got %+v", plan.Deployments)
+	}
+}
+
+func TestUnit_ShutdownMidRound(t *testing.T) {
+	// setup - same as BasicHscaleUp, shutting down while the hscale is in flight
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+	mm.RelDeploymentUtil = 2
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+	ctx, shutdown := context.WithCancel(t.Context())
+	defer shutdown()
+	mm.MockChangeReplicaCount = func(m *MockMetrics, actionCtx context.Context, namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error {
+		shutdown()
+		if actionCtx.Err() != nil {
+			t.Errorf("expected the in-flight hscale to keep its context, got %s", actionCtx.Err())
+		}
+		return MockChangeReplicaCount(m, actionCtx, namespace, deploymentName, replicaCt, clientset)
+	}
+	mm.MockUpdatePodoscalerPolicyStatus = func(m *MockMetrics, statusCtx context.Context, dynamicClient dynamic.Interface, policy *v1alpha1.PodoscalerPolicy) error {
+		if statusCtx.Err() != nil {
+			return statusCtx.Err()
+		}
+		return MockUpdatePodoscalerPolicyStatus(m, statusCtx, dynamicClient, policy)
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 500, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected an interrupted round, got %v", err)
+	}
+
+	// the hscale finishes, the vscale after it is never started
+	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 4})
+	AssertNoActions(mm, t)
+
+	// the status write is not cut off by the shutdown either
+	condition := meta.FindStatusCondition(mm.PolicyStatus.Conditions, v1alpha1.ReconciledCondition)
+	if condition == nil || condition.Reason != v1alpha1.ReconcileErrorReason {
+		t.Errorf("expected Reconciled to be False with %s, got %+v", v1alpha1.ReconcileErrorReason, condition)
+	}
+}
+
 // error handling?
+
+func vscaleRollbackMockMetrics() *MockMetrics {
+	// same as BasicVscaleUp, 3 pods at 300 go to 330
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+	mm.RelDeploymentUtil = 1.1
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+	return mm
+}
+
+func assertResizeOutcomes(plan autoscaler.RoundPlan, expected map[autoscaler.ResizeOutcome]int, t *testing.T) {
+	if len(plan.Deployments) != 1 {
+		t.Errorf("expected 1 deployment in the plan, got %d", len(plan.Deployments))
+		return
+	}
+	counts := map[autoscaler.ResizeOutcome]int{}
+	for _, pod := range plan.Deployments[0].Resizes {
+		counts[pod.Outcome]++
+	}
+	if !maps.Equal(counts, expected) {
+		t.Errorf("expected pod outcomes %v, got %+v", expected, plan.Deployments[0].Resizes)
+	}
+}
+
+// the third pod refuses the resize, the two already resized are put back
+func TestUnit_VscaleRollback(t *testing.T) {
+	mm := vscaleRollbackMockMetrics()
+	resizes := 0
+	mm.MockVScale = func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string) error {
+		if resources.Requests.CPU == "330m" {
+			resizes++
+			if resizes == 3 {
+				return fmt.Errorf("pod %s is being evicted", podname)
+			}
+		}
+		return MockVScale(m, ctx, clientset, podname, containername, resources, namespace)
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 400, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", CpuRequests: "330m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", CpuRequests: "330m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", CpuRequests: "300m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", CpuRequests: "300m"})
+	AssertNoActions(mm, t)
+
+	AssertPodListsEqual(mm.Pods, CreateSimpleMockMetrics().Pods, t)
+	if mm.Templates != nil {
+		t.Errorf("expected the template to be left alone, got %+v", mm.Templates)
+	}
+	assertResizeOutcomes(a.LastPlan(), map[autoscaler.ResizeOutcome]int{autoscaler.PodReverted: 2, autoscaler.PodFailed: 1}, t)
+}
+
+// every pod is resized but the template patch fails, so the pods are put back too
+func TestUnit_TemplatePatchRollback(t *testing.T) {
+	mm := vscaleRollbackMockMetrics()
+	mm.MockPatchDeploymentReqs = func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName string, containername string, resources util.VerticalPatchContainerResources, namespace string) error {
+		return apierrors.NewForbidden(appsv1.Resource("deployments"), deploymentName, fmt.Errorf("rbac"))
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 400, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	for range 3 {
+		AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", CpuRequests: "330m"})
+	}
+	for range 3 {
+		AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", CpuRequests: "300m"})
+	}
+	AssertNoActions(mm, t)
+
+	AssertPodListsEqual(mm.Pods, CreateSimpleMockMetrics().Pods, t)
+	assertResizeOutcomes(a.LastPlan(), map[autoscaler.ResizeOutcome]int{autoscaler.PodReverted: 3}, t)
+	if !strings.Contains(a.LastPlan().Deployments[0].Error, "resize rolled back") {
+		t.Errorf("expected the rollback in the plan error, got %q", a.LastPlan().Deployments[0].Error)
+	}
+}
+
+// the first pod's resize is infeasible on its node, it is moved and the resize retried
+func TestUnit_InfeasibleResizeMigrates(t *testing.T) {
+	mm := vscaleRollbackMockMetrics()
+	infeasiblePod := ""
+	// node1 is congested, so only a pod there has somewhere to go
+	mm.MockWaitForResize = func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string, timeout time.Duration) error {
+		if infeasiblePod == "" && m.Pods[podname].NodeName == "node1" {
+			infeasiblePod = podname
+			return fmt.Errorf("%w: pod %s", util.ErrResizeInfeasible, podname)
+		}
+		return nil
+	}
+
+	// test
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 400, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	// pods resized before the infeasible one (on node2, or node1 if they come first) are put back too
+	reverted := 0
+	for len(mm.Actions) > 0 && mm.Actions[0].PodName != infeasiblePod {
+		AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", CpuRequests: "330m"})
+		reverted++
+	}
+	AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", CpuRequests: "330m"})
+	for range reverted + 1 {
+		AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", CpuRequests: "300m"})
+	}
+	AssertAction(mm, t, Action{Type: MigratePodAction, PodName: infeasiblePod, NodeName: "node2"})
+	for range 3 {
+		AssertAction(mm, t, Action{Type: VscaleAction, ContainerName: "container", CpuRequests: "330m"})
+	}
+	AssertNoActions(mm, t)
+
+	for name, pod := range mm.Pods {
+		if name == infeasiblePod || pod.CpuRequests != 330 {
+			t.Errorf("expected the moved pod gone and every pod at 330m, got %+v", mm.Pods)
+		}
+	}
+
+	plan := a.LastPlan()
+	if len(plan.Deployments) != 1 || plan.Deployments[0].Error != "" || len(plan.Deployments[0].Actions) != 3 {
+		t.Errorf("expected the vscale and its 2 fallback actions, got %+v", plan.Deployments)
+	}
+	if moved := mm.Pods["pod4"]; moved.NodeName != "node2" {
+		t.Errorf("expected the replacement pod4 on node2, got %+v", mm.Pods)
+	}
+	outcomes := map[autoscaler.ResizeOutcome]int{autoscaler.PodFailed: 1, autoscaler.PodReverted: reverted, autoscaler.PodUntouched: 2 - reverted, autoscaler.PodResized: 3}
+	maps.DeleteFunc(outcomes, func(_ autoscaler.ResizeOutcome, n int) bool { return n == 0 })
+	assertResizeOutcomes(plan, outcomes, t)
+
+	// the policy status says where the pod went
+	decision := mm.PolicyStatus.LastDecision
+	if decision == nil || len(decision.Actions) != 3 || decision.Actions[1].Type != string(autoscaler.MigratePodAction) || decision.Actions[1].Node != "node2" {
+		t.Errorf("expected the migration to node2 in the last decision, got %+v", decision)
+	}
+}
@@ -0,0 +1,75 @@
+//go:build autoscalertest
+// +build autoscalertest
+
+package autoscalertest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tholiang/podoscaler/scalers/util"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func cacheTestObjects() []runtime.Object {
+	node := &v1.Node{}
+	node.Name = "node1"
+	node.Status.Capacity = v1.ResourceList{"cpu": resource.MustParse("2")}
+	node.Status.Allocatable = v1.ResourceList{"cpu": resource.MustParse("1900m")}
+
+	deployment := &appsv1.Deployment{}
+	deployment.Name = MOCK_DEPLOYMENT_NAME
+	deployment.Namespace = MOCK_DEPLOYMENT_NAMESPACE
+	deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "dummy"}}
+
+	objs := []runtime.Object{node, deployment}
+	for _, name := range []string{"pod1", "pod2", "other"} {
+		pod := MakePod(name, "node1", "container", 300)
+		pod.Namespace = MOCK_DEPLOYMENT_NAMESPACE
+		if name != "other" {
+			pod.Labels = map[string]string{"app": "dummy"}
+			pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+		}
+		objs = append(objs, &pod)
+	}
+	return objs
+}
+
+// the cache has to answer the same as the API server
+func TestUnit_ClusterCacheReads(t *testing.T) {
+	clientset := fake.NewSimpleClientset(cacheTestObjects()...)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cache, err := util.NewClusterCache(clientset, 0)
+	AssertNoError(err, t)
+	err = cache.Start(ctx)
+	AssertNoError(err, t)
+
+	for name, reader := range map[string]util.ClusterReader{"cache": cache, "api": util.NewAPIReader(clientset)} {
+		t.Run(name, func(t *testing.T) {
+			allocable, capacity, err := util.GetNodeAllocableAndCapacity(ctx, reader, "node1")
+			AssertNoError(err, t)
+			AssertIntsEqual(1900-3*300, int(allocable), t)
+			AssertIntsEqual(2000, int(capacity), t)
+
+			pods, err := util.GetReadyPodListForDeployment(ctx, reader, MOCK_DEPLOYMENT_NAME, MOCK_DEPLOYMENT_NAMESPACE)
+			AssertNoError(err, t)
+			AssertIntsEqual(2, len(pods), t)
+
+			nodes, err := util.GetNodeList(ctx, reader)
+			AssertNoError(err, t)
+			AssertIntsEqual(1, len(nodes.Items), t)
+
+			_, err = util.GetDeployment(ctx, reader, "missing", MOCK_DEPLOYMENT_NAMESPACE)
+			if err == nil {
+				t.Errorf("expected an error for a missing deployment")
+			}
+		})
+	}
+}
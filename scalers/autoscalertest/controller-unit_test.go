@@ -0,0 +1,70 @@
+//go:build autoscalertest
+// +build autoscalertest
+
+package autoscalertest
+
+import (
+	"testing"
+
+	"github.com/tholiang/podoscaler/scalers/autoscaler"
+)
+
+func TestUnit_ControllerMode(t *testing.T) {
+	// the rules would scale down here - the controller holds, latency is just over its 80ms setpoint
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 0.9
+	mm.RelDeploymentUtil = 0.8
+	mm.DeploymentAnnotations = map[string]string{autoscaler.SCALING_MODE_ANNOTATION: string(autoscaler.ControllerScalingMode)}
+
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 300, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+	AssertNoActions(mm, t)
+
+	// well over, but at most 25% more cpu in one round
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 2
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 4})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "282m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "282m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod3", ContainerName: "container", CpuRequests: "282m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod4", ContainerName: "container", CpuRequests: "282m"})
+	AssertNoActions(mm, t)
+
+	mm.DeploymentAnnotations[autoscaler.SCALING_MODE_ANNOTATION] = "pid"
+	deployment, err := mm.GetDeployment(t.Context(), a.Clientset, mm.DeploymentName, mm.DeploymentNamespace)
+	AssertNoError(err, t)
+	_, err = a.GetDeploymentPolicy(deployment)
+	if err == nil {
+		t.Errorf("expected invalid policy error")
+	}
+}
+
+func TestUnit_ControllerSLOTargets(t *testing.T) {
+	// p99 is well under its setpoint, p95 is over its own - the controller steers on the slo, so it asks for more
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 0.5
+	mm.Percentiles = map[string]float64{"p95": 0.04}
+	mm.RelDeploymentUtil = 0.8
+	mm.DeploymentAnnotations = map[string]string{
+		autoscaler.SCALING_MODE_ANNOTATION:    string(autoscaler.ControllerScalingMode),
+		autoscaler.LATENCY_TARGETS_ANNOTATION: "p95=30,p99=100",
+	}
+
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 300, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+	plan := a.LastPlan()
+	if len(plan.Deployments) != 1 || plan.Deployments[0].ControlTarget <= plan.Deployments[0].Allocation {
+		t.Errorf("expected the controller to ask for more than the allocation on the p95 target, got %+v", plan.Deployments)
+	}
+	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 4})
+}
@@ -0,0 +1,110 @@
+//go:build autoscalertest
+// +build autoscalertest
+
+package autoscalertest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tholiang/podoscaler/scalers/autoscaler"
+)
+
+// a ten minute season: 500 millicpus, with a 300 millicpu peak over minutes 5 to 7
+func seasonalLoad(t time.Time) float64 {
+	if minute := t.Unix() / 60 % 10; minute >= 5 && minute <= 7 {
+		return 800
+	}
+	return 500
+}
+
+func TestUnit_ForecasterLearnsSeason(t *testing.T) {
+	f := autoscaler.NewForecaster(10 * time.Minute)
+	start := time.Unix(0, 0)
+
+	// nothing until a whole season has been seen
+	for i := range 5 {
+		now := start.Add(time.Duration(i) * time.Minute)
+		f.Observe(now, seasonalLoad(now))
+		if _, ok := f.Forecast(now, time.Minute); ok {
+			t.Fatalf("expected no forecast after %d minutes", i)
+		}
+	}
+
+	for i := 5; i < 40; i++ {
+		now := start.Add(time.Duration(i) * time.Minute)
+		f.Observe(now, seasonalLoad(now))
+		f.Forecast(now, time.Minute)
+	}
+
+	// minute 39, the peak starts in 6 minutes
+	now := start.Add(39 * time.Minute)
+	next, ok := f.Forecast(now, time.Minute)
+	if !ok || math.Abs(next-500) > 50 {
+		t.Errorf("expected about 500 next minute, got %.0f", next)
+	}
+	peak, _ := f.Forecast(now, 6*time.Minute)
+	if math.Abs(peak-800) > 80 {
+		t.Errorf("expected the 800 peak within 6 minutes, got %.0f", peak)
+	}
+
+	mape, n := f.Error()
+	if n == 0 || mape > 10 {
+		t.Errorf("expected checked forecasts within 10%%, got %.1f%% over %d", mape, n)
+	}
+}
+
+func TestPlanner_ForecastDemand(t *testing.T) {
+	policy := MakePlannerPolicy(300, 100)
+	policy.ForecastWeight = 0.5
+	state := MakePlannerState(simplePlannerPods(), 0.8, 50, policy)
+
+	// learning or off
+	if demand := state.Demand(); demand != 720 {
+		t.Errorf("expected the 720 millicpus used, got %d", demand)
+	}
+	// never below what is used now
+	state.Forecast = 400
+	if demand := state.Demand(); demand != 720 {
+		t.Errorf("expected the 720 millicpus used, got %d", demand)
+	}
+	state.Forecast = 1500
+	if demand := state.Demand(); demand != 1110 {
+		t.Errorf("expected 1110 millicpus blended, got %d", demand)
+	}
+}
+
+func TestPlanner_ForecastPreScale(t *testing.T) {
+	// 720/900 used and within the slo, but 1500 expected - sized for 1110 ahead of it
+	policy := MakePlannerPolicy(300, 100)
+	policy.ForecastWeight = 0.5
+	state := MakePlannerState(simplePlannerPods(), 0.8, 50, policy)
+	state.Forecast = 1500
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 4},
+		{Type: autoscaler.VScaleAction, CpuRequests: 278},
+	}, t)
+
+	// 910 fits three replicas of up to 400, only the requests have to grow
+	state.Policy.Maps = 400
+	state.Forecast = 1100
+	decision = autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.VScaleAction, CpuRequests: 304},
+	}, t)
+
+	// off, or still learning - 1350/900 used is left to the reactive rules while within the slo
+	policy.ForecastWeight = 0
+	state = MakePlannerState(simplePlannerPods(), 1.5, 50, policy)
+	state.Forecast = 1500
+	decision = autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+
+	state.Policy.ForecastWeight = 0.5
+	state.Forecast = 0
+	decision = autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+}
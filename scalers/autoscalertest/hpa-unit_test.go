@@ -0,0 +1,161 @@
+//go:build autoscalertest
+// +build autoscalertest
+
+package autoscalertest
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tholiang/podoscaler/scalers/autoscaler"
+	"github.com/tholiang/podoscaler/scalers/util"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// an hpa on the mock deployment, scaling it between minReplicas and maxReplicas
+func makeHPA(minReplicas int32, maxReplicas int32) *autoscalingv2.HorizontalPodAutoscaler {
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	hpa.Name = MOCK_DEPLOYMENT_NAME + "-hpa"
+	hpa.Namespace = MOCK_DEPLOYMENT_NAMESPACE
+	hpa.Spec.ScaleTargetRef = autoscalingv2.CrossVersionObjectReference{Kind: "Deployment", Name: MOCK_DEPLOYMENT_NAME, APIVersion: "apps/v1"}
+	hpa.Spec.MinReplicas = &minReplicas
+	hpa.Spec.MaxReplicas = maxReplicas
+	return hpa
+}
+
+// same as BasicHscaleUp, which goes to 4 pods at 450 without an hpa
+func hpaMockMetrics(mode autoscaler.HPAInteropMode) *MockMetrics {
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 1.5
+	mm.RelDeploymentUtil = 2
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+	mm.HPA = makeHPA(5, 8)
+	mm.DeploymentAnnotations = map[string]string{autoscaler.HPA_INTEROP_ANNOTATION: string(mode)}
+	return mm
+}
+
+func TestUnit_HPARefuse(t *testing.T) {
+	mm := hpaMockMetrics(autoscaler.RefuseHPAInterop)
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 500, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	// only the deployment is skipped, and the warning is recorded once
+	for range 2 {
+		err = a.RunRound(t.Context())
+		AssertNoError(err, t)
+	}
+	AssertNoActions(mm, t)
+	if plan := a.LastPlan(); len(plan.Deployments) != 1 || !strings.Contains(plan.Deployments[0].Error, "hpa interop refuse") {
+		t.Errorf("expected the deployment skipped with an error, got %+v", plan.Deployments)
+	}
+	if !slices.Equal(mm.Events, []string{v1.EventTypeWarning + " " + autoscaler.HPA_CONFLICT_EVENT}) {
+		t.Errorf("expected one conflict warning, got %v", mm.Events)
+	}
+	if util.HPADisabled(mm.HPA) {
+		t.Errorf("expected the hpa left alone")
+	}
+
+	// recorded again once the hpa comes back after being removed
+	mm.HPA = nil
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+	mm.Actions = nil
+	mm.HPA = makeHPA(5, 8)
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+	if len(mm.Events) != 2 {
+		t.Errorf("expected the conflict recorded again, got %v", mm.Events)
+	}
+}
+
+func TestUnit_HPATakeover(t *testing.T) {
+	mm := hpaMockMetrics(autoscaler.TakeoverHPAInterop)
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 500, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	// the hpa's min of 5 replicas holds, 360m does not fit so placement settles on 6 at 300m
+	AssertAction(mm, t, Action{Type: DisableHPAAction, HPAName: MOCK_DEPLOYMENT_NAME + "-hpa"})
+	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 6})
+	AssertNoActions(mm, t)
+	if !util.HPADisabled(mm.HPA) {
+		t.Errorf("expected the hpa disabled")
+	}
+	if !slices.Equal(mm.Events, []string{v1.EventTypeNormal + " " + autoscaler.HPA_TAKEN_OVER_EVENT}) {
+		t.Errorf("expected one takeover event, got %v", mm.Events)
+	}
+	if plan := a.LastPlan(); len(plan.Deployments) != 1 || plan.Deployments[0].HPAInterop != string(autoscaler.TakeoverHPAInterop) {
+		t.Errorf("expected the takeover in the round plan, got %+v", plan.Deployments)
+	}
+}
+
+func TestUnit_HPAVertical(t *testing.T) {
+	mm := hpaMockMetrics(autoscaler.VerticalHPAInterop)
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 500, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	// replicas are the hpa's, the 4 pods needed are made up for with larger requests
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "600m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "600m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod3", ContainerName: "container", CpuRequests: "600m"})
+	AssertNoActions(mm, t)
+	if util.HPADisabled(mm.HPA) {
+		t.Errorf("expected the hpa left alone")
+	}
+	if !slices.Equal(mm.Events, []string{v1.EventTypeNormal + " " + autoscaler.HPA_OWNS_REPLICAS_EVENT}) {
+		t.Errorf("expected one vertical only event, got %v", mm.Events)
+	}
+}
+
+func TestUnit_DisableHPA(t *testing.T) {
+	hpa := makeHPA(2, 4)
+	other := makeHPA(1, 2)
+	other.Name, other.Spec.ScaleTargetRef.Name = "other-hpa", "other"
+	clientset := fake.NewSimpleClientset(other, hpa)
+
+	found, err := util.FindHPA(t.Context(), clientset, MOCK_DEPLOYMENT_NAME, MOCK_DEPLOYMENT_NAMESPACE)
+	AssertNoError(err, t)
+	if found == nil || found.Name != hpa.Name {
+		t.Fatalf("expected %s, got %v", hpa.Name, found)
+	}
+	missing, err := util.FindHPA(t.Context(), clientset, "missing", MOCK_DEPLOYMENT_NAMESPACE)
+	AssertNoError(err, t)
+	if missing != nil {
+		t.Errorf("expected no hpa, got %s", missing.Name)
+	}
+
+	err = util.DisableHPA(t.Context(), clientset, found)
+	AssertNoError(err, t)
+	disabled, err := util.FindHPA(t.Context(), clientset, MOCK_DEPLOYMENT_NAME, MOCK_DEPLOYMENT_NAMESPACE)
+	AssertNoError(err, t)
+	if !util.HPADisabled(disabled) {
+		t.Errorf("expected the hpa disabled, got %+v", disabled.Spec.Behavior)
+	}
+	original := disabled.Annotations[util.HPA_ORIGINAL_BEHAVIOR_ANNOTATION]
+	if err := json.Unmarshal([]byte(original), new(autoscalingv2.HorizontalPodAutoscalerBehavior)); err != nil {
+		t.Errorf("expected the original behavior kept, got %q: %s", original, err.Error())
+	}
+
+	// already disabled is left as is, the original behavior is not overwritten
+	calls := len(clientset.Actions())
+	err = util.DisableHPA(t.Context(), clientset, disabled)
+	AssertNoError(err, t)
+	if len(clientset.Actions()) != calls {
+		t.Errorf("expected no patch for a disabled hpa")
+	}
+}
@@ -4,11 +4,17 @@
 package autoscalertest
 
 import (
+	"context"
 	"fmt"
+	"time"
 
+	"github.com/tholiang/podoscaler/scalers/api/v1alpha1"
+	"github.com/tholiang/podoscaler/scalers/autoscaler"
 	"github.com/tholiang/podoscaler/scalers/util"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	kube_client "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -31,21 +37,17 @@ func IntAssertIntsEqual(i1 int, i2 int) {
 func IntSummarizeActions(mm *MockMetrics) {
 	vscales := 0
 	changereplicas := 0
-	deletes := 0
 	for _, d := range mm.Actions {
 		if d.Type == VscaleAction {
 			vscales++
 		} else if d.Type == ChangeReplicaCountAction {
 			changereplicas++
-		} else if d.Type == DeletePodAction {
-			deletes++
 		}
 	}
 
 	fmt.Println("<<< actions performed: ")
 	fmt.Printf("%d vscales\n", vscales)
 	fmt.Printf("%d replica count changes\n", changereplicas)
-	fmt.Printf("%d deletes\n", deletes)
 	fmt.Println(">>>")
 }
 
@@ -126,27 +128,48 @@ func IntMockMetricsClientset(m *MockMetrics, config *rest.Config) (*metrics_clie
 	return metrics_client.NewForConfig(config)
 }
 
-func IntMockNodeList(m *MockMetrics, clientset kube_client.Interface) (*v1.NodeList, error) {
-	return util.GetNodeList(clientset)
+func IntMockNodeList(m *MockMetrics, ctx context.Context, clientset kube_client.Interface) (*v1.NodeList, error) {
+	return util.GetNodeList(ctx, util.NewAPIReader(clientset))
 }
 
-func IntMockControlledDeployments(m *MockMetrics, clientset kube_client.Interface) (*appsv1.DeploymentList, error) {
-	return util.GetControlledDeployments(clientset)
+func IntMockDynamicClient(m *MockMetrics, config *rest.Config) (dynamic.Interface, error) {
+	return util.GetDynamicClient(config)
 }
 
-func IntMockReadyPodListForDeployment(m *MockMetrics, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
-	return util.GetReadyPodListForDeployment(clientset, deploymentName, namespace)
+func IntMockPodoscalerPolicies(m *MockMetrics, ctx context.Context, dynamicClient dynamic.Interface) (*v1alpha1.PodoscalerPolicyList, error) {
+	return util.ListPodoscalerPolicies(ctx, dynamicClient)
 }
 
-func IntMockDeploymentUtilAndAlloc(m *MockMetrics, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod) (int64, int64, error) {
-	_, alloc, err := util.GetDeploymentUtilAndAlloc(clientset, metricsClient, deploymentName, namespace, podList)
+func IntMockUpdatePodoscalerPolicyStatus(m *MockMetrics, ctx context.Context, dynamicClient dynamic.Interface, policy *v1alpha1.PodoscalerPolicy) error {
+	m.PolicyStatus = *policy.Status.DeepCopy()
+	return util.PatchPodoscalerPolicyStatus(ctx, dynamicClient, policy.Namespace, policy.Name, policy.Status)
+}
+
+func IntMockDeployment(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*appsv1.Deployment, error) {
+	return util.GetDeployment(ctx, util.NewAPIReader(clientset), deploymentName, namespace)
+}
+
+func IntMockReadyPodListForDeployment(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
+	return util.GetReadyPodListForDeployment(ctx, util.NewAPIReader(clientset), deploymentName, namespace)
+}
+
+func IntMockUnschedulablePodListForDeployment(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
+	return util.GetUnschedulablePodListForDeployment(ctx, util.NewAPIReader(clientset), deploymentName, namespace)
+}
+
+func IntMockDeploymentUtilAndAlloc(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod, sidecars []string) (int64, int64, error) {
+	_, alloc, err := util.GetDeploymentUtilAndAlloc(ctx, util.NewAPIReader(clientset), metricsClient, deploymentName, namespace, podList, sidecars)
 	if err != nil {
 		return 0, 0, err
 	}
 	return int64(m.RelDeploymentUtil * float64(alloc)), alloc, nil
 }
 
-func IntMockNodeUsage(m *MockMetrics, metricsClient *metrics_client.Clientset, nodeName string) (int64, error) {
+func IntMockPodContainerUsage(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string) (map[string]map[string]util.ContainerUsage, error) {
+	return util.GetPodContainerUsage(ctx, util.NewAPIReader(clientset), metricsClient, deploymentName, namespace)
+}
+
+func IntMockNodeUsage(m *MockMetrics, ctx context.Context, metricsClient *metrics_client.Clientset, nodeName string) (int64, error) {
 	cap, ok := m.NodeCapacities[nodeName]
 	if !ok {
 		return 0, fmt.Errorf("node capacities is not set for node %s - sorry this is a workaround", nodeName)
@@ -159,29 +182,52 @@ func IntMockNodeUsage(m *MockMetrics, metricsClient *metrics_client.Clientset, n
 	return int64(usage * float64(cap)), nil
 }
 
-func IntMockNodeAllocableAndCapacity(m *MockMetrics, clientset kube_client.Interface, nodeName string) (int64, int64, error) {
-	return util.GetNodeAllocableAndCapacity(clientset, nodeName)
+func IntMockNodeAllocableAndCapacity(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, nodeName string) (int64, int64, error) {
+	return util.GetNodeAllocableAndCapacity(ctx, util.NewAPIReader(clientset), nodeName)
+}
+
+func IntMockNodeScheduling(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, nodeName string) (util.NodeScheduling, error) {
+	return util.GetNodeScheduling(ctx, util.NewAPIReader(clientset), nodeName)
 }
 
-func IntMockLatencyMetrics(m *MockMetrics, clientset kube_client.Interface) (map[string]float64, error) {
+func IntMockLatencyMetrics(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, source autoscaler.LatencySource) (map[string]float64, error) {
+	latency, ok := m.SourceLatencies[source.String()]
+	if !ok {
+		latency = m.Latency
+	}
 	metrics := map[string]float64{
-		"p99": m.Latency,
+		"p99": latency,
 	}
 	return metrics, nil
 }
 
-func IntMockVScale(m *MockMetrics, clientset kube_client.Interface, podname string, containername string, cpurequests string, namespace string) error {
-	err := util.VScale(clientset, podname, containername, cpurequests, namespace)
+func IntMockTrafficMetrics(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, source autoscaler.LatencySource, requestsQuery string, errorsQuery string) (util.Traffic, error) {
+	if m.TrafficErr != nil {
+		return util.Traffic{}, m.TrafficErr
+	}
+	return util.Traffic{Requests: m.RequestRate, Errors: m.ErrorRate}, nil
+}
+
+func IntMockVScale(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string) error {
+	err := util.VScale(ctx, clientset, podname, containername, resources, namespace)
 	if err != nil {
 		return err
 	}
 
-	m.Actions = append(m.Actions, Action{Type: VscaleAction, PodName: podname, ContainerName: containername, CpuRequests: cpurequests, Namespace: namespace})
+	m.Actions = append(m.Actions, Action{Type: VscaleAction, PodName: podname, ContainerName: containername, CpuRequests: resources.Requests.CPU, MemRequests: resources.Requests.Memory, CpuLimits: resources.Limits.CPU, Namespace: namespace})
 	return nil
 }
 
-func IntMockChangeReplicaCount(m *MockMetrics, namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error {
-	err := util.ChangeReplicaCount(namespace, deploymentName, replicaCt, clientset)
+func IntMockWaitForResize(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string, timeout time.Duration) error {
+	return util.WaitForResize(ctx, clientset, podname, containername, resources, namespace, timeout)
+}
+
+func IntMockPatchDeploymentReqs(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName string, containername string, resources util.VerticalPatchContainerResources, namespace string) error {
+	return util.PatchDeploymentReqs(ctx, clientset, deploymentName, containername, resources, namespace)
+}
+
+func IntMockChangeReplicaCount(m *MockMetrics, ctx context.Context, namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error {
+	err := util.ChangeReplicaCount(ctx, namespace, deploymentName, replicaCt, clientset)
 	if err != nil {
 		return err
 	}
@@ -190,13 +236,37 @@ func IntMockChangeReplicaCount(m *MockMetrics, namespace string, deploymentName
 	return nil
 }
 
-func IntMockDeletePod(m *MockMetrics, clientset kube_client.Interface, podname string, namespace string) error {
-	err := util.DeletePod(clientset, podname, namespace)
+func IntMockMigratePod(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, podname string, nodename string, namespace string, timeout time.Duration) (string, error) {
+	replacement, err := util.MigratePod(ctx, clientset, podname, nodename, namespace, timeout)
+	if err != nil {
+		return "", err
+	}
+
+	m.Actions = append(m.Actions, Action{Type: MigratePodAction, PodName: podname, NodeName: nodename, Namespace: namespace})
+	return replacement, nil
+}
+
+func IntMockHPAForDeployment(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	return util.FindHPA(ctx, clientset, deploymentName, namespace)
+}
+
+func IntMockDisableHPA(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, hpa *autoscalingv2.HorizontalPodAutoscaler) error {
+	err := util.DisableHPA(ctx, clientset, hpa)
+	if err != nil {
+		return err
+	}
+
+	m.Actions = append(m.Actions, Action{Type: DisableHPAAction, HPAName: hpa.Name, Namespace: hpa.Namespace})
+	return nil
+}
+
+func IntMockRecordEvent(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, object v1.ObjectReference, eventType string, reason string, message string) error {
+	err := util.RecordEvent(ctx, clientset, object, eventType, reason, message)
 	if err != nil {
 		return err
 	}
 
-	m.Actions = append(m.Actions, Action{Type: DeletePodAction, PodName: podname, Namespace: namespace})
+	m.Events = append(m.Events, eventType+" "+reason)
 	return nil
 }
 
@@ -206,15 +276,27 @@ func CreateIntMockMetrics() *MockMetrics {
 	mm.MockGetClientset = IntMockClientset
 	mm.MockGetMetricsClientset = IntMockMetricsClientset
 	mm.MockGetNodeList = IntMockNodeList
-	mm.MockGetControlledDeployments = IntMockControlledDeployments
+	mm.MockGetDynamicClient = IntMockDynamicClient
+	mm.MockGetPodoscalerPolicies = IntMockPodoscalerPolicies
+	mm.MockUpdatePodoscalerPolicyStatus = IntMockUpdatePodoscalerPolicyStatus
+	mm.MockGetDeployment = IntMockDeployment
 	mm.MockGetReadyPodListForDeployment = IntMockReadyPodListForDeployment
+	mm.MockGetUnschedulablePodListForDeployment = IntMockUnschedulablePodListForDeployment
 	mm.MockGetDeploymentUtilAndAlloc = IntMockDeploymentUtilAndAlloc
+	mm.MockGetPodContainerUsage = IntMockPodContainerUsage
 	mm.MockGetNodeUsage = IntMockNodeUsage
 	mm.MockGetNodeAllocableAndCapacity = IntMockNodeAllocableAndCapacity
+	mm.MockGetNodeScheduling = IntMockNodeScheduling
 	mm.MockGetLatencyMetrics = IntMockLatencyMetrics
+	mm.MockGetTrafficMetrics = IntMockTrafficMetrics
 	mm.MockVScale = IntMockVScale
+	mm.MockWaitForResize = IntMockWaitForResize
+	mm.MockPatchDeploymentReqs = IntMockPatchDeploymentReqs
 	mm.MockChangeReplicaCount = IntMockChangeReplicaCount
-	mm.MockDeletePod = IntMockDeletePod
+	mm.MockMigratePod = IntMockMigratePod
+	mm.MockGetHPAForDeployment = IntMockHPAForDeployment
+	mm.MockDisableHPA = IntMockDisableHPA
+	mm.MockRecordEvent = IntMockRecordEvent
 
 	// default values
 	mm.DeploymentName = "dummy"
@@ -0,0 +1,75 @@
+//go:build autoscalertest
+// +build autoscalertest
+
+package autoscalertest
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tholiang/podoscaler/scalers/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUnit_RunLeaderElected(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	config := util.LeaderElectionConfig{LeaseName: "podoscaler-test", LeaseNamespace: MOCK_DEPLOYMENT_NAMESPACE, Identity: "replica-1"}
+	status := &util.LeaderStatus{Identity: config.Identity}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	started := make(chan struct{})
+	var returned atomic.Bool
+	run := func(runCtx context.Context) {
+		close(started)
+		<-runCtx.Done()
+		time.Sleep(100 * time.Millisecond) // a round winding down
+		returned.Store(true)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- util.RunLeaderElected(ctx, clientset, config, status, run)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the lease won and run started")
+	}
+	if !status.IsLeader() {
+		t.Errorf("expected to lead while run is going")
+	}
+	recorder := httptest.NewRecorder()
+	status.ServeHTTP(recorder, nil)
+	if !strings.Contains(recorder.Body.String(), `podoscaler_leader{identity="replica-1"} 1`) {
+		t.Errorf("expected the leader gauge at 1, got %q", recorder.Body.String())
+	}
+
+	// shutting down cancels run's context, and only returns once run has
+	cancel()
+	select {
+	case err := <-done:
+		AssertNoError(err, t)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected RunLeaderElected to return after shutdown")
+	}
+	if !returned.Load() {
+		t.Errorf("expected run to have returned before RunLeaderElected")
+	}
+	if status.IsLeader() {
+		t.Errorf("expected to stop leading on shutdown")
+	}
+
+	// the lease is released for a standby
+	lease, err := clientset.CoordinationV1().Leases(config.LeaseNamespace).Get(t.Context(), config.LeaseName, metav1.GetOptions{})
+	AssertNoError(err, t)
+	if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != "" {
+		t.Errorf("expected the lease released, held by %s", *lease.Spec.HolderIdentity)
+	}
+}
@@ -0,0 +1,73 @@
+//go:build autoscalertest
+// +build autoscalertest
+
+package autoscalertest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tholiang/podoscaler/scalers/util"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// a clientset holding pod1 on node1, where every created pod gets a name and the given condition
+func migrationClientset(condition v1.PodCondition) *fake.Clientset {
+	pod := MakePod("pod1", "node1", "container", 300)
+	pod.Namespace = MOCK_DEPLOYMENT_NAMESPACE
+	pod.GenerateName = "testapp-abc-"
+	pod.Labels = map[string]string{"app": "testapp", util.POD_TEMPLATE_HASH_LABEL: "abc"}
+
+	clientset := fake.NewSimpleClientset(&pod)
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		created := action.(k8stesting.CreateAction).GetObject().(*v1.Pod)
+		created.Name = created.GenerateName + "xyz"
+		created.Status.Conditions = []v1.PodCondition{condition}
+		return false, nil, nil // the tracker stores the pod as changed here
+	})
+	return clientset
+}
+
+func TestUnit_MigratePod(t *testing.T) {
+	clientset := migrationClientset(v1.PodCondition{Type: v1.PodReady, Status: v1.ConditionTrue})
+	replacement, err := util.MigratePod(t.Context(), clientset, "pod1", "node2", MOCK_DEPLOYMENT_NAMESPACE, time.Second)
+	AssertNoError(err, t)
+
+	pods, err := clientset.CoreV1().Pods(MOCK_DEPLOYMENT_NAMESPACE).List(t.Context(), metav1.ListOptions{})
+	AssertNoError(err, t)
+	if len(pods.Items) != 1 || pods.Items[0].Name != replacement {
+		t.Fatalf("expected only the replacement %s left, got %+v", replacement, pods.Items)
+	}
+
+	pod := pods.Items[0]
+	if pod.Labels[util.POD_TEMPLATE_HASH_LABEL] != "abc" || pod.Labels["app"] != "testapp" {
+		t.Errorf("expected the replacement to carry the original labels, got %v", pod.Labels)
+	}
+	if pod.Spec.NodeName != "" || pod.Annotations[util.MIGRATED_FROM_ANNOTATION] != "pod1" {
+		t.Errorf("expected an unscheduled replacement of pod1, got node %q and annotations %v", pod.Spec.NodeName, pod.Annotations)
+	}
+	terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || len(terms[0].MatchFields) != 1 || terms[0].MatchFields[0].Values[0] != "node2" {
+		t.Errorf("expected the replacement pinned to node2, got %+v", terms)
+	}
+}
+
+func TestUnit_MigratePodUnschedulable(t *testing.T) {
+	clientset := migrationClientset(v1.PodCondition{Type: v1.PodScheduled, Status: v1.ConditionFalse, Reason: v1.PodReasonUnschedulable, Message: "Insufficient cpu"})
+	_, err := util.MigratePod(t.Context(), clientset, "pod1", "node2", MOCK_DEPLOYMENT_NAMESPACE, time.Second)
+	if !errors.Is(err, util.ErrMigrationFailed) {
+		t.Errorf("expected a failed migration, got %v", err)
+	}
+
+	// the original keeps serving, the replacement is cleaned up
+	pods, err := clientset.CoreV1().Pods(MOCK_DEPLOYMENT_NAMESPACE).List(t.Context(), metav1.ListOptions{})
+	AssertNoError(err, t)
+	if len(pods.Items) != 1 || pods.Items[0].Name != "pod1" {
+		t.Errorf("expected only pod1 left, got %+v", pods.Items)
+	}
+}
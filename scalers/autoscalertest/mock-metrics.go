@@ -4,18 +4,37 @@
 package autoscalertest
 
 import (
+	"context"
+	"time"
+
+	"github.com/tholiang/podoscaler/scalers/api/v1alpha1"
+	"github.com/tholiang/podoscaler/scalers/autoscaler"
+	util "github.com/tholiang/podoscaler/scalers/util"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
 	kube_client "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	metrics_client "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
+type ContainerData struct {
+	Name        string
+	CpuRequests int64
+	MemRequests int64 // in bytes, 0 leaves memory requests unset
+	MemUsage    int64 // working set in bytes
+}
+
 type PodData struct {
 	PodName       string
 	NodeName      string
 	ContainerName string
 	CpuRequests   int64
+	MemRequests   int64           // in bytes, 0 leaves memory requests unset
+	MemUsage      int64           // working set in bytes
+	CpuLimits     int64           // 0 leaves the limit unset
+	Extra         []ContainerData // sidecars or more app containers, placed before the main container
 }
 type MockPodList map[string]PodData
 
@@ -24,7 +43,8 @@ type ActionType string
 const (
 	VscaleAction             ActionType = "vscale"
 	ChangeReplicaCountAction ActionType = "change replica"
-	DeletePodAction          ActionType = "delete"
+	MigratePodAction         ActionType = "migrate"
+	DisableHPAAction         ActionType = "disable hpa"
 )
 
 type Action struct {
@@ -32,36 +52,66 @@ type Action struct {
 	Namespace      string // change replica, delete
 	DeploymentName string // change replica
 	ReplicaCt      int    // change replica
-	PodName        string // vscale, delete
+	PodName        string // vscale, delete, migrate
+	NodeName       string // migrate
 	ContainerName  string // vscale
 	CpuRequests    string // vscale
+	MemRequests    string // vscale
+	CpuLimits      string // vscale
+	HPAName        string // disable hpa
 }
 
 type MockMetrics struct {
-	DeploymentName      string
-	DeploymentNamespace string
-	Pods                MockPodList
-	Latency             float64
-	RelNodeUsages       map[string]float64
-	NodeAllocables      map[string]int64
-	NodeCapacities      map[string]int64
-	RelDeploymentUtil   float64
-
-	MockGetKubernetesConfig          func(m *MockMetrics) (*rest.Config, error)
-	MockGetClientset                 func(m *MockMetrics, config *rest.Config) (*kube_client.Clientset, error)
-	MockGetMetricsClientset          func(m *MockMetrics, config *rest.Config) (*metrics_client.Clientset, error)
-	MockGetNodeList                  func(m *MockMetrics, clientset kube_client.Interface) (*v1.NodeList, error)
-	MockGetReadyPodListForDeployment func(m *MockMetrics, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error)
-	MockGetDeploymentUtilAndAlloc    func(m *MockMetrics, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod) (int64, int64, error)
-	MockGetNodeUsage                 func(m *MockMetrics, metricsClient *metrics_client.Clientset, nodeName string) (int64, error)
-	MockGetNodeAllocableAndCapacity  func(m *MockMetrics, clientset kube_client.Interface, nodeName string) (int64, int64, error)
-	MockGetLatencyMetrics            func(m *MockMetrics, clientset kube_client.Interface) (map[string]float64, error)
-	MockVScale                       func(m *MockMetrics, clientset kube_client.Interface, podname string, containername string, cpurequests string, namespace string) error
-	MockChangeReplicaCount           func(m *MockMetrics, namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error
-	MockGetControlledDeployments     func(m *MockMetrics, clientset kube_client.Interface) (*appsv1.DeploymentList, error)
-	MockDeletePod                    func(m *MockMetrics, clientset kube_client.Interface, podname string, namespace string) error
-
-	Actions []Action // log in MockVScale, MockChangeReplicaCount, MockDeletePod implementations
+	DeploymentName        string
+	DeploymentNamespace   string
+	DeploymentAnnotations map[string]string
+	Pods                  MockPodList
+	Latency               float64
+	SourceLatencies       map[string]float64 // LatencySource.String() to latency, falls back to Latency
+	Percentiles           map[string]float64 // reported next to p99, in seconds
+	RequestRate           float64            // per second
+	ErrorRate             float64            // 5xx per second
+	TrafficErr            error
+	RelNodeUsages         map[string]float64
+	NodeAllocables        map[string]int64
+	NodeCapacities        map[string]int64
+	NodeMemAllocables     map[string]int64 // MOCK_NODE_MEMORY if unset
+	NodeLabels            map[string]map[string]string
+	NodeTaints            map[string][]v1.Taint
+	Templates             map[string]util.VerticalPatchContainerResources // last deployment patch, by container name
+	RelDeploymentUtil     float64
+	PolicySpec            v1alpha1.PodoscalerPolicySpec          // targetRef is filled in if left empty
+	PolicyStatus          v1alpha1.PodoscalerPolicyStatus        // last status written
+	HPA                   *autoscalingv2.HorizontalPodAutoscaler // nil if no hpa targets the deployment
+	Events                []string                               // "type reason" of every recorded event
+
+	MockGetKubernetesConfig                  func(m *MockMetrics) (*rest.Config, error)
+	MockGetClientset                         func(m *MockMetrics, config *rest.Config) (*kube_client.Clientset, error)
+	MockGetMetricsClientset                  func(m *MockMetrics, config *rest.Config) (*metrics_client.Clientset, error)
+	MockGetDynamicClient                     func(m *MockMetrics, config *rest.Config) (dynamic.Interface, error)
+	MockGetNodeList                          func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface) (*v1.NodeList, error)
+	MockGetReadyPodListForDeployment         func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error)
+	MockGetUnschedulablePodListForDeployment func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error)
+	MockGetDeploymentUtilAndAlloc            func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod, sidecars []string) (int64, int64, error)
+	MockGetPodContainerUsage                 func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string) (map[string]map[string]util.ContainerUsage, error)
+	MockGetNodeUsage                         func(m *MockMetrics, ctx context.Context, metricsClient *metrics_client.Clientset, nodeName string) (int64, error)
+	MockGetNodeAllocableAndCapacity          func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, nodeName string) (int64, int64, error)
+	MockGetNodeScheduling                    func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, nodeName string) (util.NodeScheduling, error)
+	MockGetLatencyMetrics                    func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, source autoscaler.LatencySource) (map[string]float64, error)
+	MockGetTrafficMetrics                    func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, source autoscaler.LatencySource, requestsQuery string, errorsQuery string) (util.Traffic, error)
+	MockVScale                               func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string) error
+	MockWaitForResize                        func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string, timeout time.Duration) error
+	MockPatchDeploymentReqs                  func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName string, containername string, resources util.VerticalPatchContainerResources, namespace string) error
+	MockChangeReplicaCount                   func(m *MockMetrics, ctx context.Context, namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error
+	MockGetPodoscalerPolicies                func(m *MockMetrics, ctx context.Context, dynamicClient dynamic.Interface) (*v1alpha1.PodoscalerPolicyList, error)
+	MockUpdatePodoscalerPolicyStatus         func(m *MockMetrics, ctx context.Context, dynamicClient dynamic.Interface, policy *v1alpha1.PodoscalerPolicy) error
+	MockGetDeployment                        func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*appsv1.Deployment, error)
+	MockMigratePod                           func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, podname string, nodename string, namespace string, timeout time.Duration) (string, error)
+	MockGetHPAForDeployment                  func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*autoscalingv2.HorizontalPodAutoscaler, error)
+	MockDisableHPA                           func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, hpa *autoscalingv2.HorizontalPodAutoscaler) error
+	MockRecordEvent                          func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, object v1.ObjectReference, eventType string, reason string, message string) error
+
+	Actions []Action // log in MockVScale, MockChangeReplicaCount, MockMigratePod, MockDisableHPA implementations
 }
 
 func (m *MockMetrics) GetKubernetesConfig() (*rest.Config, error) {
@@ -73,35 +123,76 @@ func (m *MockMetrics) GetClientset(config *rest.Config) (*kube_client.Clientset,
 func (m *MockMetrics) GetMetricsClientset(config *rest.Config) (*metrics_client.Clientset, error) {
 	return m.MockGetMetricsClientset(m, config)
 }
-func (m *MockMetrics) GetNodeList(clientset kube_client.Interface) (*v1.NodeList, error) {
-	return m.MockGetNodeList(m, clientset)
+func (m *MockMetrics) GetDynamicClient(config *rest.Config) (dynamic.Interface, error) {
+	return m.MockGetDynamicClient(m, config)
+}
+func (m *MockMetrics) GetNodeList(ctx context.Context, clientset kube_client.Interface) (*v1.NodeList, error) {
+	return m.MockGetNodeList(m, ctx, clientset)
+}
+func (m *MockMetrics) GetReadyPodListForDeployment(ctx context.Context, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
+	return m.MockGetReadyPodListForDeployment(m, ctx, clientset, deploymentName, namespace)
+}
+func (m *MockMetrics) GetUnschedulablePodListForDeployment(ctx context.Context, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
+	return m.MockGetUnschedulablePodListForDeployment(m, ctx, clientset, deploymentName, namespace)
+}
+func (m *MockMetrics) GetDeploymentUtilAndAlloc(ctx context.Context, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod, sidecars []string) (int64, int64, error) {
+	return m.MockGetDeploymentUtilAndAlloc(m, ctx, clientset, metricsClient, deploymentName, namespace, podList, sidecars)
+}
+func (m *MockMetrics) GetPodContainerUsage(ctx context.Context, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string) (map[string]map[string]util.ContainerUsage, error) {
+	return m.MockGetPodContainerUsage(m, ctx, clientset, metricsClient, deploymentName, namespace)
+}
+func (m *MockMetrics) GetNodeUsage(ctx context.Context, metricsClient *metrics_client.Clientset, nodeName string) (int64, error) {
+	return m.MockGetNodeUsage(m, ctx, metricsClient, nodeName)
 }
-func (m *MockMetrics) GetReadyPodListForDeployment(clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
-	return m.MockGetReadyPodListForDeployment(m, clientset, deploymentName, namespace)
+func (m *MockMetrics) GetNodeAllocableAndCapacity(ctx context.Context, clientset kube_client.Interface, nodeName string) (int64, int64, error) {
+	return m.MockGetNodeAllocableAndCapacity(m, ctx, clientset, nodeName)
 }
-func (m *MockMetrics) GetDeploymentUtilAndAlloc(clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod) (int64, int64, error) {
-	return m.MockGetDeploymentUtilAndAlloc(m, clientset, metricsClient, deploymentName, namespace, podList)
+func (m *MockMetrics) GetNodeScheduling(ctx context.Context, clientset kube_client.Interface, nodeName string) (util.NodeScheduling, error) {
+	return m.MockGetNodeScheduling(m, ctx, clientset, nodeName)
 }
-func (m *MockMetrics) GetNodeUsage(metricsClient *metrics_client.Clientset, nodeName string) (int64, error) {
-	return m.MockGetNodeUsage(m, metricsClient, nodeName)
+func (m *MockMetrics) GetLatencyMetrics(ctx context.Context, clientset kube_client.Interface, source autoscaler.LatencySource) (map[string]float64, error) {
+	return m.MockGetLatencyMetrics(m, ctx, clientset, source)
 }
-func (m *MockMetrics) GetNodeAllocableAndCapacity(clientset kube_client.Interface, nodeName string) (int64, int64, error) {
-	return m.MockGetNodeAllocableAndCapacity(m, clientset, nodeName)
+func (m *MockMetrics) GetTrafficMetrics(ctx context.Context, clientset kube_client.Interface, source autoscaler.LatencySource, requestsQuery string, errorsQuery string) (util.Traffic, error) {
+	return m.MockGetTrafficMetrics(m, ctx, clientset, source, requestsQuery, errorsQuery)
 }
-func (m *MockMetrics) GetLatencyMetrics(clientset kube_client.Interface) (map[string]float64, error) {
-	return m.MockGetLatencyMetrics(m, clientset)
+func (m *MockMetrics) VScale(ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string) error {
+	return m.MockVScale(m, ctx, clientset, podname, containername, resources, namespace)
 }
-func (m *MockMetrics) VScale(clientset kube_client.Interface, podname string, containername string, cpurequests string, namespace string) error {
-	return m.MockVScale(m, clientset, podname, containername, cpurequests, namespace)
+func (m *MockMetrics) WaitForResize(ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string, timeout time.Duration) error {
+	return m.MockWaitForResize(m, ctx, clientset, podname, containername, resources, namespace, timeout)
 }
-func (m *MockMetrics) ChangeReplicaCount(namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error {
-	return m.MockChangeReplicaCount(m, namespace, deploymentName, replicaCt, clientset)
+func (m *MockMetrics) PatchDeploymentReqs(ctx context.Context, clientset kube_client.Interface, deploymentName string, containername string, resources util.VerticalPatchContainerResources, namespace string) error {
+	return m.MockPatchDeploymentReqs(m, ctx, clientset, deploymentName, containername, resources, namespace)
+}
+func (m *MockMetrics) ChangeReplicaCount(ctx context.Context, namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error {
+	return m.MockChangeReplicaCount(m, ctx, namespace, deploymentName, replicaCt, clientset)
+}
+
+func (m *MockMetrics) GetPodoscalerPolicies(ctx context.Context, dynamicClient dynamic.Interface) (*v1alpha1.PodoscalerPolicyList, error) {
+	return m.MockGetPodoscalerPolicies(m, ctx, dynamicClient)
+}
+
+func (m *MockMetrics) UpdatePodoscalerPolicyStatus(ctx context.Context, dynamicClient dynamic.Interface, policy *v1alpha1.PodoscalerPolicy) error {
+	return m.MockUpdatePodoscalerPolicyStatus(m, ctx, dynamicClient, policy)
+}
+
+func (m *MockMetrics) GetDeployment(ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*appsv1.Deployment, error) {
+	return m.MockGetDeployment(m, ctx, clientset, deploymentName, namespace)
+}
+
+func (m *MockMetrics) MigratePod(ctx context.Context, clientset kube_client.Interface, podname string, nodename string, namespace string, timeout time.Duration) (string, error) {
+	return m.MockMigratePod(m, ctx, clientset, podname, nodename, namespace, timeout)
+}
+
+func (m *MockMetrics) GetHPAForDeployment(ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	return m.MockGetHPAForDeployment(m, ctx, clientset, deploymentName, namespace)
 }
 
-func (m *MockMetrics) GetControlledDeployments(clientset kube_client.Interface) (*appsv1.DeploymentList, error) {
-	return m.MockGetControlledDeployments(m, clientset)
+func (m *MockMetrics) DisableHPA(ctx context.Context, clientset kube_client.Interface, hpa *autoscalingv2.HorizontalPodAutoscaler) error {
+	return m.MockDisableHPA(m, ctx, clientset, hpa)
 }
 
-func (m *MockMetrics) DeletePod(clientset kube_client.Interface, podname string, namespace string) error {
-	return m.MockDeletePod(m, clientset, podname, namespace)
+func (m *MockMetrics) RecordEvent(ctx context.Context, clientset kube_client.Interface, object v1.ObjectReference, eventType string, reason string, message string) error {
+	return m.MockRecordEvent(m, ctx, clientset, object, eventType, reason, message)
 }
@@ -0,0 +1,494 @@
+//go:build autoscalertest
+// +build autoscalertest
+
+package autoscalertest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tholiang/podoscaler/scalers/autoscaler"
+	"github.com/tholiang/podoscaler/scalers/util"
+)
+
+/* PLANNER UNIT TESTS - CHECK DECISIONS DIRECTLY, NOTHING IS EXECUTED */
+func simplePlannerPods() MockPodList {
+	return MockPodList{
+		"pod1": {PodName: "pod1", NodeName: "node1", ContainerName: "container", CpuRequests: 300},
+		"pod2": {PodName: "pod2", NodeName: "node1", ContainerName: "container", CpuRequests: 300},
+		"pod3": {PodName: "pod3", NodeName: "node2", ContainerName: "container", CpuRequests: 300},
+	}
+}
+
+const MiB = 1024 * 1024
+
+func memoryPlannerPods(memRequests int64, memUsage int64) MockPodList {
+	pods := simplePlannerPods()
+	for k, p := range pods {
+		p.MemRequests = memRequests
+		p.MemUsage = memUsage
+		pods[k] = p
+	}
+	return pods
+}
+
+func TestPlanner_Stable(t *testing.T) {
+	state := MakePlannerState(simplePlannerPods(), 0.9, 95, MakePlannerPolicy(500, 100))
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+}
+
+func TestPlanner_HscaleUp(t *testing.T) {
+	state := MakePlannerState(simplePlannerPods(), 2, 150, MakePlannerPolicy(500, 100))
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 4},
+		{Type: autoscaler.VScaleAction, CpuRequests: 450},
+	}, t)
+}
+
+func TestPlanner_FewerLargerPods(t *testing.T) {
+	// 1800m used over 3 pods, maps 1000 -> 2 pods at 900
+	state := MakePlannerState(simplePlannerPods(), 2, 150, MakePlannerPolicy(1000, 100))
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.VScaleAction, CpuRequests: 900},
+		{Type: autoscaler.HScaleAction, Replicas: 2},
+		{Type: autoscaler.VScaleAction, CpuRequests: 900},
+	}, t)
+}
+
+func TestPlanner_MigrateOffCongestedNode(t *testing.T) {
+	state := MakePlannerState(simplePlannerPods(), 1.1, 150, MakePlannerPolicy(400, 100))
+	state.Nodes["node1"] = autoscaler.NodeState{Name: "node1", Usage: 1000, Allocable: 10, Capacity: 1000}
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.MigratePodAction, PodName: "pod1", Node: "node2", Migration: true},
+		{Type: autoscaler.MigratePodAction, PodName: "pod2", Node: "node2", Migration: true},
+		{Type: autoscaler.VScaleAction, CpuRequests: 330},
+	}, t)
+}
+
+func TestPlanner_MigrationTargetHasRoom(t *testing.T) {
+	state := MakePlannerState(simplePlannerPods(), 1.1, 150, MakePlannerPolicy(400, 100))
+	state.Nodes["node1"] = autoscaler.NodeState{Name: "node1", Usage: 1000, Allocable: 10, Capacity: 1000}
+	state.Nodes["node2"] = autoscaler.NodeState{Name: "node2", Usage: 270, Allocable: 400, Capacity: 1000}
+	state.Nodes["node3"] = autoscaler.NodeState{Name: "node3", Usage: 100, Allocable: 500, Capacity: 1000}
+	// unrequested but congested, never a target
+	state.Nodes["node4"] = autoscaler.NodeState{Name: "node4", Usage: 900, Allocable: 900, Capacity: 1000}
+
+	// pod1 takes the most room, which leaves node3 too little for pod2
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.MigratePodAction, PodName: "pod1", Node: "node3", Migration: true},
+		{Type: autoscaler.MigratePodAction, PodName: "pod2", Node: "node2", Migration: true},
+		{Type: autoscaler.VScaleAction, CpuRequests: 330},
+	}, t)
+}
+
+func TestPlanner_MigrationNoNodeFits(t *testing.T) {
+	state := MakePlannerState(simplePlannerPods(), 1.1, 150, MakePlannerPolicy(400, 100))
+	state.Nodes["node1"] = autoscaler.NodeState{Name: "node1", Usage: 1000, Allocable: 10, Capacity: 1000}
+	state.Nodes["node2"] = autoscaler.NodeState{Name: "node2", Usage: 270, Allocable: 300, Capacity: 1000}
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+}
+
+func TestPlanner_MigrationBlockedByMaxReplicas(t *testing.T) {
+	policy := MakePlannerPolicy(400, 100)
+	policy.MaxReplicas = 3
+	state := MakePlannerState(simplePlannerPods(), 1.1, 150, policy)
+	state.Nodes["node1"] = autoscaler.NodeState{Name: "node1", Usage: 1000, Allocable: 10, Capacity: 1000}
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+}
+
+func TestPlanner_LatencyErrorBlocksDownscale(t *testing.T) {
+	state := MakePlannerState(simplePlannerPods(), 0.5, 0, MakePlannerPolicy(300, 100))
+	state.LatencyErr = fmt.Errorf("no datapoints")
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+}
+
+func TestPlanner_NoPods(t *testing.T) {
+	state := MakePlannerState(MockPodList{}, 0, 150, MakePlannerPolicy(300, 100))
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+}
+
+func TestPlanner_MemoryStable(t *testing.T) {
+	state := MakePlannerState(memoryPlannerPods(256*MiB, 180*MiB), 0.9, 95, MakePlannerPolicy(500, 100))
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+}
+
+func TestPlanner_MemoryGrowOnOOM(t *testing.T) {
+	state := MakePlannerState(memoryPlannerPods(256*MiB, 200*MiB), 0.9, 95, MakePlannerPolicy(500, 100))
+	state.Pods[1].OOMKilled = true
+
+	// max(256Mi * 1.5, 200Mi / 0.7) = 384Mi
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.VScaleAction, MemRequests: 384 * MiB},
+	}, t)
+}
+
+func TestPlanner_MemoryPressureRidesOnCpuVscale(t *testing.T) {
+	state := MakePlannerState(memoryPlannerPods(256*MiB, 250*MiB), 2, 150, MakePlannerPolicy(500, 100))
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 4},
+		{Type: autoscaler.VScaleAction, CpuRequests: 450, MemRequests: 384 * MiB},
+	}, t)
+}
+
+func TestPlanner_MemoryShrink(t *testing.T) {
+	state := MakePlannerState(memoryPlannerPods(256*MiB, 64*MiB), 0.9, 95, MakePlannerPolicy(500, 100))
+
+	// ceil(64Mi / 0.7), never below the working set
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.VScaleAction, MemRequests: 95869806},
+	}, t)
+}
+
+func TestPlanner_MemoryGrowClippedByMax(t *testing.T) {
+	policy := MakePlannerPolicy(500, 100)
+	policy.MaxMemory = 256 * MiB
+	state := MakePlannerState(memoryPlannerPods(256*MiB, 250*MiB), 0.9, 95, policy)
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+}
+
+func stabilizationPlannerPolicy(up time.Duration, down time.Duration) autoscaler.DeploymentPolicy {
+	policy := MakePlannerPolicy(300, 100)
+	policy.ScaleUpStabilization = up
+	policy.ScaleDownStabilization = down
+	return policy
+}
+
+func TestPlanner_StabilizationHoldsDownscale(t *testing.T) {
+	// same as BasicHscaleDown, 3 x 300 -> 2 x 265, but 3 x 300 was still wanted a minute ago
+	now := time.Now()
+	state := MakePlannerState(simplePlannerPods(), 0.5, 95, stabilizationPlannerPolicy(0, 5*time.Minute))
+	history := []autoscaler.Recommendation{{Time: now.Add(-time.Minute), Replicas: 3, CpuAllocation: 900}}
+
+	decision := autoscaler.Stabilize(state, autoscaler.PlanDeployment(state), history, now)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+}
+
+func TestPlanner_StabilizationPartialDownscale(t *testing.T) {
+	// replicas are held at 3, cpu only goes down to the highest total in the window (600)
+	now := time.Now()
+	state := MakePlannerState(simplePlannerPods(), 0.5, 95, stabilizationPlannerPolicy(0, 5*time.Minute))
+	history := []autoscaler.Recommendation{
+		{Time: now.Add(-4 * time.Minute), Replicas: 3, CpuAllocation: 600},
+		{Time: now.Add(-2 * time.Minute), Replicas: 3, CpuAllocation: 530},
+	}
+
+	decision := autoscaler.Stabilize(state, autoscaler.PlanDeployment(state), history, now)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.VScaleAction, CpuRequests: 200},
+	}, t)
+}
+
+func TestPlanner_StabilizationWindowExpires(t *testing.T) {
+	now := time.Now()
+	state := MakePlannerState(simplePlannerPods(), 0.5, 95, stabilizationPlannerPolicy(0, 5*time.Minute))
+	history := []autoscaler.Recommendation{{Time: now.Add(-6 * time.Minute), Replicas: 3, CpuAllocation: 900}}
+
+	decision := autoscaler.Stabilize(state, autoscaler.PlanDeployment(state), history, now)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 2},
+		{Type: autoscaler.VScaleAction, CpuRequests: 265},
+	}, t)
+}
+
+func TestPlanner_StabilizationHoldsScaleUp(t *testing.T) {
+	// same as HscaleUp, 3 x 300 -> 4 x 450, the window only saw 3 x 300 and 4 x 300
+	now := time.Now()
+	policy := stabilizationPlannerPolicy(time.Minute, 0)
+	policy.Maps = 500
+	state := MakePlannerState(simplePlannerPods(), 2, 150, policy)
+	history := []autoscaler.Recommendation{{Time: now.Add(-30 * time.Second), Replicas: 4, CpuAllocation: 1200}}
+
+	decision := autoscaler.Stabilize(state, autoscaler.PlanDeployment(state), history, now)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 4},
+	}, t)
+}
+
+func TestPlanner_ResizeFallbackMigrates(t *testing.T) {
+	// pod1 can't grow to 450 on node1, node2 has 700 unrequested
+	state := MakePlannerState(simplePlannerPods(), 2, 150, MakePlannerPolicy(500, 100))
+	vscale := autoscaler.ScaleAction{Type: autoscaler.VScaleAction, CpuRequests: 450}
+
+	decision := autoscaler.PlanResizeFallback(state, vscale, 3, "pod1")
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.MigratePodAction, PodName: "pod1", Node: "node2", Migration: true},
+		{Type: autoscaler.VScaleAction, CpuRequests: 450},
+	}, t)
+	for _, action := range decision.Actions {
+		if !action.Fallback {
+			t.Errorf("expected every action to be marked as a fallback, got %+v", action)
+		}
+	}
+}
+
+func TestPlanner_ResizeFallbackHscale(t *testing.T) {
+	// no node has 800 unrequested, 3 x 800 is made up with pods at 300
+	state := MakePlannerState(simplePlannerPods(), 2, 150, MakePlannerPolicy(500, 100))
+	state.Nodes["node3"] = autoscaler.NodeState{Name: "node3", Allocable: 700, Capacity: 1000, MemAllocable: MOCK_NODE_MEMORY}
+	vscale := autoscaler.ScaleAction{Type: autoscaler.VScaleAction, CpuRequests: 800}
+
+	decision := autoscaler.PlanResizeFallback(state, vscale, 3, "pod1")
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 8},
+	}, t)
+
+	// as many as the nodes can place
+	delete(state.Nodes, "node3")
+	decision = autoscaler.PlanResizeFallback(state, vscale, 3, "pod1")
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 6},
+	}, t)
+
+	// unless the replica bound leaves no room
+	state.Policy.MaxReplicas = 3
+	decision = autoscaler.PlanResizeFallback(state, vscale, 3, "pod1")
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+}
+
+// setpoint 80ms, the tests run it at 720/900 millicpus in use
+func controllerPlannerPolicy() autoscaler.DeploymentPolicy {
+	policy := MakePlannerPolicy(300, 100)
+	policy.Mode = autoscaler.ControllerScalingMode
+	policy.LatencySetpoint = autoscaler.DEFAULT_LATENCY_SETPOINT
+	policy.ControllerMaxStep = autoscaler.DEFAULT_CONTROLLER_MAX_STEP
+	return policy
+}
+
+func TestPlanner_ControllerStartsAtAllocation(t *testing.T) {
+	c := autoscaler.Controller{}
+	if target, _ := c.Update(MakePlannerState(simplePlannerPods(), 0.8, 80, controllerPlannerPolicy())); target != 900 {
+		t.Errorf("expected the controller to start at the 900 millicpus allocated, got %d", target)
+	}
+}
+
+func TestPlanner_ControllerBoundedStep(t *testing.T) {
+	c := autoscaler.Controller{}
+	state := MakePlannerState(simplePlannerPods(), 0.8, 400, controllerPlannerPolicy())
+	for range 5 {
+		target, e := c.Update(state)
+		if e != autoscaler.CONTROLLER_MAX_ERROR {
+			t.Errorf("expected the error clamped to %v, got %v", autoscaler.CONTROLLER_MAX_ERROR, e)
+		}
+		if limit := state.Allocation * 5 / 4; target > limit {
+			t.Errorf("expected at most %d millicpus from %d, got %d", limit, state.Allocation, target)
+		}
+		state.Allocation = target
+	}
+}
+
+func TestPlanner_ControllerAntiWindup(t *testing.T) {
+	// held at 1200 millicpus by the bounds while latency stays over the setpoint
+	c := autoscaler.Controller{}
+	state := MakePlannerState(simplePlannerPods(), 0.8, 200, controllerPlannerPolicy())
+	state.Policy.MaxReplicas, state.Policy.MaxCPU = 4, 300
+	for range 20 {
+		target, _ := c.Update(state)
+		state.Allocation = target
+	}
+	if state.Allocation != 1200 {
+		t.Fatalf("expected the 1200 millicpu bound, got %d", state.Allocation)
+	}
+	saturated := c.Integral
+
+	// a target that never lands does not wind up either
+	stuck := c
+	for range 20 {
+		stuck.Update(MakePlannerState(simplePlannerPods(), 0.8, 200, controllerPlannerPolicy()))
+	}
+	if stuck.Integral != saturated {
+		t.Errorf("expected the integral held at %.2f while the allocation lags, got %.2f", saturated, stuck.Integral)
+	}
+
+	// latency back under the setpoint comes down right away, not after unwinding 20 rounds
+	state.Latencies = map[string]float64{"p99": 40}
+	if target, _ := c.Update(state); target >= 1200 {
+		t.Errorf("expected the target to drop below 1200 millicpus, got %d", target)
+	}
+}
+
+func TestPlanner_ControllerSLOTargets(t *testing.T) {
+	// p99 is at half the threshold, but p95 is at its 30ms target, over the 80% setpoint
+	policy := controllerPlannerPolicy()
+	policy.LatencyTargets, _ = autoscaler.ParseLatencyTargets("p95=30,p99=100")
+	state := MakePlannerState(simplePlannerPods(), 0.8, 50, policy)
+	state.Latencies["p95"] = 30
+	c := autoscaler.Controller{}
+	c.Update(state)
+	target, e := c.Update(state)
+	if math.Abs(e-0.25) > 1e-9 || target <= state.Allocation {
+		t.Errorf("expected an error of 0.25 from the p95 target pushing cpu up, got %.3f and %d millicpus", e, target)
+	}
+
+	// p99 alone is under the setpoint
+	state.Policy.LatencyTargets = nil
+	c = autoscaler.Controller{}
+	if _, e := c.Update(state); e >= 0 {
+		t.Errorf("expected p99 alone under the setpoint, got an error of %.3f", e)
+	}
+}
+
+func TestPlanner_ControllerPlan(t *testing.T) {
+	state := MakePlannerState(simplePlannerPods(), 0.8, 200, controllerPlannerPolicy())
+	state.ControlTarget = 1125
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 4},
+		{Type: autoscaler.VScaleAction, CpuRequests: 282},
+	}, t)
+
+	// down as well as up
+	state.ControlTarget = 700
+	decision = autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.VScaleAction, CpuRequests: 234},
+	}, t)
+
+	// within the deadband
+	state.ControlTarget = 920
+	decision = autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+}
+
+func TestPlanner_SLOScore(t *testing.T) {
+	// p95 25ms and p99 90ms, p99 alone is over, so every target holding is violated
+	policy := MakePlannerPolicy(300, 100)
+	policy.LatencyTargets, _ = autoscaler.ParseLatencyTargets("p95=30,p99=80")
+	state := MakePlannerState(simplePlannerPods(), 1.5, 90, policy)
+	state.Latencies["p95"] = 25
+	if score := state.SLOScore(); score != 90.0/80 || !state.SLOViolated() {
+		t.Errorf("expected the worst target's score of %.3f violated, got %.3f", 90.0/80, score)
+	}
+
+	// p95's room makes up for p99 in the weighted mean
+	state.Policy.LatencyTargets, _ = autoscaler.ParseLatencyTargets("p95=30:0.5,p99=80:0.5")
+	expected := (25.0/30 + 90.0/80) / 2
+	if score := state.SLOScore(); math.Abs(score-expected) > 1e-9 || state.SLOViolated() {
+		t.Errorf("expected a weighted score of %.3f within the slo, got %.3f", expected, score)
+	}
+
+	// no targets is p99 under the latency threshold
+	state.Policy.LatencyTargets = nil
+	if score := state.SLOScore(); score != 0.9 || state.SLOViolated() {
+		t.Errorf("expected p99's score of 0.9, got %.3f", score)
+	}
+}
+
+func TestPlanner_SLOScoreDecides(t *testing.T) {
+	// p95 25ms and p99 90ms against p95 under 30ms and p99 under 80ms, scales out on the p99 target alone
+	policy := MakePlannerPolicy(300, 100)
+	policy.LatencyTargets, _ = autoscaler.ParseLatencyTargets("p95=30,p99=80")
+	state := MakePlannerState(simplePlannerPods(), 1.5, 90, policy)
+	state.Latencies["p95"] = 25
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 5},
+		{Type: autoscaler.VScaleAction, CpuRequests: 270},
+	}, t)
+
+	// underutilized, but not safe to downscale while the slo is violated
+	state = MakePlannerState(simplePlannerPods(), 0.5, 90, policy)
+	state.Latencies["p95"] = 25
+	decision = autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+
+	// the weighted score is within the slo, so it is
+	state.Policy.LatencyTargets, _ = autoscaler.ParseLatencyTargets("p95=30:0.5,p99=80:0.5")
+	decision = autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 2},
+		{Type: autoscaler.VScaleAction, CpuRequests: 265},
+	}, t)
+}
+
+func TestPlanner_AvailabilityScore(t *testing.T) {
+	// p99 at half the threshold, 2% 5xx against a 1% error budget
+	policy := MakePlannerPolicy(300, 100)
+	policy.AvailabilityTarget = 0.99
+	state := MakePlannerState(simplePlannerPods(), 1.5, 50, policy)
+	state.Traffic = util.Traffic{Requests: 100, Errors: 2}
+	if score := state.SLOScore(); math.Abs(score-2) > 1e-9 || !state.SLOViolated() {
+		t.Errorf("expected an availability score of 2 violated, got %.3f", score)
+	}
+
+	// within the budget, latency is the worse of the two
+	state.Traffic = util.Traffic{Requests: 100, Errors: 0.5}
+	if score := state.SLOScore(); score != 0.5 || state.SLOViolated() {
+		t.Errorf("expected latency's score of 0.5, got %.3f", score)
+	}
+
+	// too few requests to judge on
+	state.Traffic = util.Traffic{Requests: 0.5, Errors: 0.5}
+	if score := state.SLOScore(); score != 0.5 || state.SLOViolated() {
+		t.Errorf("expected low traffic ignored, got %.3f", score)
+	}
+
+	// unread traffic is not a violation, but the slo is unread
+	state.Traffic, state.TrafficErr = util.Traffic{}, errors.New("no traffic")
+	if state.SLOViolated() || !state.SLOUnread() {
+		t.Errorf("expected the slo unread and not violated")
+	}
+	state.Policy.AvailabilityTarget = 0
+	if state.SLOUnread() {
+		t.Errorf("expected traffic to not matter without an availability target")
+	}
+}
+
+func TestPlanner_AvailabilityDecides(t *testing.T) {
+	// latency is within the slo, 5xx scales out just like a latency violation does
+	policy := MakePlannerPolicy(300, 100)
+	policy.AvailabilityTarget = 0.99
+	state := MakePlannerState(simplePlannerPods(), 1.5, 50, policy)
+	state.Traffic = util.Traffic{Requests: 100, Errors: 2}
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 5},
+		{Type: autoscaler.VScaleAction, CpuRequests: 270},
+	}, t)
+
+	// underutilized, but not safe to downscale while availability is violated
+	state = MakePlannerState(simplePlannerPods(), 0.5, 50, policy)
+	state.Traffic = util.Traffic{Requests: 100, Errors: 2}
+	decision = autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+
+	// or while it could not be read
+	state.Traffic, state.TrafficErr = util.Traffic{}, errors.New("no traffic")
+	decision = autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{}, t)
+
+	// within the budget it is
+	state.Traffic, state.TrafficErr = util.Traffic{Requests: 100, Errors: 0.5}, nil
+	decision = autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 2},
+		{Type: autoscaler.VScaleAction, CpuRequests: 265},
+	}, t)
+}
@@ -0,0 +1,81 @@
+//go:build autoscalertest
+// +build autoscalertest
+
+package autoscalertest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tholiang/podoscaler/scalers/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var resizeTo330 = util.VerticalPatchContainerResources{Requests: util.VerticalPatchResourceSpec{CPU: "330m"}}
+
+// pod1 with its container's allocated and actual cpu requests, status resize left to the caller
+func resizePod(allocated string, actual string) *v1.Pod {
+	pod := MakePod("pod1", "node1", "container", 330)
+	pod.Namespace = MOCK_DEPLOYMENT_NAMESPACE
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:               "container",
+		AllocatedResources: v1.ResourceList{"cpu": resource.MustParse(allocated)},
+		Resources:          &v1.ResourceRequirements{Requests: v1.ResourceList{"cpu": resource.MustParse(actual)}},
+	}}
+	return &pod
+}
+
+func TestUnit_ResizeState(t *testing.T) {
+	deferred := resizePod("300m", "300m")
+	deferred.Status.Conditions = []v1.PodCondition{{Type: util.POD_RESIZE_PENDING, Status: v1.ConditionTrue, Reason: string(v1.PodResizeStatusDeferred)}}
+	infeasible := resizePod("300m", "300m")
+	infeasible.Status.Resize = v1.PodResizeStatusInfeasible
+
+	tests := []struct {
+		name    string
+		pod     *v1.Pod
+		applied bool
+		status  v1.PodResizeStatus
+	}{
+		{"applied", resizePod("330m", "330m"), true, ""},
+		{"allocated, not actuated", resizePod("330m", "300m"), false, ""},
+		{"not allocated", resizePod("300m", "300m"), false, ""},
+		{"deferred condition", deferred, false, v1.PodResizeStatusDeferred},
+		{"infeasible status", infeasible, false, v1.PodResizeStatusInfeasible},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied, status := util.ResizeState(tt.pod, "container", resizeTo330)
+			if applied != tt.applied || status != tt.status {
+				t.Errorf("expected (%t, %q), got (%t, %q)", tt.applied, tt.status, applied, status)
+			}
+		})
+	}
+}
+
+func TestUnit_WaitForResize(t *testing.T) {
+	infeasible := resizePod("300m", "300m")
+	infeasible.Status.Resize = v1.PodResizeStatusInfeasible
+	clientset := fake.NewSimpleClientset(infeasible)
+	err := util.WaitForResize(t.Context(), clientset, "pod1", "container", resizeTo330, MOCK_DEPLOYMENT_NAMESPACE, time.Second)
+	if !errors.Is(err, util.ErrResizeInfeasible) {
+		t.Errorf("expected infeasible, got %v", err)
+	}
+
+	// deferred is waited on until the timeout
+	deferred := resizePod("300m", "300m")
+	deferred.Status.Resize = v1.PodResizeStatusDeferred
+	clientset = fake.NewSimpleClientset(deferred)
+	err = util.WaitForResize(t.Context(), clientset, "pod1", "container", resizeTo330, MOCK_DEPLOYMENT_NAMESPACE, 50*time.Millisecond)
+	if !errors.Is(err, util.ErrResizeTimeout) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	clientset = fake.NewSimpleClientset(resizePod("330m", "330m"))
+	err = util.WaitForResize(t.Context(), clientset, "pod1", "container", resizeTo330, MOCK_DEPLOYMENT_NAMESPACE, time.Second)
+	AssertNoError(err, t)
+}
@@ -0,0 +1,140 @@
+//go:build autoscalertest
+// +build autoscalertest
+
+package autoscalertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/tholiang/podoscaler/scalers/util"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestUnit_IsRetryable(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"nil", nil, false},
+		{"throttled", apierrors.NewTooManyRequests("slow down", 1), true},
+		{"server timeout", apierrors.NewServerTimeout(pods, "list", 1), true},
+		{"unavailable", apierrors.NewServiceUnavailable("down"), true},
+		{"internal", apierrors.NewInternalError(errors.New("boom")), true},
+		{"wrapped internal", fmt.Errorf("failed to get pods: %w", apierrors.NewInternalError(errors.New("boom"))), true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"not found", apierrors.NewNotFound(pods, "pod1"), false},
+		{"forbidden", apierrors.NewForbidden(pods, "pod1", errors.New("rbac")), false},
+		{"own deadline", context.DeadlineExceeded, false},
+		{"config", errors.New("failed to load aws config"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if util.IsRetryable(tt.err) != tt.retryable {
+				t.Errorf("expected IsRetryable(%v) to be %t", tt.err, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestUnit_RetryGivesUp(t *testing.T) {
+	backoff := wait.Backoff{Duration: time.Millisecond, Factor: 2, Jitter: 0.2, Steps: 3}
+
+	attempts := 0
+	err := util.Retry(t.Context(), backoff, func(ctx context.Context) error {
+		attempts++
+		return apierrors.NewServiceUnavailable("down")
+	})
+	if !apierrors.IsServiceUnavailable(err) {
+		t.Errorf("expected the last error to come back, got %v", err)
+	}
+	AssertIntsEqual(3, attempts, t)
+
+	// fatal errors are not retried
+	attempts = 0
+	err = util.Retry(t.Context(), backoff, func(ctx context.Context) error {
+		attempts++
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "pod1")
+	})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	AssertIntsEqual(1, attempts, t)
+}
+
+// a throttled API server is retried until it answers
+func TestUnit_APIReaderRetries(t *testing.T) {
+	clientset := fake.NewSimpleClientset(cacheTestObjects()...)
+	calls := 0
+	clientset.PrependReactor("get", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		if calls <= 2 {
+			return true, nil, apierrors.NewTooManyRequests("slow down", 0)
+		}
+		return false, nil, nil
+	})
+
+	node, err := util.NewAPIReader(clientset).GetNode(t.Context(), "node1")
+	AssertNoError(err, t)
+	AssertIntsEqual(3, calls, t)
+	if node.Status.Allocatable.Cpu().MilliValue() != 1900 {
+		t.Errorf("expected node1, got %+v", node.Status.Allocatable)
+	}
+}
+
+// a list that keeps failing while waiting on replicas is waited out, not fatal
+func TestUnit_HScalePollRetries(t *testing.T) {
+	clientset := fake.NewSimpleClientset(cacheTestObjects()...)
+	clientset.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	lists := 0
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		lists++
+		if lists <= util.DEFAULT_BACKOFF.Steps {
+			return true, nil, apierrors.NewServiceUnavailable("down")
+		}
+		return false, nil, nil
+	})
+
+	err := util.ChangeReplicaCount(t.Context(), MOCK_DEPLOYMENT_NAMESPACE, MOCK_DEPLOYMENT_NAME, 2, clientset)
+	AssertNoError(err, t)
+	if lists <= util.DEFAULT_BACKOFF.Steps {
+		t.Errorf("expected the poll to list again after a failed read, listed %d times", lists)
+	}
+}
+
+// a delete that failed but went through is done once a retry finds the pod gone
+func TestUnit_DeletePodRetried(t *testing.T) {
+	clientset := fake.NewSimpleClientset(cacheTestObjects()...)
+	deletes := 0
+	clientset.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		deletes++
+		if action.(k8stesting.DeleteAction).GetName() == "pod1" && deletes == 1 {
+			return true, nil, apierrors.NewServerTimeout(schema.GroupResource{Resource: "pods"}, "delete", 1)
+		}
+		return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "pod1")
+	})
+
+	err := util.DeletePod(t.Context(), clientset, "pod1", MOCK_DEPLOYMENT_NAMESPACE)
+	AssertNoError(err, t)
+	AssertIntsEqual(2, deletes, t)
+
+	// a pod that was never there is still not found
+	err = util.DeletePod(t.Context(), clientset, "missing", MOCK_DEPLOYMENT_NAMESPACE)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected not found on the first attempt, got %v", err)
+	}
+}
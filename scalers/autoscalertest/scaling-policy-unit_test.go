@@ -0,0 +1,110 @@
+//go:build autoscalertest
+// +build autoscalertest
+
+package autoscalertest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tholiang/podoscaler/scalers/autoscaler"
+)
+
+func TestPlanner_HPAFormula(t *testing.T) {
+	hpa := autoscaler.HPAPolicy{}
+	policy := MakePlannerPolicy(300, 100)
+	policy.HPATargetUtilization = 0.5
+
+	// 80% against 50%: ceil(3 x 1.6)
+	state := MakePlannerState(simplePlannerPods(), 0.8, 500, policy)
+	AssertScaleActions(hpa.Plan(state, time.Now()).Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 5},
+	}, t)
+
+	// within the 10% tolerance, latency plays no part
+	state.Policy.HPATargetUtilization = 0.75
+	AssertScaleActions(hpa.Plan(state, time.Now()).Actions, []autoscaler.ScaleAction{}, t)
+
+	// 50% against 90%: ceil(3 x 0.56)
+	state = MakePlannerState(simplePlannerPods(), 0.5, 0, MakePlannerPolicy(300, 100))
+	AssertScaleActions(hpa.Plan(state, time.Now()).Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 2},
+	}, t)
+}
+
+func TestPlanner_VPARecommendation(t *testing.T) {
+	vpa := autoscaler.NewVPAPolicy()
+	// every pod uses 150 of its 300 millicpus
+	state := MakePlannerState(simplePlannerPods(), 0.5, 0, MakePlannerPolicy(300, 100))
+	now := time.Now()
+
+	// three pods a round, ten samples needed
+	for i := range 3 {
+		AssertScaleActions(vpa.Plan(state, now.Add(time.Duration(i)*time.Minute)).Actions, []autoscaler.ScaleAction{}, t)
+	}
+
+	// 150 plus the 15% margin
+	AssertScaleActions(vpa.Plan(state, now.Add(3*time.Minute)).Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.VScaleAction, CpuRequests: 173},
+	}, t)
+
+	// other deployments have their own history
+	other := state
+	other.Name = "other"
+	AssertScaleActions(vpa.Plan(other, now.Add(3*time.Minute)).Actions, []autoscaler.ScaleAction{}, t)
+}
+
+func TestUnit_HPAPolicy(t *testing.T) {
+	// the vecter rules would scale down here
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 0.9
+	mm.RelDeploymentUtil = 0.8
+	mm.DeploymentAnnotations = map[string]string{
+		autoscaler.SCALING_POLICY_ANNOTATION:         autoscaler.HPA_SCALING_POLICY,
+		autoscaler.HPA_TARGET_UTILIZATION_ANNOTATION: "0.5",
+	}
+
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 300, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 5})
+	AssertNoActions(mm, t)
+	if plan := a.LastPlan(); len(plan.Deployments) != 1 || plan.Deployments[0].ScalingPolicy != autoscaler.HPA_SCALING_POLICY {
+		t.Errorf("expected the hpa policy in the round plan, got %+v", plan.Deployments)
+	}
+}
+
+func TestUnit_UnknownScalingPolicy(t *testing.T) {
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 0.9
+	mm.RelDeploymentUtil = 0.8
+	mm.DeploymentAnnotations = map[string]string{autoscaler.SCALING_POLICY_ANNOTATION: "kpa"}
+
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 300, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	// only the deployment is skipped
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+	AssertNoActions(mm, t)
+	if plan := a.LastPlan(); len(plan.Deployments) != 1 || !strings.Contains(plan.Deployments[0].Error, autoscaler.SCALING_POLICY_ANNOTATION) {
+		t.Errorf("expected the deployment skipped with a policy error, got %+v", plan.Deployments)
+	}
+
+	for _, name := range []string{"", "kpa", "VPA"} {
+		if _, err := autoscaler.ParseScalingPolicy(name); err == nil {
+			t.Errorf("expected %q rejected", name)
+		}
+	}
+	name, err := autoscaler.ParseScalingPolicy(" hpa ")
+	AssertNoError(err, t)
+	if name != autoscaler.HPA_SCALING_POLICY {
+		t.Errorf("expected hpa, got %q", name)
+	}
+}
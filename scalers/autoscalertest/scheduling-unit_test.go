@@ -0,0 +1,88 @@
+//go:build autoscalertest
+// +build autoscalertest
+
+package autoscalertest
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/tholiang/podoscaler/scalers/autoscaler"
+	v1 "k8s.io/api/core/v1"
+)
+
+func assertPlacement(placement autoscaler.Placement, pods map[string]int, unplaced int, t *testing.T) {
+	if !maps.Equal(placement.Pods, pods) || placement.Unplaced != unplaced {
+		t.Errorf("expected %v with %d unplaced, got %v with %d unplaced", pods, unplaced, placement.Pods, placement.Unplaced)
+	}
+}
+
+func TestPlanner_Simulate(t *testing.T) {
+	// node1 and node2 both have 1000 millicpus once the deployment's own pods are taken off
+	state := MakePlannerState(simplePlannerPods(), 1, 0, MakePlannerPolicy(400, 100))
+
+	// running pods stay where they are, the new one goes where the most is left
+	assertPlacement(state.Simulate(4, 300, 0), map[string]int{"node1": 2, "node2": 2}, 0, t)
+	assertPlacement(state.Simulate(7, 300, 0), map[string]int{"node1": 3, "node2": 3}, 1, t)
+	// a pod that can't grow in place is the one dropped
+	assertPlacement(state.Simulate(2, 600, 0), map[string]int{"node1": 1, "node2": 1}, 0, t)
+
+	// memory counts too
+	node2 := state.Nodes["node2"]
+	node2.MemAllocable = 0
+	state.Nodes["node2"] = node2
+	assertPlacement(state.Simulate(4, 300, 1), map[string]int{"node1": 3}, 1, t)
+}
+
+func TestPlanner_SimulateConstraints(t *testing.T) {
+	state := MakePlannerState(simplePlannerPods(), 1, 0, MakePlannerPolicy(400, 100))
+	node2 := state.Nodes["node2"]
+	node2.Taints = []v1.Taint{{Key: "dedicated", Value: "batch", Effect: v1.TaintEffectNoSchedule}}
+	state.Nodes["node2"] = node2
+
+	// pod3 is already running there, new pods are kept off
+	assertPlacement(state.Simulate(5, 300, 0), map[string]int{"node1": 3, "node2": 1}, 1, t)
+
+	state.Tolerations = []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "batch", Effect: v1.TaintEffectNoSchedule}}
+	assertPlacement(state.Simulate(5, 300, 0), map[string]int{"node1": 3, "node2": 2}, 0, t)
+
+	// node2 has no pool label
+	node1 := state.Nodes["node1"]
+	node1.Labels = map[string]string{"pool": "web"}
+	state.Nodes["node1"] = node1
+	state.NodeSelector = map[string]string{"pool": "web"}
+	assertPlacement(state.Simulate(5, 300, 0), map[string]int{"node1": 3, "node2": 1}, 1, t)
+
+	// cordoned
+	node1.Unschedulable = true
+	state.Nodes["node1"] = node1
+	assertPlacement(state.Simulate(4, 300, 0), map[string]int{"node1": 2, "node2": 1}, 1, t)
+}
+
+func TestPlanner_PlacementPicksFeasibleMix(t *testing.T) {
+	// 6 x 300 wanted, but each node only has memory for two pods of 1Gi - 4 x 450 is the closest that fits
+	state := MakePlannerState(memoryPlannerPods(1024*MiB, 512*MiB), 2, 150, MakePlannerPolicy(300, 100))
+	node1, node2 := state.Nodes["node1"], state.Nodes["node2"]
+	node1.MemAllocable, node2.MemAllocable = 0, 1024*MiB
+	state.Nodes["node1"], state.Nodes["node2"] = node1, node2
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 4},
+		{Type: autoscaler.VScaleAction, CpuRequests: 450},
+	}, t)
+}
+
+func TestPlanner_PlacementNoFeasibleMix(t *testing.T) {
+	// 1800 millicpus wanted, 1400 unrequested in the cluster - the plan is kept, no smaller one would do
+	state := MakePlannerState(simplePlannerPods(), 2, 150, MakePlannerPolicy(300, 100))
+	node2 := state.Nodes["node2"]
+	node2.Allocable = 100
+	state.Nodes["node2"] = node2
+
+	decision := autoscaler.PlanDeployment(state)
+	AssertScaleActions(decision.Actions, []autoscaler.ScaleAction{
+		{Type: autoscaler.HScaleAction, Replicas: 6},
+		{Type: autoscaler.VScaleAction, CpuRequests: 300},
+	}, t)
+}
@@ -0,0 +1,170 @@
+//go:build autoscalertest
+// +build autoscalertest
+
+package autoscalertest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/tholiang/podoscaler/scalers/autoscaler"
+	"github.com/tholiang/podoscaler/scalers/util"
+	kube_client "k8s.io/client-go/kubernetes"
+)
+
+func TestUnit_ParseLatencyTargets(t *testing.T) {
+	targets, err := autoscaler.ParseLatencyTargets("p95=30, p99.9=80")
+	AssertNoError(err, t)
+	expected := []autoscaler.LatencyTarget{{Percentile: "p95", Threshold: 30}, {Percentile: "p99.9", Threshold: 80}}
+	if !slices.Equal(targets, expected) {
+		t.Errorf("expected %v, got %v", expected, targets)
+	}
+
+	targets, err = autoscaler.ParseLatencyTargets("p95=30:0.25,p99=80:0.75")
+	AssertNoError(err, t)
+	if len(targets) != 2 || targets[0].Weight != 0.25 || targets[1].Weight != 0.75 {
+		t.Errorf("expected weights 0.25 and 0.75, got %v", targets)
+	}
+
+	for _, bad := range []string{"", "p95", "p95=0", "95=30", "p101=30", "p95=30,p95=40", "p95=30:2", "p95=30:0.5,p99=80"} {
+		if _, err := autoscaler.ParseLatencyTargets(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestUnit_LatencyTargets(t *testing.T) {
+	// p99 is well within the default threshold, p95 is over its own
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 0.5
+	mm.Percentiles = map[string]float64{"p95": 0.04}
+	mm.RelDeploymentUtil = 2
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+	mm.DeploymentAnnotations = map[string]string{autoscaler.LATENCY_TARGETS_ANNOTATION: "p95=30,p99=100"}
+
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 500, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 4})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "450m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "450m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod3", ContainerName: "container", CpuRequests: "450m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod4", ContainerName: "container", CpuRequests: "450m"})
+	AssertNoActions(mm, t)
+	plan := a.LastPlan()
+	if len(plan.Deployments) != 1 || !plan.Deployments[0].SLOViolated || plan.Deployments[0].SLOScore <= 1 {
+		t.Errorf("expected the p95 target violated in the round plan, got %+v", plan.Deployments)
+	}
+
+	// a promql source only reports p99, other percentiles are rejected with the policy
+	deployment, err := mm.GetDeployment(t.Context(), a.Clientset, mm.DeploymentName, mm.DeploymentNamespace)
+	AssertNoError(err, t)
+	deployment.Annotations = map[string]string{
+		autoscaler.LATENCY_SOURCE_ANNOTATION:  "promql:histogram_quantile(0.95, rate(testapp_latency_bucket[1m]))",
+		autoscaler.LATENCY_TARGETS_ANNOTATION: "p95=30",
+	}
+	if _, err := a.GetDeploymentPolicy(deployment); err == nil {
+		t.Errorf("expected a p95 target on a promql source rejected")
+	}
+	deployment.Annotations[autoscaler.LATENCY_TARGETS_ANNOTATION] = "p99=80"
+	_, err = a.GetDeploymentPolicy(deployment)
+	AssertNoError(err, t)
+
+	// a percentile the latency source does not report leaves latency unread, nothing is scaled down on it
+	mm.DeploymentAnnotations[autoscaler.LATENCY_TARGETS_ANNOTATION] = "p90=30"
+	mm.RelDeploymentUtil = 0.5
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+	AssertNoActions(mm, t)
+}
+
+func TestUnit_AvailabilitySLO(t *testing.T) {
+	// same as BasicHscaleUp, with latency well within the threshold and 1% 5xx against a 0.1% error budget
+	mm := CreateSimpleMockMetrics()
+	mm.Latency = MOCK_LATENCY_THRESHOLD * 0.5
+	mm.RelDeploymentUtil = 2
+	mm.RelNodeUsages = map[string]float64{
+		"node1": 0.9,
+		"node2": 0.5,
+	}
+	mm.RequestRate, mm.ErrorRate = 100, 1
+	mm.DeploymentAnnotations = map[string]string{autoscaler.AVAILABILITY_TARGET_ANNOTATION: "0.999"}
+
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 500, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+
+	AssertAction(mm, t, Action{Type: ChangeReplicaCountAction, Namespace: MOCK_DEPLOYMENT_NAMESPACE, DeploymentName: MOCK_DEPLOYMENT_NAME, ReplicaCt: 4})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod1", ContainerName: "container", CpuRequests: "450m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod2", ContainerName: "container", CpuRequests: "450m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod3", ContainerName: "container", CpuRequests: "450m"})
+	AssertAction(mm, t, Action{Type: VscaleAction, PodName: "pod4", ContainerName: "container", CpuRequests: "450m"})
+	AssertNoActions(mm, t)
+	plan := a.LastPlan()
+	if len(plan.Deployments) != 1 || !plan.Deployments[0].SLOViolated || plan.Deployments[0].RequestRate != 100 || plan.Deployments[0].ErrorRate != 1 {
+		t.Errorf("expected the availability violation in the round plan, got %+v", plan.Deployments)
+	}
+
+	// unreadable traffic leaves the slo unread, nothing is scaled down on it
+	mm.TrafficErr = errors.New("no datapoints")
+	mm.RelDeploymentUtil = 0.5
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+	AssertNoActions(mm, t)
+
+	// without a target traffic is never read
+	delete(mm.DeploymentAnnotations, autoscaler.AVAILABILITY_TARGET_ANNOTATION)
+	reads := 0
+	mm.MockGetTrafficMetrics = func(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, source autoscaler.LatencySource, requestsQuery string, errorsQuery string) (util.Traffic, error) {
+		reads++
+		return MockTrafficMetrics(m, ctx, clientset, source, requestsQuery, errorsQuery)
+	}
+	err = a.RunRound(t.Context())
+	AssertNoError(err, t)
+	AssertIntsEqual(0, reads, t)
+}
+
+func TestUnit_AvailabilityPolicy(t *testing.T) {
+	mm := CreateSimpleMockMetrics()
+	a := UnitMakeAutoscaler(0.2, 0.85, MOCK_DEPLOYMENT_NAMESPACE, 300, 100, mm)
+	err := a.Init()
+	AssertNoError(err, t)
+	deployment, err := mm.GetDeployment(t.Context(), a.Clientset, mm.DeploymentName, mm.DeploymentNamespace)
+	AssertNoError(err, t)
+
+	deployment.Annotations = map[string]string{autoscaler.AVAILABILITY_TARGET_ANNOTATION: "0.995"}
+	policy, err := a.GetDeploymentPolicy(deployment)
+	AssertNoError(err, t)
+	if policy.AvailabilityTarget != 0.995 {
+		t.Errorf("expected an availability target of 0.995, got %v", policy.AvailabilityTarget)
+	}
+
+	// a promql latency source has no load balancer to read traffic from
+	deployment.Annotations[autoscaler.LATENCY_SOURCE_ANNOTATION] = "promql:histogram_quantile(0.99, rate(testapp_latency_bucket[1m]))"
+	if _, err := a.GetDeploymentPolicy(deployment); err == nil {
+		t.Errorf("expected an availability target without traffic queries rejected")
+	}
+	deployment.Annotations[autoscaler.REQUESTS_QUERY_ANNOTATION] = "sum(rate(testapp_requests_total[1m]))"
+	deployment.Annotations[autoscaler.ERRORS_QUERY_ANNOTATION] = `sum(rate(testapp_requests_total{code=~"5.."}[1m]))`
+	_, err = a.GetDeploymentPolicy(deployment)
+	AssertNoError(err, t)
+
+	for _, bad := range []string{"1", "99.9", "-0.1", "most"} {
+		deployment.Annotations = map[string]string{autoscaler.AVAILABILITY_TARGET_ANNOTATION: bad}
+		if _, err := a.GetDeploymentPolicy(deployment); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
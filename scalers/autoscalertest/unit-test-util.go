@@ -4,15 +4,24 @@
 package autoscalertest
 
 import (
+	"context"
 	"fmt"
+	"maps"
 	"slices"
 	"sort"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/tholiang/podoscaler/scalers/api/v1alpha1"
+	"github.com/tholiang/podoscaler/scalers/autoscaler"
+	util "github.com/tholiang/podoscaler/scalers/util"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/dynamic"
 	kube_client "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	metrics_client "k8s.io/metrics/pkg/client/clientset/versioned"
@@ -55,6 +64,12 @@ func AssertAction(mm *MockMetrics, t *testing.T, a Action) {
 		if a.CpuRequests != first_action.CpuRequests {
 			t.Errorf("incorrect cpu request for vscale, expected %s, got %s", a.CpuRequests, first_action.CpuRequests)
 		}
+		if a.MemRequests != first_action.MemRequests {
+			t.Errorf("incorrect memory request for vscale, expected %s, got %s", a.MemRequests, first_action.MemRequests)
+		}
+		if a.CpuLimits != first_action.CpuLimits {
+			t.Errorf("incorrect cpu limit for vscale, expected %s, got %s", a.CpuLimits, first_action.CpuLimits)
+		}
 	} else if a.Type == ChangeReplicaCountAction {
 		if a.Namespace != first_action.Namespace {
 			t.Errorf("incorrect namespace for change replica, expected %s, got %s", a.Namespace, first_action.Namespace)
@@ -65,6 +80,17 @@ func AssertAction(mm *MockMetrics, t *testing.T, a Action) {
 		if a.ReplicaCt != first_action.ReplicaCt {
 			t.Errorf("incorrect replica count for change replica, expected %d, got %d", a.ReplicaCt, first_action.ReplicaCt)
 		}
+	} else if a.Type == DisableHPAAction {
+		if a.HPAName != first_action.HPAName {
+			t.Errorf("incorrect hpa for disable hpa, expected %s, got %s", a.HPAName, first_action.HPAName)
+		}
+	} else if a.Type == MigratePodAction {
+		if a.PodName != first_action.PodName {
+			t.Errorf("incorrect pod name for migrate, expected %s, got %s", a.PodName, first_action.PodName)
+		}
+		if a.NodeName != first_action.NodeName {
+			t.Errorf("incorrect node for migrate, expected %s, got %s", a.NodeName, first_action.NodeName)
+		}
 	} else {
 		if a.DeploymentName != first_action.DeploymentName {
 			t.Errorf("incorrect deployment name for delete, expected %s, got %s", a.DeploymentName, first_action.DeploymentName)
@@ -120,6 +146,15 @@ func AssertPodListsEqual(l1 MockPodList, l2 MockPodList, t *testing.T) {
 		if l1[k].CpuRequests != l2[k].CpuRequests {
 			t.Errorf("pod mismatch at %s, expected %d cpu requests, got %d", k, l2[k].CpuRequests, l1[k].CpuRequests)
 		}
+		if l1[k].MemRequests != l2[k].MemRequests {
+			t.Errorf("pod mismatch at %s, expected %d memory requests, got %d", k, l2[k].MemRequests, l1[k].MemRequests)
+		}
+		if l1[k].CpuLimits != l2[k].CpuLimits {
+			t.Errorf("pod mismatch at %s, expected %d cpu limits, got %d", k, l2[k].CpuLimits, l1[k].CpuLimits)
+		}
+		if !slices.Equal(l1[k].Extra, l2[k].Extra) {
+			t.Errorf("pod mismatch at %s, expected extra containers %+v, got %+v", k, l2[k].Extra, l1[k].Extra)
+		}
 	}
 }
 
@@ -128,6 +163,7 @@ const MOCK_DEPLOYMENT_NAME = "testapp"
 const MOCK_DEPLOYMENT_NAMESPACE = "default"
 const MOCK_MAPS = 500              // in millicpus
 const MOCK_LATENCY_THRESHOLD = 0.1 // in seconds
+const MOCK_NODE_MEMORY = 8 << 30   // unrequested memory on a node unless set, in bytes
 
 /* mock util */
 func MakePod(podName string, nodeName string, containerName string, cpuRequests int64) v1.Pod {
@@ -159,15 +195,39 @@ func MakeDeployment(deploymentName string, namespace string, replicas int32) app
 func MockPodListToPodList(mock MockPodList) []v1.Pod {
 	podlist := []v1.Pod{}
 	for _, v := range mock {
-		podlist = append(podlist, MakePod(v.PodName, v.NodeName, v.ContainerName, v.CpuRequests))
+		pod := MakePod(v.PodName, v.NodeName, v.ContainerName, v.CpuRequests)
+		if v.MemRequests > 0 {
+			pod.Spec.Containers[0].Resources.Requests["memory"] = *resource.NewQuantity(v.MemRequests, resource.BinarySI)
+		}
+		if v.CpuLimits > 0 {
+			pod.Spec.Containers[0].Resources.Limits = v1.ResourceList{
+				"cpu": *resource.NewMilliQuantity(v.CpuLimits, resource.DecimalSI),
+			}
+		}
+		extra := []v1.Container{}
+		for _, c := range v.Extra {
+			container := MakePod(v.PodName, v.NodeName, c.Name, c.CpuRequests).Spec.Containers[0]
+			if c.MemRequests > 0 {
+				container.Resources.Requests["memory"] = *resource.NewQuantity(c.MemRequests, resource.BinarySI)
+			}
+			extra = append(extra, container)
+		}
+		pod.Spec.Containers = append(extra, pod.Spec.Containers...)
+		podlist = append(podlist, pod)
 	}
 	return podlist
 }
 
-func GetDeploymentAlloc(pods MockPodList) int64 {
+// cpu requests of the app containers
+func GetDeploymentAlloc(pods MockPodList, sidecars []string) int64 {
 	var alloc int64 = 0
 	for _, v := range pods {
 		alloc += v.CpuRequests
+		for _, c := range v.Extra {
+			if !util.IsSidecar(c.Name, sidecars) {
+				alloc += c.CpuRequests
+			}
+		}
 	}
 
 	return alloc
@@ -186,28 +246,75 @@ func MockMetricsClientset(m *MockMetrics, config *rest.Config) (*metrics_client.
 	return new(metrics_client.Clientset), nil
 }
 
-func MockNodeList(m *MockMetrics, clientset kube_client.Interface) (*v1.NodeList, error) {
-	return new(v1.NodeList), nil // doesn't matter - just for logs
+// every node with a capacity, the planner looks for migration targets among them
+func MockNodeList(m *MockMetrics, ctx context.Context, clientset kube_client.Interface) (*v1.NodeList, error) {
+	nodelist := new(v1.NodeList)
+	for _, name := range slices.Sorted(maps.Keys(m.NodeCapacities)) {
+		node := v1.Node{}
+		node.Name = name
+		nodelist.Items = append(nodelist.Items, node)
+	}
+	return nodelist, nil
 }
 
-func MockControlledDeployments(m *MockMetrics, clientset kube_client.Interface) (*appsv1.DeploymentList, error) {
-	deploymentList := new(appsv1.DeploymentList)
-	deploymentList.Items = []appsv1.Deployment{
-		MakeDeployment(m.DeploymentName, m.DeploymentNamespace, 1),
+func MockDynamicClient(m *MockMetrics, config *rest.Config) (dynamic.Interface, error) {
+	return nil, nil // policies come from MockPodoscalerPolicies
+}
+
+// one policy targeting the mock deployment
+func MockPodoscalerPolicies(m *MockMetrics, ctx context.Context, dynamicClient dynamic.Interface) (*v1alpha1.PodoscalerPolicyList, error) {
+	policy := v1alpha1.PodoscalerPolicy{Spec: *m.PolicySpec.DeepCopy(), Status: *m.PolicyStatus.DeepCopy()}
+	policy.Name = m.DeploymentName
+	policy.Namespace = m.DeploymentNamespace
+	if policy.Spec.TargetRef.Name == "" {
+		policy.Spec.TargetRef = v1alpha1.TargetReference{APIVersion: "apps/v1", Kind: "Deployment", Name: m.DeploymentName}
 	}
-	return deploymentList, nil
+	return &v1alpha1.PodoscalerPolicyList{Items: []v1alpha1.PodoscalerPolicy{policy}}, nil
 }
 
-func MockReadyPodListForDeployment(m *MockMetrics, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
+func MockUpdatePodoscalerPolicyStatus(m *MockMetrics, ctx context.Context, dynamicClient dynamic.Interface, policy *v1alpha1.PodoscalerPolicy) error {
+	m.PolicyStatus = *policy.Status.DeepCopy()
+	return nil
+}
+
+func MockDeployment(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*appsv1.Deployment, error) {
+	if deploymentName != m.DeploymentName || namespace != m.DeploymentNamespace {
+		return nil, apierrors.NewNotFound(appsv1.Resource("deployments"), deploymentName)
+	}
+	deployment := MakeDeployment(m.DeploymentName, m.DeploymentNamespace, 1)
+	deployment.Annotations = m.DeploymentAnnotations
+	return &deployment, nil
+}
+
+func MockReadyPodListForDeployment(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
 	return MockPodListToPodList(m.Pods), nil
 }
 
-func MockDeploymentUtilAndAlloc(m *MockMetrics, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod) (int64, int64, error) {
-	alloc := GetDeploymentAlloc(m.Pods)
+func MockUnschedulablePodListForDeployment(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
+	return []v1.Pod{}, nil
+}
+
+func MockDeploymentUtilAndAlloc(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod, sidecars []string) (int64, int64, error) {
+	alloc := GetDeploymentAlloc(m.Pods, sidecars)
 	return int64(m.RelDeploymentUtil * float64(alloc)), alloc, nil
 }
 
-func MockNodeUsage(m *MockMetrics, metricsClient *metrics_client.Clientset, nodeName string) (int64, error) {
+// every container runs at RelDeploymentUtil of its cpu requests
+func MockPodContainerUsage(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string) (map[string]map[string]util.ContainerUsage, error) {
+	usage := map[string]map[string]util.ContainerUsage{}
+	for k, v := range m.Pods {
+		containers := map[string]util.ContainerUsage{
+			v.ContainerName: {CPU: int64(m.RelDeploymentUtil * float64(v.CpuRequests)), Memory: v.MemUsage},
+		}
+		for _, c := range v.Extra {
+			containers[c.Name] = util.ContainerUsage{CPU: int64(m.RelDeploymentUtil * float64(c.CpuRequests)), Memory: c.MemUsage}
+		}
+		usage[k] = containers
+	}
+	return usage, nil
+}
+
+func MockNodeUsage(m *MockMetrics, ctx context.Context, metricsClient *metrics_client.Clientset, nodeName string) (int64, error) {
 	cap, ok := m.NodeCapacities[nodeName]
 	if !ok {
 		return 0, fmt.Errorf("couldn't find allocable for node %s", nodeName)
@@ -220,7 +327,15 @@ func MockNodeUsage(m *MockMetrics, metricsClient *metrics_client.Clientset, node
 	return int64(usage * float64(cap)), nil
 }
 
-func MockNodeAllocableAndCapacity(m *MockMetrics, clientset kube_client.Interface, nodeName string) (int64, int64, error) {
+func MockNodeScheduling(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, nodeName string) (util.NodeScheduling, error) {
+	memAllocable, ok := m.NodeMemAllocables[nodeName]
+	if !ok {
+		memAllocable = MOCK_NODE_MEMORY
+	}
+	return util.NodeScheduling{MemAllocable: memAllocable, Labels: m.NodeLabels[nodeName], Taints: m.NodeTaints[nodeName]}, nil
+}
+
+func MockNodeAllocableAndCapacity(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, nodeName string) (int64, int64, error) {
 	alloc, ok := m.NodeAllocables[nodeName]
 	if !ok {
 		return 0, 0, fmt.Errorf("couldn't find allocable for node %s", nodeName)
@@ -233,36 +348,99 @@ func MockNodeAllocableAndCapacity(m *MockMetrics, clientset kube_client.Interfac
 	return alloc, cap, nil
 }
 
-func MockLatencyMetrics(m *MockMetrics, clientset kube_client.Interface) (map[string]float64, error) {
+func MockLatencyMetrics(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, source autoscaler.LatencySource) (map[string]float64, error) {
+	latency, ok := m.SourceLatencies[source.String()]
+	if !ok {
+		latency = m.Latency
+	}
 	metrics := map[string]float64{
-		"p99": m.Latency,
+		"p99": latency,
 	}
+	maps.Copy(metrics, m.Percentiles)
 	return metrics, nil
 }
 
-func MockVScale(m *MockMetrics, clientset kube_client.Interface, podname string, containername string, cpurequests string, namespace string) error {
+func MockTrafficMetrics(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, source autoscaler.LatencySource, requestsQuery string, errorsQuery string) (util.Traffic, error) {
+	if m.TrafficErr != nil {
+		return util.Traffic{}, m.TrafficErr
+	}
+	return util.Traffic{Requests: m.RequestRate, Errors: m.ErrorRate}, nil
+}
+
+func MockVScale(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string) error {
+	requests := resources.Requests
 	data, ok := m.Pods[podname]
 	if !ok {
 		return fmt.Errorf("failed to get pod with name: %s", podname)
 	}
-	if data.ContainerName != containername {
+
+	container := ContainerData{Name: data.ContainerName, CpuRequests: data.CpuRequests, MemRequests: data.MemRequests, MemUsage: data.MemUsage}
+	extraidx := slices.IndexFunc(data.Extra, func(c ContainerData) bool { return c.Name == containername })
+	if extraidx >= 0 {
+		container = data.Extra[extraidx]
+	} else if data.ContainerName != containername {
 		return fmt.Errorf("found incorrect container name for pod %s, expected %s, got %s", podname, data.ContainerName, containername)
 	}
 
-	var err error
-	data.CpuRequests, err = strconv.ParseInt(cpurequests[:len(cpurequests)-1], 10, 64) // assume format it "[num]m" for millis
-	if err != nil {
-		return fmt.Errorf("failed to parse cpurequests string: %s", err.Error())
+	if requests.CPU != "" {
+		var err error
+		container.CpuRequests, err = strconv.ParseInt(requests.CPU[:len(requests.CPU)-1], 10, 64) // assume format it "[num]m" for millis
+		if err != nil {
+			return fmt.Errorf("failed to parse cpurequests string: %s", err.Error())
+		}
+	}
+	if requests.Memory != "" {
+		q, err := resource.ParseQuantity(requests.Memory)
+		if err != nil {
+			return fmt.Errorf("failed to parse memory requests string: %s", err.Error())
+		}
+		container.MemRequests = q.Value()
 	}
 
+	if extraidx >= 0 {
+		data.Extra = slices.Clone(data.Extra)
+		data.Extra[extraidx] = container
+	} else {
+		data.CpuRequests = container.CpuRequests
+		data.MemRequests = container.MemRequests
+	}
+
+	// limits are only tracked on the main container
+	if resources.Limits.CPU == util.REMOVE_RESOURCE {
+		return fmt.Errorf("can't remove the cpu limit of running pod %s", podname)
+	}
+	if resources.Limits.CPU != "" && extraidx < 0 {
+		var err error
+		data.CpuLimits, err = strconv.ParseInt(resources.Limits.CPU[:len(resources.Limits.CPU)-1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse cpulimits string: %s", err.Error())
+		}
+	}
+	if data.CpuLimits > 0 && data.CpuLimits < data.CpuRequests {
+		return fmt.Errorf("pod %s cpu requests %dm above limit %dm", podname, data.CpuRequests, data.CpuLimits)
+	}
 	m.Pods[podname] = data
 
-	m.Actions = append(m.Actions, Action{Type: VscaleAction, PodName: podname, ContainerName: containername, CpuRequests: cpurequests, Namespace: namespace})
+	m.Actions = append(m.Actions, Action{Type: VscaleAction, PodName: podname, ContainerName: containername, CpuRequests: requests.CPU, MemRequests: requests.Memory, CpuLimits: resources.Limits.CPU, Namespace: namespace})
+
+	return nil
+}
+
+// MockVScale applies resizes right away
+func MockWaitForResize(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources util.VerticalPatchContainerResources, namespace string, timeout time.Duration) error {
+	return nil
+}
 
+// template only - running pods are checked through MockVScale
+func MockPatchDeploymentReqs(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName string, containername string, resources util.VerticalPatchContainerResources, namespace string) error {
+	if m.Templates == nil {
+		m.Templates = map[string]util.VerticalPatchContainerResources{}
+	}
+	m.Templates[containername] = resources
 	return nil
 }
 
-func MockChangeReplicaCount(m *MockMetrics, namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error {
+func MockChangeReplicaCount(m *MockMetrics, ctx context.Context, namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error {
 	podnames := GetPodListKeys(m.Pods)
 	numpods := len(m.Pods)
 	if numpods < replicaCt {
@@ -297,16 +475,53 @@ func MockChangeReplicaCount(m *MockMetrics, namespace string, deploymentName str
 	return nil
 }
 
-func MockDeletePod(m *MockMetrics, clientset kube_client.Interface, podname string, namespace string) error {
+// the pod is replaced by a new one (next free pod number) on nodename
+func MockMigratePod(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, podname string, nodename string, namespace string, timeout time.Duration) (string, error) {
 	poddata, ok := m.Pods[podname]
 	if !ok {
-		return fmt.Errorf("failed to delete pod, no pod found with name: %s", podname)
+		return "", fmt.Errorf("failed to migrate pod, no pod found with name: %s", podname)
 	}
 
+	largestpid := 0
+	for _, n := range GetPodListKeys(m.Pods) {
+		cand, _ := strconv.Atoi(n[3:])
+		largestpid = max(largestpid, cand)
+	}
+	replacement := fmt.Sprintf("pod%d", largestpid+1)
+
 	delete(m.Pods, podname)
 	m.NodeAllocables[poddata.NodeName] += poddata.CpuRequests
+	poddata.PodName, poddata.NodeName = replacement, nodename
+	m.Pods[replacement] = poddata
+	m.NodeAllocables[nodename] -= poddata.CpuRequests
+
+	m.Actions = append(m.Actions, Action{Type: MigratePodAction, PodName: podname, NodeName: nodename, Namespace: namespace})
+	return replacement, nil
+}
+
+func MockHPAForDeployment(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	if m.HPA == nil || m.HPA.Namespace != namespace || m.HPA.Spec.ScaleTargetRef.Name != deploymentName {
+		return nil, nil
+	}
+	return m.HPA.DeepCopy(), nil
+}
+
+func MockDisableHPA(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, hpa *autoscalingv2.HorizontalPodAutoscaler) error {
+	if util.HPADisabled(m.HPA) {
+		return nil
+	}
+	disabled := autoscalingv2.DisabledPolicySelect
+	m.HPA.Spec.Behavior = &autoscalingv2.HorizontalPodAutoscalerBehavior{
+		ScaleUp:   &autoscalingv2.HPAScalingRules{SelectPolicy: &disabled},
+		ScaleDown: &autoscalingv2.HPAScalingRules{SelectPolicy: &disabled},
+	}
+
+	m.Actions = append(m.Actions, Action{Type: DisableHPAAction, HPAName: hpa.Name, Namespace: hpa.Namespace})
+	return nil
+}
 
-	m.Actions = append(m.Actions, Action{Type: DeletePodAction, PodName: podname, Namespace: namespace})
+func MockRecordEvent(m *MockMetrics, ctx context.Context, clientset kube_client.Interface, object v1.ObjectReference, eventType string, reason string, message string) error {
+	m.Events = append(m.Events, eventType+" "+reason)
 	return nil
 }
 
@@ -316,23 +531,35 @@ func CreateSimpleMockMetrics() *MockMetrics {
 	mm.MockGetClientset = MockClientset
 	mm.MockGetMetricsClientset = MockMetricsClientset
 	mm.MockGetNodeList = MockNodeList
-	mm.MockGetControlledDeployments = MockControlledDeployments
+	mm.MockGetDynamicClient = MockDynamicClient
+	mm.MockGetPodoscalerPolicies = MockPodoscalerPolicies
+	mm.MockUpdatePodoscalerPolicyStatus = MockUpdatePodoscalerPolicyStatus
+	mm.MockGetDeployment = MockDeployment
 	mm.MockGetReadyPodListForDeployment = MockReadyPodListForDeployment
+	mm.MockGetUnschedulablePodListForDeployment = MockUnschedulablePodListForDeployment
 	mm.MockGetDeploymentUtilAndAlloc = MockDeploymentUtilAndAlloc
+	mm.MockGetPodContainerUsage = MockPodContainerUsage
 	mm.MockGetNodeUsage = MockNodeUsage
 	mm.MockGetNodeAllocableAndCapacity = MockNodeAllocableAndCapacity
+	mm.MockGetNodeScheduling = MockNodeScheduling
 	mm.MockGetLatencyMetrics = MockLatencyMetrics
+	mm.MockGetTrafficMetrics = MockTrafficMetrics
 	mm.MockVScale = MockVScale
+	mm.MockWaitForResize = MockWaitForResize
+	mm.MockPatchDeploymentReqs = MockPatchDeploymentReqs
 	mm.MockChangeReplicaCount = MockChangeReplicaCount
-	mm.MockDeletePod = MockDeletePod
+	mm.MockMigratePod = MockMigratePod
+	mm.MockGetHPAForDeployment = MockHPAForDeployment
+	mm.MockDisableHPA = MockDisableHPA
+	mm.MockRecordEvent = MockRecordEvent
 
 	// default values
 	mm.DeploymentName = MOCK_DEPLOYMENT_NAME
 	mm.DeploymentNamespace = MOCK_DEPLOYMENT_NAMESPACE
 	mm.Pods = map[string]PodData{
-		"pod1": {"pod1", "node1", "container", 300},
-		"pod2": {"pod2", "node1", "container", 300},
-		"pod3": {"pod3", "node2", "container", 300},
+		"pod1": {PodName: "pod1", NodeName: "node1", ContainerName: "container", CpuRequests: 300},
+		"pod2": {PodName: "pod2", NodeName: "node1", ContainerName: "container", CpuRequests: 300},
+		"pod3": {PodName: "pod3", NodeName: "node2", ContainerName: "container", CpuRequests: 300},
 	}
 	mm.Latency = MOCK_LATENCY_THRESHOLD * 0.95
 	mm.RelNodeUsages = map[string]float64{
@@ -351,3 +578,65 @@ func CreateSimpleMockMetrics() *MockMetrics {
 
 	return mm
 }
+
+/* planner helpers - build DeploymentState directly instead of going through MockMetrics */
+func MakePlannerState(pods MockPodList, relUtil float64, latencyMs float64, policy autoscaler.DeploymentPolicy) autoscaler.DeploymentState {
+	state := autoscaler.DeploymentState{
+		Name:      MOCK_DEPLOYMENT_NAME,
+		Namespace: MOCK_DEPLOYMENT_NAMESPACE,
+		Policy:    policy,
+		Latencies: map[string]float64{"p99": latencyMs},
+		Nodes: map[string]autoscaler.NodeState{
+			"node1": {Name: "node1", Usage: 540, Allocable: 400, Capacity: 1000, MemAllocable: MOCK_NODE_MEMORY},
+			"node2": {Name: "node2", Usage: 270, Allocable: 700, Capacity: 1000, MemAllocable: MOCK_NODE_MEMORY},
+		},
+	}
+
+	for _, k := range GetPodListKeys(pods) {
+		p := pods[k]
+		containers := []autoscaler.ContainerState{}
+		for _, c := range p.Extra {
+			if !util.IsSidecar(c.Name, policy.Sidecars) {
+				containers = append(containers, autoscaler.ContainerState{Name: c.Name, CpuRequests: c.CpuRequests, CpuUsage: int64(relUtil * float64(c.CpuRequests)), MemRequests: c.MemRequests, MemUsage: c.MemUsage})
+			}
+		}
+		containers = append(containers, autoscaler.ContainerState{Name: p.ContainerName, CpuRequests: p.CpuRequests, CpuUsage: int64(relUtil * float64(p.CpuRequests)), MemRequests: p.MemRequests, MemUsage: p.MemUsage})
+		pod := autoscaler.NewPodState(p.PodName, p.NodeName, containers)
+		state.Pods = append(state.Pods, pod)
+		state.MemAllocation += pod.MemRequests
+		state.MemUsage += pod.MemUsage
+	}
+	state.Allocation = GetDeploymentAlloc(pods, policy.Sidecars)
+	state.Utilization = int64(relUtil * float64(state.Allocation))
+	return state
+}
+
+func MakePlannerPolicy(maps int64, latencyThreshold int64) autoscaler.DeploymentPolicy {
+	return autoscaler.DeploymentPolicy{
+		LatencyThreshold:              latencyThreshold,
+		Maps:                          maps,
+		MinNodeAvailabilityThreshold:  0.2,
+		DownscaleUtilizationThreshold: 0.85,
+		MinReplicas:                   autoscaler.DEFAULT_MIN_REPLICAS,
+		MinCPU:                        autoscaler.DEFAULT_MIN_REQUESTS,
+		MinMemory:                     autoscaler.DEFAULT_MIN_MEMORY,
+		MemoryPressureThreshold:       autoscaler.DEFAULT_MEMORY_PRESSURE_THRESHOLD,
+		MemoryDownscaleThreshold:      autoscaler.DEFAULT_MEMORY_DOWNSCALE_THRESHOLD,
+		Sidecars:                      util.DEFAULT_SIDECARS,
+		ScalingPolicy:                 autoscaler.DEFAULT_SCALING_POLICY,
+		HPATargetUtilization:          autoscaler.DEFAULT_HPA_TARGET_UTILIZATION,
+	}
+}
+
+func AssertScaleActions(actual []autoscaler.ScaleAction, expected []autoscaler.ScaleAction, t *testing.T) {
+	if len(actual) != len(expected) {
+		t.Errorf("expected %d actions, got %d: %+v", len(expected), len(actual), actual)
+		return
+	}
+	for i := range expected {
+		a, e := actual[i], expected[i]
+		if a.Type != e.Type || a.Replicas != e.Replicas || a.CpuRequests != e.CpuRequests || a.MemRequests != e.MemRequests || a.PodName != e.PodName || a.Migration != e.Migration {
+			t.Errorf("action %d mismatch, expected %+v, got %+v", i, e, a)
+		}
+	}
+}
@@ -4,42 +4,364 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	autoscaler "github.com/tholiang/podoscaler/scalers/autoscaler"
 	"github.com/tholiang/podoscaler/scalers/util"
 )
 
+const LEASE_NAME = "podoscaler-autoscaler"
+
+// AUTOSCALE_DRY_RUN=true only reports what each round would do
+// AUTOSCALE_PLAN_FILE appends each round's plan as a json line, dry runs without one print it to stdout
+func plan_output() (bool, io.Writer) {
+	dryrun, err := strconv.ParseBool(os.Getenv("AUTOSCALE_DRY_RUN"))
+	if err != nil {
+		dryrun = false
+	}
+
+	// outside a dry run the plan stays out of the log stream unless a file asks for it
+	var fallback io.Writer
+	if dryrun {
+		fallback = os.Stdout
+	}
+
+	path := os.Getenv("AUTOSCALE_PLAN_FILE")
+	if path == "" {
+		return dryrun, fallback
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Printf("❌ ERROR: Failed to open plan file %s: %s\n", path, err.Error())
+		return dryrun, fallback
+	}
+	return dryrun, f
+}
+
+// AUTOSCALE_SIDECARS lists containers to leave alone, comma separated (defaults to util.DEFAULT_SIDECARS)
+// set it empty to measure and resize every container
+func sidecars() []string {
+	v, ok := os.LookupEnv("AUTOSCALE_SIDECARS")
+	if !ok {
+		return util.DEFAULT_SIDECARS
+	}
+	return util.ParseContainerList(v)
+}
+
+// AUTOSCALE_CPU_LIMIT is the default cpu limit policy, see autoscaler.ParseCPULimitPolicy
+func cpu_limit() autoscaler.CPULimitPolicy {
+	limit, err := autoscaler.ParseCPULimitPolicy(os.Getenv("AUTOSCALE_CPU_LIMIT"))
+	if err != nil {
+		fmt.Printf("❌ ERROR: Bad AUTOSCALE_CPU_LIMIT, keeping limits as is: %s\n", err.Error())
+		return autoscaler.CPULimitPolicy{Mode: autoscaler.KeepCPULimit}
+	}
+	return limit
+}
+
+// AUTOSCALE_LATENCY_TARGETS is the default slo, see autoscaler.ParseLatencyTargets (defaults to p99 under the latency threshold)
+func latency_targets() []autoscaler.LatencyTarget {
+	v := os.Getenv("AUTOSCALE_LATENCY_TARGETS")
+	if v == "" {
+		return nil
+	}
+	targets, err := autoscaler.ParseLatencyTargets(v)
+	if err != nil {
+		fmt.Printf("❌ ERROR: Bad AUTOSCALE_LATENCY_TARGETS, holding p99 under the latency threshold: %s\n", err.Error())
+		return nil
+	}
+	return targets
+}
+
+// AUTOSCALE_AVAILABILITY_TARGET is the default share of requests that have to succeed, e.g. 0.999 (defaults to no availability slo)
+func availability_target() float64 {
+	v := os.Getenv("AUTOSCALE_AVAILABILITY_TARGET")
+	if v == "" {
+		return 0
+	}
+	target, err := strconv.ParseFloat(v, 64)
+	if err != nil || target < 0 || target >= 1 {
+		fmt.Printf("❌ ERROR: Bad AUTOSCALE_AVAILABILITY_TARGET %q, no availability slo\n", v)
+		return 0
+	}
+	return target
+}
+
+// AUTOSCALE_WORKERS is how many deployments are processed at once
+func workers() int {
+	n, err := strconv.Atoi(os.Getenv("AUTOSCALE_WORKERS"))
+	if err != nil || n <= 0 {
+		return autoscaler.DEFAULT_WORKERS
+	}
+	return n
+}
+
+// AUTOSCALE_MAX_REPLICAS and AUTOSCALE_MAX_REQUESTS (in millicpus) cap every deployment without its own
+// bounds, unset or 0 leaves them uncapped
+func upper_bound(key string) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		fmt.Printf("❌ ERROR: Bad %s %q, no cap\n", key, v)
+		return 0
+	}
+	return n
+}
+
+// AUTOSCALE_DEPLOYMENT_TIMEOUT bounds one deployment's round (e.g. 45s)
+func deployment_timeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("AUTOSCALE_DEPLOYMENT_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		return autoscaler.DEFAULT_DEPLOYMENT_TIMEOUT
+	}
+	return timeout
+}
+
+// AUTOSCALE_GRACE_PERIOD is how long an action in flight may keep going after SIGTERM (e.g. 20s)
+func grace_period() time.Duration {
+	grace, err := time.ParseDuration(os.Getenv("AUTOSCALE_GRACE_PERIOD"))
+	if err != nil || grace <= 0 {
+		return autoscaler.DEFAULT_GRACE_PERIOD
+	}
+	return grace
+}
+
+// AUTOSCALE_SCALE_UP_STABILIZATION and AUTOSCALE_SCALE_DOWN_STABILIZATION are how long a change
+// has to be recommended before it is made (e.g. 5m), 0 makes it right away
+func stabilization(key string, fallback time.Duration) time.Duration {
+	window, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || window < 0 {
+		return fallback
+	}
+	return window
+}
+
+// AUTOSCALE_RESIZE_TIMEOUT is how long the kubelet gets to apply one pod's resize (e.g. 20s)
+func resize_timeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("AUTOSCALE_RESIZE_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		return autoscaler.DEFAULT_RESIZE_TIMEOUT
+	}
+	return timeout
+}
+
+// AUTOSCALE_MIGRATION_TIMEOUT is how long a migrated pod's replacement gets to become ready (e.g. 1m)
+func migration_timeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("AUTOSCALE_MIGRATION_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		return autoscaler.DEFAULT_MIGRATION_TIMEOUT
+	}
+	return timeout
+}
+
+// AUTOSCALE_FORECAST_SEASON is the length of the traffic pattern forecasts learn (e.g. 24h or 168h),
+// AUTOSCALE_FORECAST_HORIZON how far ahead to scale for them (e.g. 10m)
+func forecast_duration(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// AUTOSCALE_FORECAST_WEIGHT is the forecast's share of the demand sized for, in [0, 1], 0 turns forecasting off
+func forecast_weight() float64 {
+	weight, err := strconv.ParseFloat(os.Getenv("AUTOSCALE_FORECAST_WEIGHT"), 64)
+	if err != nil || weight < 0 || weight > 1 {
+		return autoscaler.DEFAULT_FORECAST_WEIGHT
+	}
+	return weight
+}
+
+// AUTOSCALE_SCALING_MODE is the default scaling mode, rules or controller
+func scaling_mode() autoscaler.ScalingMode {
+	mode, err := autoscaler.ParseScalingMode(os.Getenv("AUTOSCALE_SCALING_MODE"))
+	if err != nil {
+		fmt.Printf("❌ ERROR: Bad AUTOSCALE_SCALING_MODE, scaling by rules: %s\n", err.Error())
+		return autoscaler.RulesScalingMode
+	}
+	return mode
+}
+
+// AUTOSCALE_LATENCY_SETPOINT (fraction of the latency threshold) and AUTOSCALE_CONTROLLER_MAX_STEP
+// (fraction of the allocation per round) tune controller mode, AUTOSCALE_HPA_TARGET_UTILIZATION
+// (fraction of cpu requests) the hpa policy, all in (0, 1]
+func fraction(key string, fallback float64) float64 {
+	f, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || f <= 0 || f > 1 {
+		return fallback
+	}
+	return f
+}
+
+// AUTOSCALE_SCALING_POLICY is the default scaling policy, vecter, hpa or vpa
+func scaling_policy() string {
+	name := os.Getenv("AUTOSCALE_SCALING_POLICY")
+	if _, ok := autoscaler.DefaultScalingPolicies()[name]; !ok {
+		if name != "" {
+			fmt.Printf("❌ ERROR: Unknown AUTOSCALE_SCALING_POLICY %q, using %s\n", name, autoscaler.DEFAULT_SCALING_POLICY)
+		}
+		return autoscaler.DEFAULT_SCALING_POLICY
+	}
+	return name
+}
+
+// AUTOSCALE_HPA_INTEROP is what to do with deployments an hpa also scales, refuse, takeover or vertical
+func hpa_interop() autoscaler.HPAInteropMode {
+	mode, err := autoscaler.ParseHPAInteropMode(os.Getenv("AUTOSCALE_HPA_INTEROP"))
+	if err != nil {
+		fmt.Printf("❌ ERROR: Bad AUTOSCALE_HPA_INTEROP, leaving hpa deployments alone: %s\n", err.Error())
+		return autoscaler.DEFAULT_HPA_INTEROP
+	}
+	return mode
+}
+
+// AUTOSCALE_LEADER_ELECT=false runs rounds without taking the lease (single replica only)
+func leader_elect() bool {
+	elect, err := strconv.ParseBool(os.Getenv("AUTOSCALE_LEADER_ELECT"))
+	if err != nil {
+		return true
+	}
+	return elect
+}
+
+// AUTOSCALE_METRICS_ADDR is where /metrics is served (defaults to :8080)
+func serve_metrics(status *util.LeaderStatus, stats *util.RoundStats) {
+	addr := os.Getenv("AUTOSCALE_METRICS_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		status.ServeHTTP(w, r)
+		stats.ServeHTTP(w, r)
+	})
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		if err != nil {
+			fmt.Printf("❌ ERROR: Metrics server on %s stopped: %s\n", addr, err.Error())
+		}
+	}()
+}
+
+// a failed round is logged and counted, the next one runs on schedule
+func run_rounds(ctx context.Context, a *autoscaler.Autoscaler, stats *util.RoundStats) {
+	lastroundtime := time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC)
+	for ctx.Err() == nil {
+		// Check if the last round was more than 60 seconds ago
+		if time.Since(lastroundtime) >= 60*time.Second {
+			lastroundtime = time.Now()
+			err := a.RunRound(ctx)
+			if ctx.Err() != nil {
+				continue // cut short by shutdown or lost leadership, not a failure
+			}
+			failed := stats.Record(err)
+			if err != nil {
+				fmt.Printf("❌ ERROR: Round failed (%d in a row): %s\n", failed, err.Error())
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(1 * time.Second):
+		}
+	}
+}
+
 func run_autoscaler() {
 	am := new(autoscaler.DefaultAutoscalerMetrics)
+	dryrun, planwriter := plan_output()
 
 	a := autoscaler.Autoscaler{
 		PrometheusUrl:                 util.DEFAULT_PROMETHEUS_URL,
 		MinNodeAvailabilityThreshold:  autoscaler.DEFAULT_MIN_NODE_AVAILABILITY_THRESHOLD,
 		DownscaleUtilizationThreshold: autoscaler.DEFAULT_DOWNSCALE_UTILIZATION_THRESHOLD,
 
-		Maps:             autoscaler.DEFAULT_MAPS,
-		LatencyThreshold: autoscaler.DEFAULT_LATENCY_THRESHOLD,
-		Metrics:          am,
+		Maps:               autoscaler.DEFAULT_MAPS,
+		LatencyThreshold:   autoscaler.DEFAULT_LATENCY_THRESHOLD,
+		LatencyTargets:     latency_targets(),
+		AvailabilityTarget: availability_target(),
+		MinReplicas:        autoscaler.DEFAULT_MIN_REPLICAS,
+		MaxReplicas:        int(upper_bound("AUTOSCALE_MAX_REPLICAS")),
+		MinRequests:        autoscaler.DEFAULT_MIN_REQUESTS,
+		MaxRequests:        upper_bound("AUTOSCALE_MAX_REQUESTS"),
+		Sidecars:           sidecars(),
+		CPULimit:           cpu_limit(),
+		DryRun:             dryrun,
+		PlanWriter:         planwriter,
+		Metrics:            am,
+
+		Workers:           workers(),
+		DeploymentTimeout: deployment_timeout(),
+		GracePeriod:       grace_period(),
+		ResizeTimeout:     resize_timeout(),
+		MigrationTimeout:  migration_timeout(),
+
+		ScaleUpStabilization:   stabilization("AUTOSCALE_SCALE_UP_STABILIZATION", autoscaler.DEFAULT_SCALE_UP_STABILIZATION),
+		ScaleDownStabilization: stabilization("AUTOSCALE_SCALE_DOWN_STABILIZATION", autoscaler.DEFAULT_SCALE_DOWN_STABILIZATION),
+
+		ForecastSeason:  forecast_duration("AUTOSCALE_FORECAST_SEASON", autoscaler.DEFAULT_FORECAST_SEASON),
+		ForecastHorizon: forecast_duration("AUTOSCALE_FORECAST_HORIZON", autoscaler.DEFAULT_FORECAST_HORIZON),
+		ForecastWeight:  forecast_weight(),
+
+		ScalingMode:       scaling_mode(),
+		LatencySetpoint:   fraction("AUTOSCALE_LATENCY_SETPOINT", autoscaler.DEFAULT_LATENCY_SETPOINT),
+		ControllerMaxStep: fraction("AUTOSCALE_CONTROLLER_MAX_STEP", autoscaler.DEFAULT_CONTROLLER_MAX_STEP),
+
+		ScalingPolicy:        scaling_policy(),
+		HPATargetUtilization: fraction("AUTOSCALE_HPA_TARGET_UTILIZATION", autoscaler.DEFAULT_HPA_TARGET_UTILIZATION),
+
+		HPAInterop: hpa_interop(),
 	}
 	err := a.Init()
 	if err != nil {
 		panic(err)
 	}
 
-	lastroundtime := time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC)
-	for {
-		// Check if the last round was more than 60 seconds ago
-		if time.Since(lastroundtime) >= 60*time.Second {
-			lastroundtime = time.Now()
-			err := a.RunRound()
-			if err != nil {
-				panic(err)
-			}
-		}
+	// SIGTERM stops new rounds and actions, the round in flight finishes before the lease is released
+	// so a standby replica takes over without waiting it out
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// standbys keep their cache warm too, so a new leader can start its first round right away
+	am.Cache, err = util.NewClusterCache(a.Clientset, util.DEFAULT_CACHE_RESYNC)
+	if err != nil {
+		panic(err)
+	}
+	err = am.Cache.Start(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	config := util.DefaultLeaderElectionConfig(LEASE_NAME)
+	status := &util.LeaderStatus{Identity: config.Identity}
+	stats := &util.RoundStats{}
+	serve_metrics(status, stats)
 
-		time.Sleep(1 * time.Second)
+	if !leader_elect() {
+		fmt.Printf("⚠️ Leader election is off, only run one replica\n")
+		status.SetLeading(true)
+		run_rounds(ctx, &a, stats)
+		return
+	}
+	err = util.RunLeaderElected(ctx, a.Clientset, config, status, func(leaderCtx context.Context) {
+		run_rounds(leaderCtx, &a, stats)
+	})
+	if err != nil {
+		panic(err)
 	}
+	fmt.Printf("Shut down\n")
 }
 
 func main() {
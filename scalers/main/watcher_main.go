@@ -4,6 +4,11 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/tholiang/podoscaler/scalers/util"
@@ -14,19 +19,26 @@ func run_autoscaler() {
 	w := watcher.Watcher{
 		PrometheusUrl: util.DEFAULT_PROMETHEUS_URL,
 	}
-	err := w.Init()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	err := w.Init(ctx)
 	if err != nil {
 		panic(err)
 	}
 
-	for {
-		err := w.WatchRound()
+	for ctx.Err() == nil {
+		err := w.WatchRound(ctx)
 		if err != nil {
 			// panic(err)
 		}
 
-		time.Sleep(60 * time.Second)
+		select {
+		case <-ctx.Done():
+		case <-time.After(60 * time.Second):
+		}
 	}
+	fmt.Printf("Shut down\n")
 }
 
 func main() {
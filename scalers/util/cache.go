@@ -0,0 +1,198 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	kube_client "k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+/* --- CLUSTER READS ---
+ * nodes, pods and deployments are read through a ClusterReader
+ * ClusterCache serves them from shared informers, APIReader goes to the API server every call
+ * writes (patches, deletes) always go to the API server
+ */
+type ClusterReader interface {
+	ListNodes(ctx context.Context) ([]v1.Node, error)
+	GetNode(ctx context.Context, name string) (*v1.Node, error)
+	GetDeployment(ctx context.Context, namespace string, name string) (*appsv1.Deployment, error)
+	ListDeployments(ctx context.Context, selector labels.Selector) ([]appsv1.Deployment, error) // across all namespaces
+	ListPods(ctx context.Context, namespace string, selector labels.Selector) ([]v1.Pod, error)
+	ListNodePods(ctx context.Context, nodeName string) ([]v1.Pod, error) // across all namespaces
+}
+
+const DEFAULT_CACHE_RESYNC = 10 * time.Minute
+
+const nodeNameIndex = "spec.nodeName"
+
+// objects handed out by the cache are shared with it and must not be modified
+type ClusterCache struct {
+	factory     informers.SharedInformerFactory
+	nodes       corelisters.NodeLister
+	pods        corelisters.PodLister
+	podsByNode  cache.Indexer
+	deployments appslisters.DeploymentLister
+}
+
+func NewClusterCache(clientset kube_client.Interface, resync time.Duration) (*ClusterCache, error) {
+	factory := informers.NewSharedInformerFactory(clientset, resync)
+	podInformer := factory.Core().V1().Pods()
+
+	err := podInformer.Informer().AddIndexers(cache.Indexers{
+		nodeNameIndex: func(obj any) ([]string, error) {
+			pod, ok := obj.(*v1.Pod)
+			if !ok || pod.Spec.NodeName == "" {
+				return []string{}, nil
+			}
+			return []string{pod.Spec.NodeName}, nil
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to index pods by node: %w", err)
+	}
+
+	return &ClusterCache{
+		factory:     factory,
+		nodes:       factory.Core().V1().Nodes().Lister(),
+		pods:        podInformer.Lister(),
+		podsByNode:  podInformer.Informer().GetIndexer(),
+		deployments: factory.Apps().V1().Deployments().Lister(),
+	}, nil
+}
+
+// starts the informers and blocks until they have synced, they stop with ctx
+func (c *ClusterCache) Start(ctx context.Context) error {
+	c.factory.Start(ctx.Done())
+	for informerType, synced := range c.factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("cache for %v did not sync", informerType)
+		}
+	}
+	return nil
+}
+
+func (c *ClusterCache) ListNodes(ctx context.Context) ([]v1.Node, error) {
+	nodes, err := c.nodes.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	return derefAll(nodes), nil
+}
+
+func (c *ClusterCache) GetNode(ctx context.Context, name string) (*v1.Node, error) {
+	return c.nodes.Get(name)
+}
+
+func (c *ClusterCache) GetDeployment(ctx context.Context, namespace string, name string) (*appsv1.Deployment, error) {
+	return c.deployments.Deployments(namespace).Get(name)
+}
+
+func (c *ClusterCache) ListDeployments(ctx context.Context, selector labels.Selector) ([]appsv1.Deployment, error) {
+	deployments, err := c.deployments.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return derefAll(deployments), nil
+}
+
+func (c *ClusterCache) ListPods(ctx context.Context, namespace string, selector labels.Selector) ([]v1.Pod, error) {
+	pods, err := c.pods.Pods(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return derefAll(pods), nil
+}
+
+func (c *ClusterCache) ListNodePods(ctx context.Context, nodeName string) ([]v1.Pod, error) {
+	objs, err := c.podsByNode.ByIndex(nodeNameIndex, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]v1.Pod, 0, len(objs))
+	for _, obj := range objs {
+		pods = append(pods, *obj.(*v1.Pod))
+	}
+	return pods, nil
+}
+
+// shallow copies - the nested maps and slices still belong to the cache
+func derefAll[T any](objs []*T) []T {
+	out := make([]T, 0, len(objs))
+	for _, obj := range objs {
+		out = append(out, *obj)
+	}
+	return out
+}
+
+// reads straight from the API server, for tests and anything that runs without a cache
+type APIReader struct {
+	Clientset kube_client.Interface
+}
+
+func NewAPIReader(clientset kube_client.Interface) *APIReader {
+	return &APIReader{Clientset: clientset}
+}
+
+func (r *APIReader) ListNodes(ctx context.Context) ([]v1.Node, error) {
+	nodes, err := retryGet(ctx, func(ctx context.Context) (*v1.NodeList, error) {
+		return r.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nodes.Items, nil
+}
+
+func (r *APIReader) GetNode(ctx context.Context, name string) (*v1.Node, error) {
+	return retryGet(ctx, func(ctx context.Context) (*v1.Node, error) {
+		return r.Clientset.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	})
+}
+
+func (r *APIReader) GetDeployment(ctx context.Context, namespace string, name string) (*appsv1.Deployment, error) {
+	return retryGet(ctx, func(ctx context.Context) (*appsv1.Deployment, error) {
+		return r.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	})
+}
+
+func (r *APIReader) ListDeployments(ctx context.Context, selector labels.Selector) ([]appsv1.Deployment, error) {
+	deployments, err := retryGet(ctx, func(ctx context.Context) (*appsv1.DeploymentList, error) {
+		return r.Clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deployments.Items, nil
+}
+
+func (r *APIReader) ListPods(ctx context.Context, namespace string, selector labels.Selector) ([]v1.Pod, error) {
+	pods, err := retryGet(ctx, func(ctx context.Context) (*v1.PodList, error) {
+		return r.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+func (r *APIReader) ListNodePods(ctx context.Context, nodeName string) ([]v1.Pod, error) {
+	pods, err := retryGet(ctx, func(ctx context.Context) (*v1.PodList, error) {
+		return r.Clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
@@ -15,10 +15,11 @@ import (
 	kube_client "k8s.io/client-go/kubernetes"
 )
 
-func GetLatencyCloudwatch(loadbalancer_name string) (map[string]float64, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+func GetLatencyCloudwatch(ctx context.Context, loadbalancer_name string) (map[string]float64, error) {
+	// retries are left to Retry so they share its backoff and classification
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRetryer(func() aws.Retryer { return aws.NopRetryer{} }))
 	if err != nil {
-		panic("configuration error, " + err.Error())
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
 	}
 
 	client := cloudwatch.NewFromConfig(cfg)
@@ -41,9 +42,11 @@ func GetLatencyCloudwatch(loadbalancer_name string) (map[string]float64, error)
 		ExtendedStatistics: []string{"p90", "p95", "p99", "p99.9", "p99.99", "p99.999", "p100"},
 	}
 
-	result, err := client.GetMetricStatistics(context.TODO(), input)
+	result, err := retryGet(ctx, func(ctx context.Context) (*cloudwatch.GetMetricStatisticsOutput, error) {
+		return client.GetMetricStatistics(ctx, input)
+	})
 	if err != nil {
-		panic("failed to get metrics, " + err.Error())
+		return nil, fmt.Errorf("failed to get latency from cloudwatch: %w", err)
 	}
 
 	for _, dp := range result.Datapoints {
@@ -52,8 +55,85 @@ func GetLatencyCloudwatch(loadbalancer_name string) (map[string]float64, error)
 	return nil, fmt.Errorf("No datapoints")
 }
 
-func GetLoadBalancerName(clientset kube_client.Interface, namespace string, serviceName string) (string, error) {
-	svc, err := clientset.CoreV1().Services(namespace).Get(context.TODO(), serviceName, metav1.GetOptions{})
+// requests and 5xx responses over the last minute, per second
+type Traffic struct {
+	Requests float64 // requests per second
+	Errors   float64 // 5xx responses per second
+}
+
+// share of requests that failed, 0 without traffic
+func (t Traffic) ErrorRatio() float64 {
+	if t.Requests <= 0 {
+		return 0
+	}
+	return min(t.Errors/t.Requests, 1)
+}
+
+// load balancer and backend 5xx both count, either way the request failed
+var CLOUDWATCH_ERROR_METRICS = []string{"HTTPCode_Backend_5XX", "HTTPCode_ELB_5XX"}
+
+func GetTrafficCloudwatch(ctx context.Context, loadbalancer_name string) (Traffic, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRetryer(func() aws.Retryer { return aws.NopRetryer{} }))
+	if err != nil {
+		return Traffic{}, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	client := cloudwatch.NewFromConfig(cfg)
+
+	endTime := time.Now()
+	startTime := endTime.Add(-1 * time.Minute)
+	// false without datapoints
+	perSecond := func(metric string) (float64, bool, error) {
+		input := &cloudwatch.GetMetricStatisticsInput{
+			Namespace:  aws.String("AWS/ELB"),
+			MetricName: aws.String(metric),
+			Dimensions: []types.Dimension{
+				{
+					Name:  aws.String("LoadBalancerName"),
+					Value: aws.String(loadbalancer_name),
+				},
+			},
+			StartTime:  aws.Time(startTime),
+			EndTime:    aws.Time(endTime),
+			Period:     aws.Int32(60),
+			Statistics: []types.Statistic{types.StatisticSum},
+		}
+		result, err := retryGet(ctx, func(ctx context.Context) (*cloudwatch.GetMetricStatisticsOutput, error) {
+			return client.GetMetricStatistics(ctx, input)
+		})
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to get %s from cloudwatch: %w", metric, err)
+		}
+		for _, dp := range result.Datapoints {
+			return aws.ToFloat64(dp.Sum) / 60, true, nil
+		}
+		return 0, false, nil
+	}
+
+	// elb metrics can lag a minute or two, no requests is unread rather than no traffic
+	var traffic Traffic
+	requests, ok, err := perSecond("RequestCount")
+	if err != nil {
+		return Traffic{}, err
+	}
+	if !ok {
+		return Traffic{}, fmt.Errorf("No RequestCount datapoints")
+	}
+	traffic.Requests = requests
+	// 5xx metrics only have datapoints when there were errors
+	for _, metric := range CLOUDWATCH_ERROR_METRICS {
+		failed, _, err := perSecond(metric)
+		if err != nil {
+			return Traffic{}, err
+		}
+		traffic.Errors += failed
+	}
+	return traffic, nil
+}
+
+func GetLoadBalancerName(ctx context.Context, clientset kube_client.Interface, namespace string, serviceName string) (string, error) {
+	svc, err := retryGet(ctx, func(ctx context.Context) (*corev1.Service, error) {
+		return clientset.CoreV1().Services(namespace).Get(ctx, serviceName, metav1.GetOptions{})
+	})
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kube_client "k8s.io/client-go/kubernetes"
+)
+
+const EVENT_SOURCE = "podoscaler"
+
+// records a kubernetes event on the object, eventType is v1.EventTypeNormal or v1.EventTypeWarning
+func RecordEvent(ctx context.Context, clientset kube_client.Interface, object v1.ObjectReference, eventType string, reason string, message string) error {
+	now := metav1.NewTime(time.Now())
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", object.Name, now.UnixNano()),
+			Namespace: object.Namespace,
+		},
+		InvolvedObject: object,
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Source:         v1.EventSource{Component: EVENT_SOURCE},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	// not retried, a create that timed out may still have gone through
+	_, err := clientset.CoreV1().Events(object.Namespace).Create(ctx, event, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to record event %s on %s %s/%s: %w", reason, object.Kind, object.Namespace, object.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	kube_client "k8s.io/client-go/kubernetes"
+)
+
+/* --- HORIZONTAL POD AUTOSCALERS ---
+ * an hpa taken over by the autoscaler is switched off, not deleted: both of its scaling directions get selectPolicy Disabled,
+ * and the behavior it had is kept in an annotation so it can be put back by hand
+ */
+const HPA_ORIGINAL_BEHAVIOR_ANNOTATION = "podoscaler/original-behavior"
+
+// the hpa scaling the deployment, nil if there is none
+func FindHPA(ctx context.Context, clientset kube_client.Interface, deploymentName string, namespace string) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	hpas, err := retryGet(ctx, func(ctx context.Context) (*autoscalingv2.HorizontalPodAutoscalerList, error) {
+		return clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, metav1.ListOptions{})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list horizontal pod autoscalers in %s: %w", namespace, err)
+	}
+	for i, hpa := range hpas.Items {
+		target := hpa.Spec.ScaleTargetRef
+		if target.Kind == "Deployment" && target.Name == deploymentName {
+			return &hpas.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// whether the hpa can no longer change replicas in either direction
+func HPADisabled(hpa *autoscalingv2.HorizontalPodAutoscaler) bool {
+	behavior := hpa.Spec.Behavior
+	disabled := func(rules *autoscalingv2.HPAScalingRules) bool {
+		return rules != nil && rules.SelectPolicy != nil && *rules.SelectPolicy == autoscalingv2.DisabledPolicySelect
+	}
+	return behavior != nil && disabled(behavior.ScaleUp) && disabled(behavior.ScaleDown)
+}
+
+// stops the hpa from scaling, its original behavior is kept under HPA_ORIGINAL_BEHAVIOR_ANNOTATION
+func DisableHPA(ctx context.Context, clientset kube_client.Interface, hpa *autoscalingv2.HorizontalPodAutoscaler) error {
+	if HPADisabled(hpa) {
+		return nil
+	}
+	original, err := json.Marshal(hpa.Spec.Behavior)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"annotations": map[string]string{HPA_ORIGINAL_BEHAVIOR_ANNOTATION: string(original)}},
+		"spec": map[string]any{"behavior": map[string]any{
+			"scaleUp":   map[string]any{"selectPolicy": autoscalingv2.DisabledPolicySelect},
+			"scaleDown": map[string]any{"selectPolicy": autoscalingv2.DisabledPolicySelect},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = retry(ctx, func(ctx context.Context) error {
+		_, err := clientset.AutoscalingV2().HorizontalPodAutoscalers(hpa.Namespace).Patch(ctx, hpa.Name, k8stypes.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to disable horizontal pod autoscaler %s/%s: %w", hpa.Namespace, hpa.Name, err)
+	}
+	return nil
+}
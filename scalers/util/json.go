@@ -2,7 +2,6 @@ package util
 
 import (
 	"encoding/json"
-	"fmt"
 )
 
 // theres definitely a better way to do this
@@ -29,12 +28,31 @@ type HorizontalScaleRequest struct {
 
 /* --- */
 // patch from https://kubernetes.io/docs/tasks/configure-pod-container/resize-container-resources/
+// empty fields are left out of the patch, so that resource keeps its current value
 type VerticalPatchResourceSpec struct {
-	CPU string `json:"cpu"`
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+}
+
+// as a value, deletes that resource from the spec (a strategic merge null)
+// only for deployment templates - running pods can't drop a limit
+const REMOVE_RESOURCE = "remove"
+
+func (s VerticalPatchResourceSpec) MarshalJSON() ([]byte, error) {
+	spec := map[string]*string{}
+	for name, value := range map[string]string{"cpu": s.CPU, "memory": s.Memory} {
+		if value == REMOVE_RESOURCE {
+			spec[name] = nil
+		} else if value != "" {
+			spec[name] = &value
+		}
+	}
+	return json.Marshal(spec)
 }
 
 type VerticalPatchContainerResources struct {
 	Requests VerticalPatchResourceSpec `json:"requests"`
+	Limits   VerticalPatchResourceSpec `json:"limits"`
 }
 
 type VerticalPatchSpecContainer struct {
@@ -50,12 +68,10 @@ type VerticalPatch struct {
 	Spec VerticalPatchSpec `json:"spec"`
 }
 
-func create_vpatch(containername string, cpurequests string) ([]byte, error) {
+func create_vpatch(containername string, resources VerticalPatchContainerResources) ([]byte, error) {
 	containerspec := VerticalPatchSpecContainer{
-		Name: containername,
-		Resources: VerticalPatchContainerResources{
-			Requests: VerticalPatchResourceSpec{cpurequests},
-		},
+		Name:      containername,
+		Resources: resources,
 	}
 
 	vp := VerticalPatch{
@@ -67,27 +83,39 @@ func create_vpatch(containername string, cpurequests string) ([]byte, error) {
 }
 
 type VerticalScaleRequest struct {
-	PodNamespace  string `json:"podnamespace"`
-	PodName       string `json:"podname"`
-	ContainerName string `json:"containername"`
-	CpuRequests   string `json:"cpurequests"`
+	PodNamespace   string `json:"podnamespace"`
+	PodName        string `json:"podname"`
+	ContainerName  string `json:"containername"`
+	CpuRequests    string `json:"cpurequests"`
+	MemoryRequests string `json:"memoryrequests"`
 }
 
-type DeploymentPatchObj struct {
-	Operation string                          `json:"op"`
-	Path      string                          `json:"path"`
-	Value     VerticalPatchContainerResources `json:"value"`
+type DeploymentPatchTemplate struct {
+	Spec VerticalPatchSpec `json:"spec"`
 }
 
-type DeploymentPatch []DeploymentPatchObj
+type DeploymentPatchSpec struct {
+	Template DeploymentPatchTemplate `json:"template"`
+}
+
+type DeploymentPatch struct {
+	Spec DeploymentPatchSpec `json:"spec"`
+}
+
+// strategic merge patch - containers are matched by name, so injected sidecars
+// don't shift anything and the other requests on the container are kept
+func create_deployment_request_patch(containername string, resources VerticalPatchContainerResources) ([]byte, error) {
+	containerspec := VerticalPatchSpecContainer{
+		Name:      containername,
+		Resources: resources,
+	}
 
-func create_deployment_request_patch(containeridx int, cpurequests string) ([]byte, error) {
 	dp := DeploymentPatch{
-		DeploymentPatchObj{
-			Operation: "replace",
-			Path:      fmt.Sprintf("/spec/template/spec/containers/%d/resources", containeridx),
-			Value: VerticalPatchContainerResources{
-				Requests: VerticalPatchResourceSpec{cpurequests},
+		DeploymentPatchSpec{
+			DeploymentPatchTemplate{
+				VerticalPatchSpec{
+					[]VerticalPatchSpecContainer{containerspec},
+				},
 			},
 		},
 	}
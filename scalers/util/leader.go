@@ -0,0 +1,135 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kube_client "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+/* --- LEADER ELECTION ---
+ * replicas race for a Lease, only the holder runs rounds
+ * the lease is released when the context is cancelled (e.g. on SIGTERM) so a standby takes over right away
+ */
+const (
+	DEFAULT_LEASE_DURATION = 15 * time.Second // how long standbys wait before taking over a silent leader
+	DEFAULT_RENEW_DEADLINE = 10 * time.Second // how long the leader keeps retrying a renewal before stepping down
+	DEFAULT_RETRY_PERIOD   = 2 * time.Second
+)
+
+type LeaderElectionConfig struct {
+	LeaseName      string
+	LeaseNamespace string
+	Identity       string // unique per replica, usually the pod name
+}
+
+// pod name and namespace come from the downward API, hostname and default otherwise
+func DefaultLeaderElectionConfig(leaseName string) LeaderElectionConfig {
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		identity, _ = os.Hostname()
+	}
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		namespace = "default"
+	}
+	return LeaderElectionConfig{LeaseName: leaseName, LeaseNamespace: namespace, Identity: identity}
+}
+
+// whether this replica leads, served as a prometheus gauge
+type LeaderStatus struct {
+	Identity string
+	leading  atomic.Bool
+}
+
+func (s *LeaderStatus) IsLeader() bool {
+	return s.leading.Load()
+}
+
+// returns whether this replica was leading before
+func (s *LeaderStatus) SetLeading(leading bool) bool {
+	return s.leading.Swap(leading)
+}
+
+func (s *LeaderStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	leading := 0
+	if s.IsLeader() {
+		leading = 1
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# HELP podoscaler_leader Whether this replica holds the autoscaler lease.\n")
+	fmt.Fprintf(w, "# TYPE podoscaler_leader gauge\n")
+	fmt.Fprintf(w, "podoscaler_leader{identity=%q} %d\n", s.Identity, leading)
+}
+
+// campaigns for the lease until ctx is done, calling run whenever this replica leads
+// run gets a context that is cancelled when leadership is lost and must return soon after
+func RunLeaderElected(ctx context.Context, clientset kube_client.Interface, config LeaderElectionConfig, status *LeaderStatus, run func(ctx context.Context)) error {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: config.LeaseName, Namespace: config.LeaseNamespace},
+		Client:     clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: config.Identity},
+	}
+
+	// a run from an earlier term may still be winding down when the lease is won back
+	var running sync.Mutex
+
+	// the lease is held until the run in flight has wound down after ctx is done,
+	// so a standby never starts a round while this one is still acting
+	electCtx, stopElecting := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopElecting()
+	context.AfterFunc(ctx, func() {
+		running.Lock()
+		defer running.Unlock()
+		stopElecting()
+	})
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            config.LeaseName,
+		LeaseDuration:   DEFAULT_LEASE_DURATION,
+		RenewDeadline:   DEFAULT_RENEW_DEADLINE,
+		RetryPeriod:     DEFAULT_RETRY_PERIOD,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				running.Lock()
+				defer running.Unlock()
+				runCtx, cancel := context.WithCancel(leaderCtx)
+				defer cancel()
+				defer context.AfterFunc(ctx, cancel)()
+
+				status.SetLeading(true)
+				fmt.Printf("👑 %s is now leading (lease %s/%s)\n", config.Identity, config.LeaseNamespace, config.LeaseName)
+				run(runCtx)
+			},
+			OnStoppedLeading: func() {
+				if status.SetLeading(false) {
+					fmt.Printf("👋 %s stopped leading\n", config.Identity)
+				}
+			},
+			OnNewLeader: func(identity string) {
+				if identity != config.Identity {
+					fmt.Printf("ℹ️ %s is leading, %s is on standby\n", identity, config.Identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up leader election: %w", err)
+	}
+
+	// Run returns when leadership is lost, campaign again until shut down
+	for electCtx.Err() == nil {
+		elector.Run(electCtx)
+	}
+	return nil
+}
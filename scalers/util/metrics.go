@@ -3,66 +3,88 @@ package util
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	// "math"
-	// "slices"
-	// "strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	kube_client "k8s.io/client-go/kubernetes"
 	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	metrics_client "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 const AUTOSCALE_LABEL = "vecter=true"
 
-func getPodMetricsListForDeployment(clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string) (*v1beta1.PodMetricsList, error) {
-	ctx := context.TODO()
+// containers injected next to the app, left out of measuring and resizing
+var DEFAULT_SIDECARS = []string{"linkerd-proxy", "istio-proxy"}
 
-	// Get the Deployment
-	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+func IsSidecar(containerName string, sidecars []string) bool {
+	return slices.Contains(sidecars, containerName)
+}
+
+// comma separated container names, e.g. "linkerd-proxy, fluent-bit"
+func ParseContainerList(value string) []string {
+	names := []string{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// the deployment's label selector, for pods and pod metrics
+func getDeploymentSelector(ctx context.Context, reader ClusterReader, deploymentName, namespace string) (labels.Selector, error) {
+	deployment, err := reader.GetDeployment(ctx, namespace, deploymentName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get deployment: %w", err)
 	}
+	return labels.Set(deployment.Spec.Selector.MatchLabels).AsSelector(), nil
+}
 
-	// List Pods from metric server using the deployment's label selector
-	labelSelector := labels.Set(deployment.Spec.Selector.MatchLabels).AsSelector().String()
+func getPodMetricsListForDeployment(ctx context.Context, reader ClusterReader, metricsClient *metrics_client.Clientset, deploymentName, namespace string) (*v1beta1.PodMetricsList, error) {
+	selector, err := getDeploymentSelector(ctx, reader, deploymentName, namespace)
+	if err != nil {
+		return nil, err
+	}
 
-	return metricsClient.MetricsV1beta1().PodMetricses(namespace).List(context.TODO(), metav1.ListOptions{
-		LabelSelector: labelSelector,
+	// pod metrics aren't cached, they change every scrape
+	return retryGet(ctx, func(ctx context.Context) (*v1beta1.PodMetricsList, error) {
+		return metricsClient.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: selector.String(),
+		})
 	})
 }
 
-func GetNodeList(clientset kube_client.Interface) (*v1.NodeList, error) {
-	ctx := context.TODO()
-
-	return clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+func GetNodeList(ctx context.Context, reader ClusterReader) (*v1.NodeList, error) {
+	nodes, err := reader.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &v1.NodeList{Items: nodes}, nil
 }
 
-func GetUnschedulablePodListForDeployment(clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
-	ctx := context.TODO()
-
-	// Get the Deployment
-	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+func getDeploymentPods(ctx context.Context, reader ClusterReader, deploymentName, namespace string) ([]v1.Pod, error) {
+	selector, err := getDeploymentSelector(ctx, reader, deploymentName, namespace)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get deployment: %w", err)
+		return nil, err
 	}
+	return reader.ListPods(ctx, namespace, selector)
+}
 
-	// List Pods from metric server using the deployment's label selector
-	labelSelector := labels.Set(deployment.Spec.Selector.MatchLabels).AsSelector().String()
-
-	podlistobj, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: labelSelector,
-	})
+func GetUnschedulablePodListForDeployment(ctx context.Context, reader ClusterReader, deploymentName, namespace string) ([]v1.Pod, error) {
+	pods, err := getDeploymentPods(ctx, reader, deploymentName, namespace)
 	if err != nil {
 		return []v1.Pod{}, err
 	}
 
 	podlist := []v1.Pod{}
-	for _, poddata := range podlistobj.Items {
+	for _, poddata := range pods {
 		for _, cond := range poddata.Status.Conditions {
 			if cond.Type == v1.PodScheduled && cond.Reason == v1.PodReasonUnschedulable {
 				podlist = append(podlist, poddata)
@@ -74,27 +96,14 @@ func GetUnschedulablePodListForDeployment(clientset kube_client.Interface, deplo
 	return podlist, nil
 }
 
-func GetReadyPodListForDeployment(clientset kube_client.Interface, deploymentName, namespace string) ([]v1.Pod, error) {
-	ctx := context.TODO()
-
-	// Get the Deployment
-	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get deployment: %w", err)
-	}
-
-	// List Pods from metric server using the deployment's label selector
-	labelSelector := labels.Set(deployment.Spec.Selector.MatchLabels).AsSelector().String()
-
-	podlistobj, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: labelSelector,
-	})
+func GetReadyPodListForDeployment(ctx context.Context, reader ClusterReader, deploymentName, namespace string) ([]v1.Pod, error) {
+	pods, err := getDeploymentPods(ctx, reader, deploymentName, namespace)
 	if err != nil {
 		return []v1.Pod{}, err
 	}
 
 	podlist := []v1.Pod{}
-	for _, poddata := range podlistobj.Items {
+	for _, poddata := range pods {
 		for _, cond := range poddata.Status.Conditions {
 			if cond.Type == v1.PodReady {
 				podlist = append(podlist, poddata)
@@ -106,37 +115,78 @@ func GetReadyPodListForDeployment(clientset kube_client.Interface, deploymentNam
 	return podlist, nil
 }
 
-// returns total utilization, allocation, and # of pods in the deployment
-func GetDeploymentUtilAndAlloc(clientset kube_client.Interface, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod) (int64, int64, error) {
-	podMetricsList, err := getPodMetricsListForDeployment(clientset, metricsClient, deploymentName, namespace)
+// returns total utilization and allocation of the app containers in the deployment
+func GetDeploymentUtilAndAlloc(ctx context.Context, reader ClusterReader, metricsClient *metrics_client.Clientset, deploymentName, namespace string, podList []v1.Pod, sidecars []string) (int64, int64, error) {
+	podMetricsList, err := getPodMetricsListForDeployment(ctx, reader, metricsClient, deploymentName, namespace)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to get podMetricsList: %w", err)
 	}
 
 	utilMilli := int64(0)
 	for _, podMetrics := range podMetricsList.Items {
-		idx := 0
-		if podMetrics.Containers[0].Name == "linkerd-proxy" {
-			idx = 1
+		for _, container := range podMetrics.Containers {
+			if IsSidecar(container.Name, sidecars) {
+				continue
+			}
+			utilMilli += container.Usage.Cpu().MilliValue()
 		}
-		container := podMetrics.Containers[idx] // TODO: handle multiple containers
-		alloc := container.Usage.Cpu().MilliValue()
-		utilMilli += alloc
 	}
 	allocMilli := int64(0)
 	for _, pod := range podList {
-		idx := 0
-		if pod.Spec.Containers[0].Name == "linkerd-proxy" {
-			idx = 1
+		for _, container := range pod.Spec.Containers {
+			if IsSidecar(container.Name, sidecars) {
+				continue
+			}
+			allocMilli += container.Resources.Requests.Cpu().MilliValue()
 		}
-		allocMilli += pod.Spec.Containers[idx].Resources.Requests.Cpu().MilliValue() // TODO: handle multiple containers
 	}
 
 	return utilMilli, allocMilli, nil
 }
 
-func GetNodeUsage(metricsClient *metrics_client.Clientset, nodeName string) (int64, error) {
-	metricsNode, err := metricsClient.MetricsV1beta1().NodeMetricses().Get(context.TODO(), nodeName, metav1.GetOptions{})
+type ContainerUsage struct {
+	CPU    int64 // in millicpus
+	Memory int64 // working set in bytes
+}
+
+// usage of every container in the deployment, by pod name then container name
+func GetPodContainerUsage(ctx context.Context, reader ClusterReader, metricsClient *metrics_client.Clientset, deploymentName, namespace string) (map[string]map[string]ContainerUsage, error) {
+	podMetricsList, err := getPodMetricsListForDeployment(ctx, reader, metricsClient, deploymentName, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get podMetricsList: %w", err)
+	}
+
+	usage := map[string]map[string]ContainerUsage{}
+	for _, podMetrics := range podMetricsList.Items {
+		containers := map[string]ContainerUsage{}
+		for _, container := range podMetrics.Containers {
+			containers[container.Name] = ContainerUsage{
+				CPU:    container.Usage.Cpu().MilliValue(),
+				Memory: container.Usage.Memory().Value(),
+			}
+		}
+		usage[podMetrics.Name] = containers
+	}
+
+	return usage, nil
+}
+
+// whether the container was OOMKilled since the given time
+func RecentlyOOMKilled(pod v1.Pod, containerName string, since time.Time) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != containerName {
+			continue
+		}
+		terminated := status.LastTerminationState.Terminated
+		return terminated != nil && terminated.Reason == "OOMKilled" && terminated.FinishedAt.After(since)
+	}
+	return false
+}
+
+func GetNodeUsage(ctx context.Context, metricsClient *metrics_client.Clientset, nodeName string) (int64, error) {
+	metricsNode, err := retryGet(ctx, func(ctx context.Context) (*v1beta1.NodeMetrics, error) {
+		return metricsClient.MetricsV1beta1().NodeMetricses().Get(ctx, nodeName, metav1.GetOptions{})
+	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to get node metrics: %w", err)
 	}
@@ -145,22 +195,20 @@ func GetNodeUsage(metricsClient *metrics_client.Clientset, nodeName string) (int
 	return usage, nil
 }
 
-func GetNodeAllocableAndCapacity(clientset kube_client.Interface, nodeName string) (int64, int64, error) {
-	node, err := clientset.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+func GetNodeAllocableAndCapacity(ctx context.Context, reader ClusterReader, nodeName string) (int64, int64, error) {
+	node, err := reader.GetNode(ctx, nodeName)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to get node: %w", err)
 	}
 
 	capacity := node.Status.Capacity.Cpu().MilliValue()
 
-	podlist, err := clientset.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{
-		FieldSelector: "spec.nodeName=" + nodeName,
-	})
+	podlist, err := reader.ListNodePods(ctx, nodeName)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to get pods on node: %w", err)
 	}
 	allocatable := node.Status.Allocatable.Cpu().MilliValue()
-	for _, pod := range podlist.Items {
+	for _, pod := range podlist {
 		for _, cont := range pod.Spec.Containers {
 			allocatable -= cont.Resources.Requests.Cpu().MilliValue()
 		}
@@ -169,18 +217,50 @@ func GetNodeAllocableAndCapacity(clientset kube_client.Interface, nodeName strin
 	return allocatable, capacity, nil
 }
 
-func GetControlledDeployments(clientset kube_client.Interface) (*appsv1.DeploymentList, error) {
-	deployments, err := clientset.AppsV1().Deployments("").List(context.TODO(), metav1.ListOptions{LabelSelector: AUTOSCALE_LABEL})
+// what the scheduler looks at besides cpu
+type NodeScheduling struct {
+	MemAllocable  int64 // unrequested allocatable memory, in bytes
+	Labels        map[string]string
+	Taints        []v1.Taint
+	Unschedulable bool // cordoned
+}
+
+func GetNodeScheduling(ctx context.Context, reader ClusterReader, nodeName string) (NodeScheduling, error) {
+	node, err := reader.GetNode(ctx, nodeName)
+	if err != nil {
+		return NodeScheduling{}, fmt.Errorf("failed to get node: %w", err)
+	}
+
+	podlist, err := reader.ListNodePods(ctx, nodeName)
+	if err != nil {
+		return NodeScheduling{}, fmt.Errorf("failed to get pods on node: %w", err)
+	}
+	memAllocable := node.Status.Allocatable.Memory().Value()
+	for _, pod := range podlist {
+		for _, cont := range pod.Spec.Containers {
+			memAllocable -= cont.Resources.Requests.Memory().Value()
+		}
+	}
+
+	return NodeScheduling{MemAllocable: memAllocable, Labels: node.Labels, Taints: node.Spec.Taints, Unschedulable: node.Spec.Unschedulable}, nil
+}
+
+func GetControlledDeployments(ctx context.Context, reader ClusterReader) (*appsv1.DeploymentList, error) {
+	selector, err := labels.Parse(AUTOSCALE_LABEL)
+	if err != nil {
+		return nil, err
+	}
+	deployments, err := reader.ListDeployments(ctx, selector)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get deployments: %w", err)
 	}
-	return deployments, nil
+	return &appsv1.DeploymentList{Items: deployments}, nil
 }
 
 /*	legacy
 
-func GetAllDeploymentsFromNamespace(clientset kube_client.Interface, namespace string) (*appsv1.DeploymentList, error) {
-	deployments, err := clientset.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{})
+func GetAllDeploymentsFromNamespace(ctx context.Context, clientset kube_client.Interface, namespace string) (*appsv1.DeploymentList, error) {
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get deployments: %w", err)
 	}
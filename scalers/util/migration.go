@@ -0,0 +1,174 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kube_client "k8s.io/client-go/kubernetes"
+)
+
+/* --- POD MIGRATION ---
+ * a pod is moved by starting a copy pinned to the target node and deleting the original once the copy is ready
+ * the copy starts without the replicaset's pod-template-hash label so the replicaset leaves it alone,
+ * and gets the label once ready - the replicaset then adopts it and scales the original (cheapest to delete) away
+ */
+const (
+	MIGRATION_POLL_INTERVAL      = 500 * time.Millisecond
+	MIGRATION_CLEANUP_TIMEOUT    = 10 * time.Second // removing a replacement that never became ready, past the migration's own deadline
+	POD_TEMPLATE_HASH_LABEL      = "pod-template-hash"
+	POD_DELETION_COST_ANNOTATION = "controller.kubernetes.io/pod-deletion-cost"
+	MIGRATED_FROM_ANNOTATION     = "podoscaler/migrated-from"
+)
+
+var ErrMigrationFailed = errors.New("migration failed")
+
+// moves the pod to nodename, returns the name of the pod that replaced it
+// the original is only deleted once its replacement is ready, on failure the replacement is removed and the original kept
+func MigratePod(ctx context.Context, clientset kube_client.Interface, podname string, nodename string, namespace string, timeout time.Duration) (string, error) {
+	var original *v1.Pod
+	err := retry(ctx, func(ctx context.Context) error {
+		var err error
+		original, err = clientset.CoreV1().Pods(namespace).Get(ctx, podname, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// not retried, a create that timed out may still have gone through and a second one would start two pods
+	replacement, err := clientset.CoreV1().Pods(namespace).Create(ctx, ReplacementPod(original, nodename), metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to create replacement for pod %s: %w", ErrMigrationFailed, podname, err)
+	}
+
+	err = waitForReplacement(ctx, clientset, replacement.Name, namespace, timeout)
+	if err != nil {
+		// the original never stopped serving, only the replacement has to go
+		cleanup, cancel := context.WithTimeout(context.WithoutCancel(ctx), MIGRATION_CLEANUP_TIMEOUT)
+		defer cancel()
+		if delErr := DeletePod(cleanup, clientset, replacement.Name, namespace); delErr != nil && !apierrors.IsNotFound(delErr) {
+			fmt.Printf("❌ ERROR: Failed to delete replacement pod %s: %s\n", replacement.Name, delErr.Error())
+		}
+		return "", fmt.Errorf("%w: pod %s to node %s: %w", ErrMigrationFailed, podname, nodename, err)
+	}
+
+	// the original goes first if the replicaset scales down before the delete below lands
+	costPatch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, POD_DELETION_COST_ANNOTATION, strconv.Itoa(-1<<31))
+	err = retry(ctx, func(ctx context.Context) error {
+		_, err := clientset.CoreV1().Pods(namespace).Patch(ctx, podname, k8stypes.MergePatchType, []byte(costPatch), metav1.PatchOptions{})
+		return err
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return replacement.Name, err
+	}
+
+	if hash, ok := original.Labels[POD_TEMPLATE_HASH_LABEL]; ok {
+		adoptPatch := fmt.Sprintf(`{"metadata":{"labels":{%q:%q}}}`, POD_TEMPLATE_HASH_LABEL, hash)
+		err = retry(ctx, func(ctx context.Context) error {
+			_, err := clientset.CoreV1().Pods(namespace).Patch(ctx, replacement.Name, k8stypes.MergePatchType, []byte(adoptPatch), metav1.PatchOptions{})
+			return err
+		})
+		if err != nil {
+			return replacement.Name, fmt.Errorf("failed to hand replacement pod %s to the replicaset: %w", replacement.Name, err)
+		}
+	}
+
+	err = DeletePod(ctx, clientset, podname, namespace)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return replacement.Name, err
+	}
+	return replacement.Name, nil
+}
+
+// a copy of pod that can only be scheduled onto nodename, and is not yet owned by the replicaset
+// the planner picked the node, so any required node affinity of the original is replaced (node selectors still apply)
+func ReplacementPod(pod *v1.Pod, nodename string) *v1.Pod {
+	labels := map[string]string{}
+	for k, v := range pod.Labels {
+		if k != POD_TEMPLATE_HASH_LABEL {
+			labels[k] = v
+		}
+	}
+	annotations := map[string]string{MIGRATED_FROM_ANNOTATION: pod.Name}
+	for k, v := range pod.Annotations {
+		if k != POD_DELETION_COST_ANNOTATION {
+			annotations[k] = v
+		}
+	}
+	generateName := pod.GenerateName
+	if generateName == "" {
+		generateName = pod.Name + "-"
+	}
+
+	spec := pod.Spec.DeepCopy()
+	spec.NodeName = ""
+	spec.EphemeralContainers = nil
+	if spec.Affinity == nil {
+		spec.Affinity = &v1.Affinity{}
+	}
+	if spec.Affinity.NodeAffinity == nil {
+		spec.Affinity.NodeAffinity = &v1.NodeAffinity{}
+	}
+	spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &v1.NodeSelector{
+		NodeSelectorTerms: []v1.NodeSelectorTerm{{
+			MatchFields: []v1.NodeSelectorRequirement{{Key: "metadata.name", Operator: v1.NodeSelectorOpIn, Values: []string{nodename}}},
+		}},
+	}
+
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: generateName,
+			Namespace:    pod.Namespace,
+			Labels:       labels,
+			Annotations:  annotations,
+		},
+		Spec: *spec,
+	}
+}
+
+// polls the replacement until it is ready, failing fast if it cannot be scheduled or fails to start
+func waitForReplacement(ctx context.Context, clientset kube_client.Interface, podname string, namespace string, timeout time.Duration) error {
+	var failed error
+	last := "not scheduled"
+	err := wait.PollUntilContextTimeout(ctx, MIGRATION_POLL_INTERVAL, timeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podname, metav1.GetOptions{})
+		if IsRetryable(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if pod.Status.Phase == v1.PodFailed {
+			failed = fmt.Errorf("replacement pod %s failed: %s", podname, pod.Status.Reason)
+			return true, nil
+		}
+		for _, condition := range pod.Status.Conditions {
+			switch {
+			case condition.Type == v1.PodScheduled && condition.Status == v1.ConditionFalse && condition.Reason == v1.PodReasonUnschedulable:
+				failed = fmt.Errorf("replacement pod %s is unschedulable: %s", podname, condition.Message)
+				return true, nil
+			case condition.Type == v1.PodScheduled && condition.Status == v1.ConditionTrue:
+				last = "scheduled, not ready"
+			case condition.Type == v1.PodReady && condition.Status == v1.ConditionTrue:
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if failed != nil {
+		return failed
+	}
+	if wait.Interrupted(err) && ctx.Err() == nil {
+		return fmt.Errorf("replacement pod %s not ready after %s (%s)", podname, timeout, last)
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tholiang/podoscaler/scalers/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+func GetDynamicClient(config *rest.Config) (dynamic.Interface, error) {
+	return dynamic.NewForConfig(config)
+}
+
+// every PodoscalerPolicy in the cluster
+func ListPodoscalerPolicies(ctx context.Context, dynamicClient dynamic.Interface) (*v1alpha1.PodoscalerPolicyList, error) {
+	list, err := retryGet(ctx, func(ctx context.Context) (*unstructured.UnstructuredList, error) {
+		return dynamicClient.Resource(v1alpha1.PodoscalerPolicyResource).Namespace("").List(ctx, metav1.ListOptions{})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list podoscaler policies: %w", err)
+	}
+
+	policies := &v1alpha1.PodoscalerPolicyList{}
+	for _, item := range list.Items {
+		policy := v1alpha1.PodoscalerPolicy{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.UnstructuredContent(), &policy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode podoscaler policy %s/%s: %w", item.GetNamespace(), item.GetName(), err)
+		}
+		policies.Items = append(policies.Items, policy)
+	}
+	return policies, nil
+}
+
+// replaces the policy's status through the status subresource
+func PatchPodoscalerPolicyStatus(ctx context.Context, dynamicClient dynamic.Interface, namespace string, name string, status v1alpha1.PodoscalerPolicyStatus) error {
+	patch, err := json.Marshal(map[string]any{"status": status})
+	if err != nil {
+		return err
+	}
+
+	err = retry(ctx, func(ctx context.Context) error {
+		_, err := dynamicClient.Resource(v1alpha1.PodoscalerPolicyResource).Namespace(namespace).Patch(ctx, name, k8stypes.MergePatchType, patch, metav1.PatchOptions{}, "status")
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update status of podoscaler policy %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+func GetDeployment(ctx context.Context, reader ClusterReader, deploymentName string, namespace string) (*appsv1.Deployment, error) {
+	deployment, err := reader.GetDeployment(ctx, namespace, deploymentName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	return deployment, nil
+}
@@ -18,7 +18,7 @@ const DEFAULT_PROMETHEUS_URL = "http://prometheus-kube-prometheus-prometheus.pro
 const PROMETHEUS_QUERY = `avg(aws_elb_latency_p99)`
 
 // return map of endpoint path to percentile latency
-func GetLatencyMetrics(deployment_name string, percentile float64) (map[string]float64, error) {
+func GetLatencyMetrics(ctx context.Context, deployment_name string, percentile float64) (map[string]float64, error) {
 	prom_url := os.Getenv("PROMETHEUS_URL")
 	if prom_url == "" {
 		return nil, errors.New("PROMETHEUS_URL env not set")
@@ -30,7 +30,7 @@ func GetLatencyMetrics(deployment_name string, percentile float64) (map[string]f
 	}
 
 	v1api := v1.NewAPI(client)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	result, warnings, err := v1api.Query(ctx, PROMETHEUS_QUERY, time.Now())
@@ -64,3 +64,72 @@ func metricToMap(m model.Metric) map[string]string {
 	}
 	return result
 }
+
+// run a user-supplied latency query (result in seconds, like the cloudwatch ELB metric)
+// the result is reported as the "p99" percentile so it can stand in for a load balancer
+func GetLatencyPrometheus(ctx context.Context, query string) (map[string]float64, error) {
+	latency, err := queryPrometheusValue(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]float64{"p99": latency}, nil
+}
+
+// run user-supplied request and 5xx rate queries (both per second, e.g. sum(rate(..._total[1m])))
+// an empty vector is no traffic, not an error
+func GetTrafficPrometheus(ctx context.Context, requestsQuery string, errorsQuery string) (Traffic, error) {
+	var traffic Traffic
+	var err error
+	traffic.Requests, err = queryPrometheusValue(ctx, requestsQuery)
+	if err != nil && !errors.Is(err, errNoPrometheusResults) {
+		return Traffic{}, fmt.Errorf("requests query: %w", err)
+	}
+	traffic.Errors, err = queryPrometheusValue(ctx, errorsQuery)
+	if err != nil && !errors.Is(err, errNoPrometheusResults) {
+		return Traffic{}, fmt.Errorf("errors query: %w", err)
+	}
+	return traffic, nil
+}
+
+var errNoPrometheusResults = errors.New("No results returned")
+
+// the single value a scalar or one-series vector query returns
+func queryPrometheusValue(ctx context.Context, query string) (float64, error) {
+	prom_url := os.Getenv("PROMETHEUS_URL")
+	if prom_url == "" {
+		return 0, errors.New("PROMETHEUS_URL env not set")
+	}
+
+	client, err := api.NewClient(api.Config{Address: prom_url})
+	if err != nil {
+		return 0, fmt.Errorf("Error creating client: %v", err)
+	}
+
+	v1api := v1.NewAPI(client)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	result, warnings, err := v1api.Query(ctx, query, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("Error querying prometheus: %v", err)
+	}
+	if len(warnings) > 0 {
+		log.Printf("Warnings: %v", warnings)
+	}
+
+	switch result.Type() {
+	case model.ValScalar:
+		return float64(result.(*model.Scalar).Value), nil
+	case model.ValVector:
+		vec := result.(model.Vector)
+		if len(vec) == 0 {
+			return 0, errNoPrometheusResults
+		}
+		if len(vec) > 1 {
+			return 0, fmt.Errorf("query returned %d series, expected 1", len(vec))
+		}
+		return float64(vec[0].Value), nil
+	default:
+		return 0, errors.New("Wrong result type")
+	}
+}
@@ -0,0 +1,116 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kube_client "k8s.io/client-go/kubernetes"
+)
+
+/* --- IN-PLACE RESIZE STATUS ---
+ * a resize patch only changes the pod spec, the kubelet applies it later (or never)
+ * kubernetes 1.33+ reports progress as pod conditions, older releases in status.resize
+ */
+const (
+	POD_RESIZE_PENDING     v1.PodConditionType = "PodResizePending"    // reason is Deferred or Infeasible
+	POD_RESIZE_IN_PROGRESS v1.PodConditionType = "PodResizeInProgress" // allocated, not yet actuated
+)
+
+const RESIZE_POLL_INTERVAL = 500 * time.Millisecond
+
+var (
+	ErrResizeInfeasible = errors.New("resize infeasible on the pod's node")
+	ErrResizeTimeout    = errors.New("resize not applied in time")
+)
+
+// polls the pod until the kubelet has applied resources to the container, calls the resize infeasible, or timeout passes
+// a deferred resize is waited on like one in progress, the node may free up in time
+func WaitForResize(ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources VerticalPatchContainerResources, namespace string, timeout time.Duration) error {
+	var status v1.PodResizeStatus
+	var infeasible error
+	err := wait.PollUntilContextTimeout(ctx, RESIZE_POLL_INTERVAL, timeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podname, metav1.GetOptions{})
+		if IsRetryable(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		var applied bool
+		applied, status = ResizeState(pod, containername, resources)
+		if status == v1.PodResizeStatusInfeasible {
+			infeasible = fmt.Errorf("%w: pod %s on node %s", ErrResizeInfeasible, podname, pod.Spec.NodeName)
+			return true, nil
+		}
+		return applied, nil
+	})
+	if infeasible != nil {
+		return infeasible
+	}
+	if wait.Interrupted(err) && ctx.Err() == nil {
+		if status == "" {
+			status = "not reported"
+		}
+		return fmt.Errorf("%w: pod %s after %s (last status %s)", ErrResizeTimeout, podname, timeout, status)
+	}
+	return err
+}
+
+// whether the kubelet has applied resources to the container and, if not, the resize status it reports
+// clusters that do not report allocated or actual resources count as applied once no resize is pending
+func ResizeState(pod *v1.Pod, containername string, resources VerticalPatchContainerResources) (bool, v1.PodResizeStatus) {
+	status := pod.Status.Resize
+	for _, condition := range pod.Status.Conditions {
+		if condition.Status != v1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case POD_RESIZE_PENDING:
+			status = v1.PodResizeStatus(condition.Reason)
+		case POD_RESIZE_IN_PROGRESS:
+			status = v1.PodResizeStatusInProgress
+		}
+	}
+	if status != "" {
+		return false, status
+	}
+
+	for _, container := range pod.Status.ContainerStatuses {
+		if container.Name != containername {
+			continue
+		}
+		if len(container.AllocatedResources) > 0 && !resourcesMatch(container.AllocatedResources, resources.Requests) {
+			return false, status
+		}
+		if actual := container.Resources; actual != nil {
+			return resourcesMatch(actual.Requests, resources.Requests) && resourcesMatch(actual.Limits, resources.Limits), status
+		}
+		return true, status
+	}
+	return false, status
+}
+
+// every value set in the patch is what the list holds
+func resourcesMatch(list v1.ResourceList, spec VerticalPatchResourceSpec) bool {
+	for name, value := range map[v1.ResourceName]string{v1.ResourceCPU: spec.CPU, v1.ResourceMemory: spec.Memory} {
+		if value == "" || value == REMOVE_RESOURCE {
+			continue
+		}
+		want, err := resource.ParseQuantity(value)
+		if err != nil {
+			return false
+		}
+		have, ok := list[name]
+		if !ok || have.Cmp(want) != 0 {
+			return false
+		}
+	}
+	return true
+}
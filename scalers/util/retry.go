@@ -0,0 +1,94 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsretry "github.com/aws/aws-sdk-go-v2/aws/retry"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+/* --- RETRIES ---
+ * kubernetes and cloudwatch calls are retried with exponential backoff and jitter
+ * only transient errors are retried, anything else (not found, forbidden, bad config) fails right away
+ */
+var DEFAULT_BACKOFF = wait.Backoff{
+	Duration: 200 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.2, // up to 20% on top of each wait so replicas and workers do not retry in lockstep
+	Steps:    4,   // attempts, including the first
+	Cap:      2 * time.Second,
+}
+
+// transient failures: throttling, timeouts, unavailable or failing servers, dropped connections
+// errors from the caller's own context are never retryable
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if apierrors.IsTooManyRequests(err) || apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) ||
+		apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err) || apierrors.IsUnexpectedServerError(err) ||
+		apierrors.IsConflict(err) {
+		return true
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		return false // any other status from the API server is an answer, not a blip
+	}
+
+	if awsretry.IsErrorRetryables(awsretry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary ||
+		awsretry.IsErrorThrottles(awsretry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+		return true
+	}
+
+	if utilnet.IsConnectionReset(err) || utilnet.IsConnectionRefused(err) || utilnet.IsProbableEOF(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// runs op until it succeeds, fails with a non-retryable error, runs out of attempts or ctx is done
+func Retry(ctx context.Context, backoff wait.Backoff, op func(ctx context.Context) error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = op(ctx)
+		if !IsRetryable(err) {
+			return err
+		}
+		if backoff.Steps <= 1 {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		delay := backoff.Step()
+		fmt.Printf("🔄 Retrying in %s after: %s\n", delay.Round(time.Millisecond), err.Error())
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Retry with DEFAULT_BACKOFF
+func retry(ctx context.Context, op func(ctx context.Context) error) error {
+	return Retry(ctx, DEFAULT_BACKOFF, op)
+}
+
+// retry for calls that return a value
+func retryGet[T any](ctx context.Context, op func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := retry(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = op(ctx)
+		return err
+	})
+	return result, err
+}
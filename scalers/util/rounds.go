@@ -0,0 +1,40 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+/* --- ROUND STATS ---
+ * a failed round is logged and counted, the next round still runs on schedule
+ */
+type RoundStats struct {
+	rounds      atomic.Int64
+	failures    atomic.Int64
+	consecutive atomic.Int64
+}
+
+// returns how many rounds in a row have failed, 0 after a good round
+func (s *RoundStats) Record(err error) int64 {
+	s.rounds.Add(1)
+	if err == nil {
+		s.consecutive.Store(0)
+		return 0
+	}
+	s.failures.Add(1)
+	return s.consecutive.Add(1)
+}
+
+func (s *RoundStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# HELP podoscaler_rounds_total Rounds run by this replica.\n")
+	fmt.Fprintf(w, "# TYPE podoscaler_rounds_total counter\n")
+	fmt.Fprintf(w, "podoscaler_rounds_total %d\n", s.rounds.Load())
+	fmt.Fprintf(w, "# HELP podoscaler_round_failures_total Rounds that returned an error.\n")
+	fmt.Fprintf(w, "# TYPE podoscaler_round_failures_total counter\n")
+	fmt.Fprintf(w, "podoscaler_round_failures_total %d\n", s.failures.Load())
+	fmt.Fprintf(w, "# HELP podoscaler_round_consecutive_failures Rounds in a row that returned an error.\n")
+	fmt.Fprintf(w, "# TYPE podoscaler_round_consecutive_failures gauge\n")
+	fmt.Fprintf(w, "podoscaler_round_consecutive_failures %d\n", s.consecutive.Load())
+}
@@ -5,22 +5,23 @@ import (
 
 	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	kube_client "k8s.io/client-go/kubernetes"
 )
 
-func ChangeReplicaCount(namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error {
+func ChangeReplicaCount(ctx context.Context, namespace string, deploymentName string, replicaCt int, clientset kube_client.Interface) error {
 	hsr := HorizontalScaleRequest{
 		DeploymentNamespace: namespace,
 		DeploymentName:      deploymentName,
 		Replicas:            int32(replicaCt),
 	}
-	return hScaleFromHSR(clientset, hsr)
+	return hScaleFromHSR(ctx, clientset, hsr)
 }
 
-func hScaleFromHSR(clientset kube_client.Interface, req HorizontalScaleRequest) error {
+func hScaleFromHSR(ctx context.Context, clientset kube_client.Interface, req HorizontalScaleRequest) error {
 	// create patch with number of replicas
 	patch, err := create_hpatch(req.Replicas)
 	if err != nil {
@@ -29,14 +30,22 @@ func hScaleFromHSR(clientset kube_client.Interface, req HorizontalScaleRequest)
 
 	// patch deployment/scale resource for given deployment
 	// derived from kubectl example: https://kubernetes.io/docs/reference/kubectl/generated/kubectl_patch/
-	_, err = clientset.AppsV1().Deployments(req.DeploymentNamespace).Patch(context.TODO(), req.DeploymentName, k8stypes.MergePatchType, patch, metav1.PatchOptions{}, "scale")
+	err = retry(ctx, func(ctx context.Context) error {
+		_, err := clientset.AppsV1().Deployments(req.DeploymentNamespace).Patch(ctx, req.DeploymentName, k8stypes.MergePatchType, patch, metav1.PatchOptions{}, "scale")
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
 	// check every 500ms for 5s or until all replicas are ready
-	err = wait.PollUntilContextTimeout(context.TODO(), 500*time.Millisecond, 10*time.Second, true, func(ctx context.Context) (bool, error) {
-		podlist, err := GetReadyPodListForDeployment(clientset, req.DeploymentName, req.DeploymentNamespace)
+	// read from the API server, a cache could still be behind the patch
+	reader := NewAPIReader(clientset)
+	err = wait.PollUntilContextTimeout(ctx, 500*time.Millisecond, 10*time.Second, true, func(ctx context.Context) (bool, error) {
+		podlist, err := GetReadyPodListForDeployment(ctx, reader, req.DeploymentName, req.DeploymentNamespace)
+		if IsRetryable(err) {
+			return false, nil
+		}
 		if err != nil {
 			return false, err
 		}
@@ -49,9 +58,9 @@ func hScaleFromHSR(clientset kube_client.Interface, req HorizontalScaleRequest)
 	return nil
 }
 
-func VScale(clientset kube_client.Interface, podname string, containername string, cpurequests string, namespace string) error {
-	// create patch with number of replicas
-	patch, err := create_vpatch(containername, cpurequests)
+func VScale(ctx context.Context, clientset kube_client.Interface, podname string, containername string, resources VerticalPatchContainerResources, namespace string) error {
+	// create patch with new requests and limits
+	patch, err := create_vpatch(containername, resources)
 	if err != nil {
 		return err
 	}
@@ -59,7 +68,10 @@ func VScale(clientset kube_client.Interface, podname string, containername strin
 	// patch pods/resize resource for given deployment
 	// derived from kubectl example: https://kubernetes.io/docs/tasks/configure-pod-container/resize-container-resources/
 	// I dont really get patch types but this only works with strategic
-	_, err = clientset.CoreV1().Pods(namespace).Patch(context.TODO(), podname, k8stypes.StrategicMergePatchType, patch, metav1.PatchOptions{}, "resize")
+	err = retry(ctx, func(ctx context.Context) error {
+		_, err := clientset.CoreV1().Pods(namespace).Patch(ctx, podname, k8stypes.StrategicMergePatchType, patch, metav1.PatchOptions{}, "resize")
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -67,15 +79,18 @@ func VScale(clientset kube_client.Interface, podname string, containername strin
 	return nil
 }
 
-func PatchDeploymentReqs(clientset kube_client.Interface, deploymentName string, containeridx int, cpurequests string, namespace string) error {
-	// create patch with number of replicas
-	patch, err := create_deployment_request_patch(containeridx, cpurequests)
+func PatchDeploymentReqs(ctx context.Context, clientset kube_client.Interface, deploymentName string, containername string, resources VerticalPatchContainerResources, namespace string) error {
+	// create patch with new requests and limits
+	patch, err := create_deployment_request_patch(containername, resources)
 	if err != nil {
 		return err
 	}
 
 	// patch default pod size for deployment
-	_, err = clientset.AppsV1().Deployments(namespace).Patch(context.TODO(), deploymentName, k8stypes.JSONPatchType, patch, metav1.PatchOptions{})
+	err = retry(ctx, func(ctx context.Context) error {
+		_, err := clientset.AppsV1().Deployments(namespace).Patch(ctx, deploymentName, k8stypes.StrategicMergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -83,8 +98,17 @@ func PatchDeploymentReqs(clientset kube_client.Interface, deploymentName string,
 	return nil
 }
 
-func DeletePod(clientset kube_client.Interface, podname string, namespace string) error {
-	err := clientset.CoreV1().Pods(namespace).Delete(context.TODO(), podname, metav1.DeleteOptions{})
+func DeletePod(ctx context.Context, clientset kube_client.Interface, podname string, namespace string) error {
+	attempts := 0
+	err := retry(ctx, func(ctx context.Context) error {
+		attempts++
+		err := clientset.CoreV1().Pods(namespace).Delete(ctx, podname, metav1.DeleteOptions{})
+		// a retry can find the pod already gone if the failed attempt went through after all
+		if attempts > 1 && apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
 	if err != nil {
 		return err
 	}
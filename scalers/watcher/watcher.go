@@ -4,6 +4,7 @@
 package watcher
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -36,12 +37,14 @@ type Watcher struct {
 	PrometheusUrl    string
 	Clientset        kube_client.Interface
 	MetricsClientset *metrics_client.Clientset
+	Cache            *util.ClusterCache // nodes, pods and deployments
 
 	rounds int64
 	data   []RoundData
 }
 
-func (w *Watcher) Init() error {
+// the cache stops with ctx
+func (w *Watcher) Init(ctx context.Context) error {
 	/* --- CONFIGURATION LOGIC --- */
 	// creates the in-cluster config
 	config, err := rest.InClusterConfig()
@@ -59,6 +62,15 @@ func (w *Watcher) Init() error {
 		return err
 	}
 
+	w.Cache, err = util.NewClusterCache(w.Clientset, util.DEFAULT_CACHE_RESYNC)
+	if err != nil {
+		return err
+	}
+	err = w.Cache.Start(ctx)
+	if err != nil {
+		return err
+	}
+
 	// set env variable for Prometheus service url
 	os.Setenv("PROMETHEUS_URL", util.DEFAULT_PROMETHEUS_URL)
 
@@ -68,13 +80,13 @@ func (w *Watcher) Init() error {
 	return nil
 }
 
-func (w *Watcher) WatchRound() error {
+func (w *Watcher) WatchRound(ctx context.Context) error {
 	fmt.Printf("Round %d\n", w.rounds)
 	w.rounds++
 	var rounddata = RoundData{Latencies: map[string]float64{}, Nodes: map[string]NodeRoundData{}, Deployments: map[string]DeploymentRoundData{}}
 
 	// get node usages
-	nodelist, err := util.GetNodeList(w.Clientset)
+	nodelist, err := util.GetNodeList(ctx, w.Cache)
 	if err != nil {
 		fmt.Printf("ERROR: Failed to get node list: %s\n", err.Error())
 		return err
@@ -83,13 +95,13 @@ func (w *Watcher) WatchRound() error {
 	for _, node := range nodelist.Items {
 		nodeName := node.Name
 
-		allocable, capacity, err := util.GetNodeAllocableAndCapacity(w.Clientset, nodeName)
+		allocable, capacity, err := util.GetNodeAllocableAndCapacity(ctx, w.Cache, nodeName)
 		if err != nil {
 			fmt.Printf("ERROR: Failed to get node metrics for node %s: %s\n", nodeName, err.Error())
 			continue
 		}
 
-		usage, err := util.GetNodeUsage(w.MetricsClientset, nodeName)
+		usage, err := util.GetNodeUsage(ctx, w.MetricsClientset, nodeName)
 		if err != nil {
 			fmt.Printf("ERROR: Failed to get usage for node %s: %s\n", nodeName, err.Error())
 			continue
@@ -100,19 +112,19 @@ func (w *Watcher) WatchRound() error {
 	}
 
 	// get latency
-	lb_name, err := util.GetLoadBalancerName(w.Clientset, os.Getenv("AUTOSCALE_NAMESPACE"), os.Getenv("AUTOSCALE_LB"))
+	lb_name, err := util.GetLoadBalancerName(ctx, w.Clientset, os.Getenv("AUTOSCALE_NAMESPACE"), os.Getenv("AUTOSCALE_LB"))
 	if err != nil {
 		fmt.Printf("ERROR: Failed to get load balancer name: %s\n", err.Error())
 		return err
 	}
-	rounddata.Latencies, err = util.GetLatencyCloudwatch(lb_name)
+	rounddata.Latencies, err = util.GetLatencyCloudwatch(ctx, lb_name)
 	if err != nil {
 		fmt.Printf("ERROR: Failed to get latency: %s\n", err.Error())
 		return err
 	}
 
 	// Get all deployments in the namespace
-	deployments, err := util.GetControlledDeployments(w.Clientset)
+	deployments, err := util.GetControlledDeployments(ctx, w.Cache)
 	if err != nil {
 		fmt.Printf("ERROR: Failed to get deployments: %s\n", err.Error())
 		return err
@@ -124,13 +136,13 @@ func (w *Watcher) WatchRound() error {
 		deploymentName := deployment.Name
 		deploymentNamespace := deployment.Namespace
 
-		podList, err := util.GetReadyPodListForDeployment(w.Clientset, deploymentName, deploymentNamespace)
+		podList, err := util.GetReadyPodListForDeployment(ctx, w.Cache, deploymentName, deploymentNamespace)
 		if err != nil {
 			fmt.Printf("ERROR: Failed to get pod list for deployment %s: %s\n", deploymentName, err.Error())
 			continue
 		}
 
-		utilization, alloc, err := util.GetDeploymentUtilAndAlloc(w.Clientset, w.MetricsClientset, deploymentName, deploymentNamespace, podList)
+		utilization, alloc, err := util.GetDeploymentUtilAndAlloc(ctx, w.Cache, w.MetricsClientset, deploymentName, deploymentNamespace, podList, util.DEFAULT_SIDECARS)
 		if err != nil {
 			fmt.Printf("ERROR: Failed to get utilization metrics for deployment %s: %s\n", deploymentName, err.Error())
 			continue
@@ -145,7 +157,7 @@ func (w *Watcher) WatchRound() error {
 
 	// print output
 	for percentile, latency := range rounddata.Latencies {
-		fmt.Printf("percentile %s latency %d\n", percentile, latency)
+		fmt.Printf("percentile %s latency %.2f\n", percentile, latency)
 	}
 
 	for node, data := range rounddata.Nodes {